@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//BumpFee replaces a transaction of one of the wallets waiting in the mempool with one paying
+//feeRate base units per byte. A feeRate of 0 raises the old rate by the default fee rate.
+func (cli *Client) BumpFee(txid string, feeRate core.Amount, mineNow bool) {
+	txID, err := hex.DecodeString(txid)
+	if err != nil {
+		log.Panic("ERROR: Transaction ID is not valid")
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	oldTx, ok := mempool.Get(txID)
+	if !ok {
+		log.Panic("ERROR: ", core.ErrBumpFeeNotFound)
+	}
+
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, "")
+	from, wallet := wallets.FindByPubKey(oldTx.Vin[0].PubKey)
+	if wallet == nil {
+		log.Panic("ERROR: ", core.ErrBumpFeeNotOwned)
+	}
+
+	tx, err := core.BumpFee(txID, wallets, feeRate, &UTXOSet, mempool)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	cli.submitTx(tx, mempool, &UTXOSet, from, mineNow)
+
+	entry, _ := mempool.Entry(tx.ID)
+	fmt.Printf("Replaced %x with %x paying a fee of %d\n", txID, tx.ID, entry.Fee)
+}
@@ -6,6 +6,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NlaakStudios/Blockchain/api/utils"
 
@@ -27,9 +30,22 @@ type Client struct {
 	DataFolder   string
 	ConfigName   string
 	ConfigFolder string
+	RPCConnect   string //address of the RPC server of the node commands hand their transactions to
 	isInit       bool
 }
 
+//stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 // NewMVC creates a new MVC gowaf app. If dir is passed, it should be a directory to look for
 // all project folders (config, static, views, models, controllers, etc). The App returned is initialized.
 func NewClient(cfgDir, cfgName string) (*Client, error) {
@@ -107,9 +123,12 @@ func (cli *Client) init() error {
 	}
 	cli.Config = appConfig
 
-	//Defaul node port (CONST)
-	cli.NodePort = config.NodePort
-	utils.CreateDirIfNotExist(config.FilePathData)
+	//Select the network before anything touches its files
+	cli.parseGlobalFlags()
+
+	//Default node port of the network
+	cli.NodePort = core.ActiveParams.DefaultPort
+	utils.CreateDirIfNotExist(filepath.Dir(core.GetBlockChainFile(cli.NodePort)))
 
 	//Validate the command line arguments
 	cli.validateArgs()
@@ -131,25 +150,87 @@ func (cli *Client) setConfigFolder(dir string) {
 	cli.ConfigFolder = dir
 }
 
+//parseGlobalFlags parses the options given before the command, which apply to every command, and removes them from
+//the arguments
+func (cli *Client) parseGlobalFlags() {
+	globalCmd := flag.NewFlagSet("blockchain", flag.ExitOnError)
+	network := globalCmd.String("network", core.MainNetParams.Name, "Network to run on: mainnet, testnet or regtest")
+	mockTime := globalCmd.Int64("mocktime", 0, "Unix time to stop the clock at (regtest only)")
+	rpcConnect := globalCmd.String("rpcconnect", "", "Address of the RPC server of the node (localhost and the RPC port of the network by default)")
+
+	err := globalCmd.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+	err = core.SelectNetwork(*network)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	err = core.SetMockTime(*mockTime)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	cli.RPCConnect = *rpcConnect
+	if cli.RPCConnect == "" {
+		cli.RPCConnect = "localhost:" + core.ActiveParams.DefaultRPCPort
+	}
+
+	os.Args = append(os.Args[:1], globalCmd.Args()...)
+}
+
 //printHeader diplay commandline application header to the user.
 func (cli *Client) printHeader() {
 	fmt.Printf("%s Blockchain Version %s\n", config.CoinName, config.Version())
 	fmt.Printf("%s (%s)\n", config.CoinCompany, config.CoinLandingPage)
+	if core.ActiveParams != &core.MainNetParams {
+		fmt.Printf("Network: %s\n", core.ActiveParams.Name)
+	}
 	fmt.Println("-----------------------------------------------------------------------------------------")
 }
 
 //printUsage diplay commandline usage information to the user.
 func (cli *Client) printUsage() {
-	fmt.Println("Usage:")
+	fmt.Println("Usage: [-network NETWORK] [-mocktime TIME] [-rpcconnect ADDR] COMMAND")
+	fmt.Println("	-network NETWORK - Run on mainnet (default), testnet or regtest. Each network keeps its blockchain, wallets and mempool in its own data directory")
+	fmt.Println("	-mocktime TIME - Stop the clock at the unix TIME while the command runs, for the timestamps of blocks, the mempool and the wallet file. Regtest only")
+	fmt.Println("	-rpcconnect ADDR - Hand new transactions to the running node whose RPC server listens on ADDR (localhost and the RPC port of the network by default)")
+	fmt.Println("Commands:")
+	fmt.Println("	broadcastpsbt -in FILE -miner ADDRESS -mine - Finalize the fully signed transaction of FILE and submit it, mining it at once, rewarding ADDRESS, when -mine is set")
+	fmt.Println("	bumpfee -txid TXID -feerate RATE -mine - Replace the wallet transaction TXID waiting in the mempool with one paying RATE base units per byte (old rate plus the default by default)")
+	fmt.Println("	changepassphrase -old OLD -new NEW - Encrypt the wallet file with the passphrase NEW instead of OLD")
+	fmt.Println("	combinepsbt -in FILE -in FILE [-in FILE...] -out OUT - Merge the signatures of copies of the same partially signed transaction into OUT")
 	fmt.Println("	createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("	createwallet - Generates a new key-pair and saves it into the wallet file")
-	fmt.Println("	getbalance -address ADDRESS - Get balance of ADDRESS")
-	fmt.Println("	listaddresses - Lists all addresses from the wallet file")
+	fmt.Println("	createpsbt -from FROM [-from FROM...] [-pubkey HEX...] -to TO -amount AMOUNT -asset ASSET -strategy STRATEGY -feerate RATE -out OUT - Write to OUT an unsigned transaction sending AMOUNT of ASSET from the FROM addresses to TO. Public keys of FROM addresses that are neither in the wallet file nor seen on the chain are given with -pubkey")
+	fmt.Println("	createrawtransaction -input TXID:VOUT [-input...] -output ADDRESS:AMOUNT[:ASSET] [-output...] -replaceable - Print as hex an unsigned transaction spending exactly the given inputs into the given outputs, or data:HEX outputs. Whatever is left of the native coins is the fee")
+	fmt.Println("	createwallet [-scheme p256|ed25519] - Derives the next receive address from the HD seed of the wallet file, which is created and shown as a mnemonic the first time")
+	fmt.Println("	decoderawtransaction -hex HEX - Show the transaction HEX as JSON, with its fee when the outputs it spends are known")
+	fmt.Println("	dumpprivkey -address ADDRESS -passphrase PASSPHRASE - Print the private key of ADDRESS for importprivkey. An encrypted wallet is unlocked with PASSPHRASE")
+	fmt.Println("	encryptwallet -passphrase PASSPHRASE - Encrypt the private keys of the wallet file with PASSPHRASE")
+	fmt.Println("	finalizepsbt -in FILE - Check the signatures of the fully signed transaction of FILE and print it as hex")
+	fmt.Println("	generate N -address ADDRESS - Have the running node mine N blocks at once with the transactions of its mempool, at the time of its clock, paying the rewards to ADDRESS (a new address of the wallet file by default). Regtest only, where blocks are found at once")
+	fmt.Println("	getbalance -address ADDRESS -asset ASSET - Get balance of ADDRESS in ASSET (native coin by default), or of the whole wallet file without -address")
+	fmt.Println("	importaddress -address ADDRESS -rescan - Watch ADDRESS without its private key, rescanning the chain for it when -rescan is set")
+	fmt.Println("	importprivkey -key KEY -passphrase PASSPHRASE -rescan - Add the private key KEY from dumpprivkey to the wallet file, rescanning the chain for its address when -rescan is set")
+	fmt.Println("	issueasset -from FROM -symbol SYMBOL -decimals N -supply N -mine - Issue a new asset and credit its supply to FROM")
+	fmt.Println("	listassets - Lists all assets issued on the chain")
+	fmt.Println("	listaddresses -balances - Lists all addresses from the wallet file, with their balance, purpose and label when -balances is set")
+	fmt.Println("	listtransactions -count N - Lists the N latest transactions of the wallet file (all by default) with their confirmations")
+	fmt.Println("	mine -node ADDR -address ADDRESS -blocks N - Mine blocks for the node whose RPC server listens on ADDR, paying rewards to ADDRESS (the node's miner address by default), stopping after N blocks (never by default)")
+	fmt.Println("	notarize -from FROM -file FILE [-file FILE...] -mine - Commit the hashes of FILEs to the chain in one transaction and write a FILE.notary.json proof for each")
 	fmt.Println("	printchain - Print all the blocks of the blockchain")
 	fmt.Println("	reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("	send -from FROM -to TO -amount AMOUNT -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
-	fmt.Println("	startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("	restorewallet -mnemonic MNEMONIC -passphrase PASSPHRASE - Restore the HD seed of MNEMONIC into the wallet file and rescan the chain for its used addresses. An encrypted wallet is unlocked with PASSPHRASE")
+	fmt.Println("	sendrawtransaction -hex HEX - Hand the signed transaction HEX to the running node, which adds it to its mempool and relays it")
+	fmt.Println("	setlabel -address ADDRESS -label LABEL - Label ADDRESS, of the wallet file or someone else's, with LABEL (an empty LABEL removes it)")
+	fmt.Println("	send -from FROM -to TO -amount AMOUNT -asset ASSET -strategy STRATEGY -feerate RATE -replaceable -passphrase PASSPHRASE -rpc ADDR -mine - Send AMOUNT of ASSET (native coin by default) from FROM address to TO, choosing coins with STRATEGY (largest-first, smallest-first, bnb or random-improve) and paying RATE base units per byte. With -replaceable, the transaction signals replace-by-fee so that bumpfee can raise its fee. An encrypted wallet is unlocked with PASSPHRASE. Mine on the same node, when -mine is set. With -rpc, the running node listening on ADDR sends from its unlocked wallet.")
+	fmt.Println("	setmocktime -time TIME -rpc ADDR - Stop the clock of the running regtest node listening on ADDR at the unix TIME, or let it run again when TIME is 0")
+	fmt.Println("	signpsbt -in FILE -out OUT -passphrase PASSPHRASE - Sign the inputs of the partially signed transaction of FILE whose keys are in the wallet file, without the chain, and write it to OUT (FILE by default). An encrypted wallet is unlocked with PASSPHRASE")
+	fmt.Println("	signrawtransaction -hex HEX -passphrase PASSPHRASE - Sign the inputs of the transaction HEX whose keys are in the wallet file and print it as hex. An encrypted wallet is unlocked with PASSPHRASE")
+	fmt.Println("	startnode -miner ADDRESS -mininterval DURATION -maxempty N -threads N -outbound N -maxinbound N -rpclisten ADDR -rpcallowunencrypted - Start a node with ID specified in NODE_ID env. var. -miner enables background mining, at most one block every DURATION, with up to N empty blocks in a row (-1 for no limit) and N threads (one per CPU by default). -outbound and -maxinbound set the peer connections the node keeps and accepts. -rpclisten serves external miners and wallet commands on ADDR (the RPC port of the network by default, none when empty), on the loopback interface when ADDR is only a port, authenticating them with the cookie file of the node. Wallet commands need an encrypted wallet unlocked with walletpassphrase, unless -rpcallowunencrypted is set")
+	fmt.Println("	verifynotary -proof PROOF [-file FILE] - Check a notary proof (and optionally the FILE it was made for) against the chain")
 	fmt.Println("	version - Display node version")
+	fmt.Println("	walletlock -rpc ADDR - Lock the wallet of the running node listening on ADDR")
+	fmt.Println("	walletpassphrase -rpc ADDR -passphrase PASSPHRASE -timeout DURATION - Unlock the wallet of the running node listening on ADDR for DURATION")
 	fmt.Println("")
 }
 
@@ -199,47 +280,252 @@ func (cli *Client) cmdCreateWallet() {
 // Run parses command line arguments and processes commands
 func (cli *Client) Run() {
 
+	broadcastPSBTCmd := flag.NewFlagSet("broadcastpsbt", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	changePassphraseCmd := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	issueAssetCmd := flag.NewFlagSet("issueasset", flag.ExitOnError)
+	listAssetsCmd := flag.NewFlagSet("listassets", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	notarizeCmd := flag.NewFlagSet("notarize", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	setMockTimeCmd := flag.NewFlagSet("setmocktime", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	verifyNotaryCmd := flag.NewFlagSet("verifynotary", flag.ExitOnError)
 	versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
-
-	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+
+	broadcastPSBTIn := broadcastPSBTCmd.String("in", "", "File of the fully signed transaction")
+	broadcastPSBTMiner := broadcastPSBTCmd.String("miner", "", "The address to send the block reward to with -mine")
+	broadcastPSBTMine := broadcastPSBTCmd.Bool("mine", false, "Mine immediately on the same node")
+	bumpFeeTxID := bumpFeeCmd.String("txid", "", "ID of the transaction to replace")
+	bumpFeeRate := bumpFeeCmd.Uint64("feerate", 0, "Fee in base units per byte of the replacement")
+	bumpFeeMine := bumpFeeCmd.Bool("mine", false, "Mine immediately on the same node")
+	changePassphraseOld := changePassphraseCmd.String("old", "", "Current passphrase (asked for when not given)")
+	changePassphraseNew := changePassphraseCmd.String("new", "", "New passphrase (asked for when not given)")
+	var combinePSBTIns stringList
+	combinePSBTCmd.Var(&combinePSBTIns, "in", "File of a partially signed copy of the transaction (may be repeated)")
+	combinePSBTOut := combinePSBTCmd.String("out", "", "File to write the combined transaction to")
+	var createPSBTFrom, createPSBTPubKeys stringList
+	createPSBTCmd.Var(&createPSBTFrom, "from", "Address whose outputs may be spent (may be repeated)")
+	createPSBTCmd.Var(&createPSBTPubKeys, "pubkey", "Hex public key of a FROM address unknown to the wallet file and the chain (may be repeated)")
+	createPSBTTo := createPSBTCmd.String("to", "", "Destination wallet address")
+	createPSBTAmount := createPSBTCmd.String("amount", "", "Amount to send, ie 1.25 or \"1.25 GWFI\"")
+	createPSBTAsset := createPSBTCmd.String("asset", "", "Symbol or ID of the asset to send (native coin by default)")
+	createPSBTStrategy := createPSBTCmd.String("strategy", "", "Coin selection strategy: largest-first (default), smallest-first, bnb or random-improve")
+	createPSBTFeeRate := createPSBTCmd.Uint64("feerate", uint64(core.DefaultFeeRate), "Fee in base units per byte of transaction")
+	createPSBTOut := createPSBTCmd.String("out", "", "File to write the unsigned transaction to")
+	var createRawTxInputs, createRawTxOutputs stringList
+	createRawTxCmd.Var(&createRawTxInputs, "input", "Output to spend, TXID:VOUT (may be repeated)")
+	createRawTxCmd.Var(&createRawTxOutputs, "output", "Output to create, ADDRESS:AMOUNT, ADDRESS:AMOUNT:ASSET or data:HEX (may be repeated)")
+	createRawTxReplaceable := createRawTxCmd.Bool("replaceable", false, "Signal replace-by-fee")
+	decodeRawTxHex := decodeRawTxCmd.String("hex", "", "Transaction as hex")
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "Address whose private key to print")
+	dumpPrivKeyPassphrase := dumpPrivKeyCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "Passphrase encrypting the wallet file (asked for when not given)")
+	finalizePSBTIn := finalizePSBTCmd.String("in", "", "File of the fully signed transaction")
+	generateBlocks := 0
+	generateAddress := generateCmd.String("address", "", "The address to send block rewards to (a new address of the wallet file by default)")
+	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for (the whole wallet file by default)")
+	getBalanceAsset := getBalanceCmd.String("asset", "", "Symbol or ID of the asset (native coin by default)")
+	createWalletScheme := createWalletCmd.String("scheme", "", "Signature scheme of the new key-pair: p256 (default) or ed25519")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "Mnemonic of the HD seed, in quotes (asked for when not given)")
+	restoreWalletPassphrase := restoreWalletCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
-	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendAmount := sendCmd.String("amount", "", "Amount to send, ie 1.25 or \"1.25 GWFI\"")
+	sendAsset := sendCmd.String("asset", "", "Symbol or ID of the asset to send (native coin by default)")
+	sendStrategy := sendCmd.String("strategy", "", "Coin selection strategy: largest-first (default), smallest-first, bnb or random-improve")
+	sendFeeRate := sendCmd.Uint64("feerate", uint64(core.DefaultFeeRate), "Fee in base units per byte of transaction")
+	sendReplaceable := sendCmd.Bool("replaceable", false, "Signal replace-by-fee so that the fee can be bumped")
+	sendPassphrase := sendCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
+	sendRPC := sendCmd.String("rpc", "", "Send from the wallet of the running node whose RPC server listens on ADDR")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	importAddressAddress := importAddressCmd.String("address", "", "Address to watch")
+	importAddressRescan := importAddressCmd.Bool("rescan", false, "Rescan the chain for the address")
+	importPrivKeyKey := importPrivKeyCmd.String("key", "", "Private key from dumpprivkey (asked for when not given)")
+	importPrivKeyPassphrase := importPrivKeyCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
+	importPrivKeyRescan := importPrivKeyCmd.Bool("rescan", false, "Rescan the chain for the address of the key")
+	listAddressesBalances := listAddressesCmd.Bool("balances", false, "Show the balance, purpose and label of each address")
+	listTransactionsCount := listTransactionsCmd.Int("count", 0, "Number of transactions to list, newest first (0 lists all)")
+	setLabelAddress := setLabelCmd.String("address", "", "Address to label")
+	setLabelLabel := setLabelCmd.String("label", "", "Label of the address")
+	setMockTimeTime := setMockTimeCmd.Int64("time", 0, "Unix time to stop the clock at (0 lets it run again)")
+	setMockTimeRPC := setMockTimeCmd.String("rpc", "", "Address of the node's RPC server, ie localhost:8333")
+	issueAssetFrom := issueAssetCmd.String("from", "", "Issuer wallet address receiving the supply")
+	issueAssetSymbol := issueAssetCmd.String("symbol", "", "Symbol of the new asset")
+	issueAssetDecimals := issueAssetCmd.Uint("decimals", 0, "Number of decimals of the new asset")
+	issueAssetSupply := issueAssetCmd.String("supply", "", "Total supply of the new asset, ie 1000000.00")
+	issueAssetMine := issueAssetCmd.Bool("mine", false, "Mine immediately on the same node")
+	signPSBTIn := signPSBTCmd.String("in", "", "File of the partially signed transaction")
+	signPSBTOut := signPSBTCmd.String("out", "", "File to write the signed transaction to (the input file by default)")
+	signPSBTPassphrase := signPSBTCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
+	sendRawTxHex := sendRawTxCmd.String("hex", "", "Signed transaction as hex")
+	signRawTxHex := signRawTxCmd.String("hex", "", "Transaction as hex")
+	signRawTxPassphrase := signRawTxCmd.String("passphrase", "", "Passphrase of an encrypted wallet (asked for when not given)")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodeMinInterval := startNodeCmd.Duration("mininterval", config.CoinBlockInterval*time.Second, "Least time between two mined blocks")
+	startNodeMaxEmpty := startNodeCmd.Int("maxempty", 0, "Empty blocks mined in a row while no transactions arrive (-1 for no limit)")
+	startNodeThreads := startNodeCmd.Int("threads", 0, "Mining threads (one per CPU by default)")
+	startNodeOutbound := startNodeCmd.Int("outbound", core.DefaultTargetOutbound, "Outbound peer connections to keep")
+	startNodeMaxInbound := startNodeCmd.Int("maxinbound", core.DefaultMaxInbound, "Inbound peer connections to accept at most")
+	startNodeRPCListen := startNodeCmd.String("rpclisten", core.ActiveParams.DefaultRPCPort, "Serve external miners and wallet commands on ADDR, ie 8333 or localhost:8333 (none when empty)")
+	startNodeRPCAllowUnencrypted := startNodeCmd.Bool("rpcallowunencrypted", false, "Serve wallet commands for an unencrypted wallet")
+	mineNode := mineCmd.String("node", "", "Address of the node's RPC server, ie localhost:8333")
+	mineAddress := mineCmd.String("address", "", "The address to send block rewards to (the node's miner address by default)")
+	mineBlocks := mineCmd.Int("blocks", 0, "Number of blocks to mine (0 mines forever)")
+	var notarizeFiles stringList
+	notarizeCmd.Var(&notarizeFiles, "file", "File to notarize (may be repeated)")
+	notarizeFrom := notarizeCmd.String("from", "", "Wallet address paying for and signing the notarization")
+	notarizeMine := notarizeCmd.Bool("mine", false, "Mine immediately on the same node")
+	walletLockRPC := walletLockCmd.String("rpc", "", "Address of the node's RPC server, ie localhost:8333")
+	walletPassphraseRPC := walletPassphraseCmd.String("rpc", "", "Address of the node's RPC server, ie localhost:8333")
+	walletPassphrasePassphrase := walletPassphraseCmd.String("passphrase", "", "Wallet passphrase (asked for when not given)")
+	walletPassphraseTimeout := walletPassphraseCmd.Duration("timeout", time.Minute, "How long the wallet stays unlocked")
+	verifyNotaryProof := verifyNotaryCmd.String("proof", "", "Notary proof file")
+	verifyNotaryFile := verifyNotaryCmd.String("file", "", "File the proof was made for")
 
 	switch os.Args[1] {
+	case "broadcastpsbt":
+		err := broadcastPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bumpfee":
+		err := bumpFeeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changepassphrase":
+		err := changePassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decoderawtransaction":
+		err := decodeRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		err := generateCmd.Parse(os.Args[2:])
+		if err == nil && generateCmd.NArg() > 0 {
+			//N comes before the options
+			generateBlocks, err = strconv.Atoi(generateCmd.Arg(0))
+			if err == nil {
+				err = generateCmd.Parse(generateCmd.Args()[1:])
+			}
+		}
+		if err != nil {
+			log.Panic(err)
+		}
 	case "getbalance":
 		err := getBalanceCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createblockchain":
 		err := createBlockchainCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
-	//case "createwallet":
-	//	err := createWalletCmd.Parse(os.Args[2:])
-	//	if err != nil {
-	//		log.Panic(err)
-	//	}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createwallet":
-		cli.cmdCreateWallet()
+		err := createWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "issueasset":
+		err := issueAssetCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listassets":
+		err := listAssetsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "listaddresses":
 		err := listAddressesCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "mine":
+		err := mineCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "notarize":
+		err := notarizeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "printchain":
 		err := printChainCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -250,32 +536,149 @@ func (cli *Client) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "sendrawtransaction":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setmocktime":
+		err := setMockTimeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpsbt":
+		err := signPSBTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
 		err := startNodeCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "verifynotary":
+		err := verifyNotaryCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "version":
 		err := versionCmd.Parse(os.Args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	default:
 		cli.printUsage()
 		cli.terminate()
 	}
 
+	if broadcastPSBTCmd.Parsed() {
+		if *broadcastPSBTIn == "" {
+			broadcastPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.BroadcastPSBT(*broadcastPSBTIn, *broadcastPSBTMiner, *broadcastPSBTMine)
+	}
+
+	if bumpFeeCmd.Parsed() {
+		if *bumpFeeTxID == "" {
+			bumpFeeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.BumpFee(*bumpFeeTxID, core.Amount(*bumpFeeRate), *bumpFeeMine)
+	}
+
+	if changePassphraseCmd.Parsed() {
+		cli.ChangePassphrase(*changePassphraseOld, *changePassphraseNew)
+	}
+
+	if combinePSBTCmd.Parsed() {
+		if len(combinePSBTIns) < 2 || *combinePSBTOut == "" {
+			combinePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.CombinePSBT(combinePSBTIns, *combinePSBTOut)
+	}
+
+	if createPSBTCmd.Parsed() {
+		if len(createPSBTFrom) == 0 || *createPSBTTo == "" || *createPSBTAmount == "" || *createPSBTOut == "" {
+			createPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.CreatePSBT(createPSBTFrom, createPSBTPubKeys, *createPSBTTo, *createPSBTAsset, *createPSBTAmount, *createPSBTStrategy, core.Amount(*createPSBTFeeRate), *createPSBTOut)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxHex == "" {
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.DecodeRawTransaction(*decodeRawTxHex)
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.DumpPrivKey(*dumpPrivKeyAddress, *dumpPrivKeyPassphrase)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		cli.EncryptWallet(*encryptWalletPassphrase)
+	}
+
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTIn == "" {
+			finalizePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.FinalizePSBT(*finalizePSBTIn)
+	}
+
+	if generateCmd.Parsed() {
+		if generateBlocks <= 0 {
+			generateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.Generate(generateBlocks, *generateAddress)
+	}
+
 	if getBalanceCmd.Parsed() {
 		if *getBalanceAddress == "" {
-			getBalanceCmd.Usage()
-			os.Exit(1)
+			cli.ShowWalletBalance(*getBalanceAsset)
+		} else {
+			cli.ShowBalance(*getBalanceAddress, *getBalanceAsset)
 		}
-		cli.ShowBalance(*getBalanceAddress)
 	}
 
 	if createBlockchainCmd.Parsed() {
@@ -287,12 +690,64 @@ func (cli *Client) Run() {
 		cli.PopulateWallets(*createBlockchainAddress)
 	}
 
+	if createRawTxCmd.Parsed() {
+		if len(createRawTxInputs) == 0 || len(createRawTxOutputs) == 0 {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.CreateRawTransaction(createRawTxInputs, createRawTxOutputs, *createRawTxReplaceable)
+	}
+
 	if createWalletCmd.Parsed() {
-		cli.CreateWallet()
+		cli.CreateWallet(*createWalletScheme)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.ImportAddress(*importAddressAddress, *importAddressRescan)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		cli.ImportPrivKey(*importPrivKeyKey, *importPrivKeyPassphrase, *importPrivKeyRescan)
+	}
+
+	if issueAssetCmd.Parsed() {
+		if *issueAssetFrom == "" || *issueAssetSymbol == "" || *issueAssetSupply == "" {
+			issueAssetCmd.Usage()
+			os.Exit(1)
+		}
+		cli.IssueAsset(*issueAssetFrom, *issueAssetSymbol, *issueAssetDecimals, *issueAssetSupply, *issueAssetMine)
+	}
+
+	if listAssetsCmd.Parsed() {
+		cli.ListAssets()
 	}
 
 	if listAddressesCmd.Parsed() {
-		cli.ListAddresses()
+		cli.ListAddresses(*listAddressesBalances)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		cli.ListTransactions(*listTransactionsCount)
+	}
+
+	if mineCmd.Parsed() {
+		if *mineNode == "" {
+			mineCmd.Usage()
+			os.Exit(1)
+		}
+		cli.Mine(*mineNode, *mineAddress, *mineBlocks)
+	}
+
+	if notarizeCmd.Parsed() {
+		if *notarizeFrom == "" || len(notarizeFiles) == 0 {
+			notarizeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.Notarize(*notarizeFrom, notarizeFiles, *notarizeMine)
 	}
 
 	if printChainCmd.Parsed() {
@@ -303,21 +758,116 @@ func (cli *Client) Run() {
 		cli.ReIndexUTXO()
 	}
 
+	if restoreWalletCmd.Parsed() {
+		cli.RestoreWallet(*restoreWalletMnemonic, *restoreWalletPassphrase)
+	}
+
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount == "" {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
 
-		cli.Send(*sendFrom, *sendTo, *sendAmount, *sendMine)
+		if *sendRPC != "" {
+			params := core.SendParams{
+				From:        *sendFrom,
+				To:          *sendTo,
+				Asset:       *sendAsset,
+				Amount:      *sendAmount,
+				Strategy:    *sendStrategy,
+				FeeRate:     core.Amount(*sendFeeRate),
+				Replaceable: *sendReplaceable,
+			}
+			cli.SendRPC(*sendRPC, params)
+		} else {
+			cli.Send(*sendFrom, *sendTo, *sendAsset, *sendAmount, *sendStrategy, core.Amount(*sendFeeRate), *sendReplaceable, *sendPassphrase, *sendMine)
+		}
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxHex == "" {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.SendRawTransaction(*sendRawTxHex)
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" {
+			setLabelCmd.Usage()
+			os.Exit(1)
+		}
+		cli.SetLabel(*setLabelAddress, *setLabelLabel)
+	}
+
+	if setMockTimeCmd.Parsed() {
+		if *setMockTimeRPC == "" {
+			setMockTimeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.SetMockTime(*setMockTimeRPC, *setMockTimeTime)
+	}
+
+	if signPSBTCmd.Parsed() {
+		if *signPSBTIn == "" {
+			signPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.SignPSBT(*signPSBTIn, *signPSBTOut, *signPSBTPassphrase)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxHex == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.SignRawTransaction(*signRawTxHex, *signRawTxPassphrase)
 	}
 
 	if startNodeCmd.Parsed() {
-		cli.StartNode(cli.NodePort, *startNodeMiner)
+		minerConfig := core.MinerConfig{
+			Address:        *startNodeMiner,
+			MinInterval:    *startNodeMinInterval,
+			MaxEmptyBlocks: *startNodeMaxEmpty,
+			Threads:        *startNodeThreads,
+		}
+		connConfig := core.ConnConfig{
+			TargetOutbound: *startNodeOutbound,
+			MaxInbound:     *startNodeMaxInbound,
+		}
+		rpcConfig := core.RPCConfig{
+			Listen:                 *startNodeRPCListen,
+			AllowUnencryptedWallet: *startNodeRPCAllowUnencrypted,
+		}
+		cli.StartNode(cli.NodePort, minerConfig, connConfig, rpcConfig)
+	}
+
+	if verifyNotaryCmd.Parsed() {
+		if *verifyNotaryProof == "" {
+			verifyNotaryCmd.Usage()
+			os.Exit(1)
+		}
+		cli.VerifyNotary(*verifyNotaryProof, *verifyNotaryFile)
 	}
 
 	if versionCmd.Parsed() {
 		fmt.Println(config.Version())
 	}
 
+	if walletLockCmd.Parsed() {
+		if *walletLockRPC == "" {
+			walletLockCmd.Usage()
+			os.Exit(1)
+		}
+		cli.WalletLock(*walletLockRPC)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphraseRPC == "" {
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.WalletPassphrase(*walletPassphraseRPC, *walletPassphrasePassphrase, *walletPassphraseTimeout)
+	}
+
 }
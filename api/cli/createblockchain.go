@@ -10,8 +10,8 @@ import (
 //CreateBlockchain creates an new with an associated master wallet
 func (cli *Client) CreateBlockchain(address string) {
 	fmt.Printf("Creating new blockchain with primary wallet address of %s.\n", address)
-	if !core.ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	if err := core.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
 		// TODO: Create Primary Wallet (address)
 		//cli.createWallet(nodeID)
 	}
@@ -2,14 +2,40 @@ package cli
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/NlaakStudios/Blockchain/api/core"
+	"github.com/NlaakStudios/Blockchain/api/hd"
 )
 
-//CreateWallet creates a new wallet address
-func (cli *Client) CreateWallet() {
+//CreateWallet creates a new wallet address using the named signature scheme (p256 by default). Addresses are
+//derived from the HD seed of the wallet file, which is created and shown as a mnemonic the first time.
+func (cli *Client) CreateWallet(scheme string) {
+	sigScheme, err := core.ParseScheme(scheme)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	wallets, _ := core.NewWallets(cli.NodePort)
-	address := wallets.CreateWallet()
+	cli.unlockWallets(wallets, "")
+	if !wallets.IsHD() {
+		mnemonic, err := hd.NewMnemonic(hd.MnemonicEntropy)
+		if err != nil {
+			log.Panic(err)
+		}
+		err = wallets.SetMnemonic(mnemonic)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+
+		fmt.Println("Your wallet file now derives its keys from this mnemonic. Write it down and keep it safe,")
+		fmt.Println("the keys can be restored from it with restorewallet:")
+		fmt.Printf("\n	%s\n\n", mnemonic)
+		if len(wallets.Wallets) > 0 {
+			fmt.Println("Keys created before are not covered by the mnemonic, keep backing up the wallet file for them.")
+		}
+	}
+	address := wallets.CreateWallet(sigScheme)
 	wallets.SaveToFile(cli.NodePort)
 
 	fmt.Printf("Your new address: %s\n", address)
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//Generate asks the running node to mine blocks at once on top of its chain, with the transactions of its mempool,
+//paying the rewards to address or to a new address of the wallet file. The node adds them to its chain and relays
+//them. It is meant for regtest, where blocks are found at once.
+func (cli *Client) Generate(blocks int, address string) {
+	if !core.ActiveParams.AllowMockTime {
+		log.Panic("ERROR: ", core.ErrGenerateNotAllowed)
+	}
+	if address == "" {
+		wallets, _ := core.NewWallets(cli.NodePort)
+		cli.unlockWallets(wallets, "")
+		address = wallets.CreateWallet(core.DefaultScheme)
+		wallets.SaveToFile(cli.NodePort)
+	} else if err := core.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Reward address is not valid: ", err)
+	}
+
+	client := cli.dialRPC(cli.RPCConnect)
+	defer client.Close()
+
+	hashes, err := client.Generate(blocks, address)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	for _, hash := range hashes {
+		fmt.Printf("Mined block %s\n", hash)
+	}
+}
+
+//SetMockTime stops the clock of the running node listening on node at the unix time t, or lets it run again
+//when t is 0
+func (cli *Client) SetMockTime(node string, t int64) {
+	client := cli.dialRPC(node)
+	defer client.Close()
+
+	err := client.SetMockTime(t)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	if t == 0 {
+		fmt.Println("The clock of the node runs again")
+	} else {
+		fmt.Printf("The clock of the node is stopped at %d\n", t)
+	}
+}
@@ -4,51 +4,88 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/NlaakStudios/Blockchain/api/config"
 	"github.com/NlaakStudios/Blockchain/api/core"
-	"github.com/NlaakStudios/Blockchain/api/utils"
 )
 
-//ShowBalance shows the balance of the given wallet in the console
-func (cli *Client) ShowBalance(address string) {
-	if !core.ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+//ShowBalance shows the confirmed balance of the given wallet in the console, and the pending one it will have
+//once the transactions of the mempool are confirmed. An empty asset shows the native coin.
+func (cli *Client) ShowBalance(address, asset string) {
+	if err := core.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
 	}
 	bc := core.NewBlockchain(cli.NodePort)
 	UTXOSet := core.UTXOSet{bc}
 	defer bc.DB.Close()
 
-	balance := 0
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
+	assetID, symbol, decimals := cli.resolveAsset(UTXOSet, asset)
 
-	for _, out := range UTXOs {
-		balance += out.Value
+	_, pubKeyHash, _ := core.DecodeAddress(address)
+	confirmed, pending, err := UTXOSet.Balance(pubKeyHash, assetID, cli.loadMempool(&UTXOSet))
+	if err != nil {
+		log.Panic("ERROR: ", err)
 	}
 
-	fmt.Printf("Balance of '%s': %d %s\n", address, balance, config.CoinSymbol)
+	fmt.Printf("Balance of '%s': %s\n", address, core.FormatAmount(confirmed, decimals, symbol))
+	fmt.Printf("Pending balance: %s\n", core.FormatAmount(pending, decimals, symbol))
 }
 
-// GetBalance given a valid address returns the current balance in coins
-func (cli *Client) GetBalance(address string) int {
+//ShowWalletBalance shows the confirmed and pending balances of all the addresses of the wallet file in the
+//console, those it can spend and the watch-only ones apart. An empty asset shows the native coin.
+func (cli *Client) ShowWalletBalance(asset string) {
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	assetID, symbol, decimals := cli.resolveAsset(UTXOSet, asset)
+	mempool := cli.loadMempool(&UTXOSet)
+	sum := func(addresses []string) (core.Amount, core.Amount) {
+		var confirmed, pending core.Amount
+		for _, address := range addresses {
+			_, pubKeyHash, _ := core.DecodeAddress(address)
+			c, p, err := UTXOSet.Balance(pubKeyHash, assetID, mempool)
+			if err == nil {
+				confirmed, err = confirmed.Add(c)
+			}
+			if err == nil {
+				pending, err = pending.Add(p)
+			}
+			if err != nil {
+				log.Panic("ERROR: ", err)
+			}
+		}
+		return confirmed, pending
+	}
+
+	confirmed, pending := sum(wallets.GetAddresses())
+	fmt.Printf("Wallet balance: %s\n", core.FormatAmount(confirmed, decimals, symbol))
+	fmt.Printf("Pending balance: %s\n", core.FormatAmount(pending, decimals, symbol))
+	if watched := wallets.GetWatchOnly(); len(watched) > 0 {
+		confirmed, pending = sum(watched)
+		fmt.Printf("Watch-only balance: %s\n", core.FormatAmount(confirmed, decimals, symbol))
+		fmt.Printf("Watch-only pending balance: %s\n", core.FormatAmount(pending, decimals, symbol))
+	}
+}
+
+// GetBalance given a valid address returns the current balance in base units of the native coin
+func (cli *Client) GetBalance(address string) core.Amount {
 
 	//fmt.Println("getBalance(%s, %s)", address, nodeID)
-	if !core.ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	if err := core.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
 	}
 
 	bc := core.NewBlockchain(cli.NodePort)
 	UTXOSet := core.UTXOSet{bc}
 	defer bc.DB.Close()
 
-	balance := 0
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
-
-	for _, out := range UTXOs {
-		balance += out.Value
+	_, pubKeyHash, _ := core.DecodeAddress(address)
+	balance, _, err := UTXOSet.Balance(pubKeyHash, nil, nil)
+	if err != nil {
+		log.Panic("ERROR: ", err)
 	}
 
 	//fmt.Printf("Balance of '%s': %d\n", address, balance)
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//DumpPrivKey prints the private key of an address of the wallet file in the text encoding importprivkey reads.
+//An encrypted wallet is unlocked with passphrase, which is asked for when empty.
+func (cli *Client) DumpPrivKey(address, passphrase string) {
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, passphrase)
+	wallet := wallets.GetWallet(address)
+
+	key, err := wallet.ExportKey()
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println(key)
+}
+
+//ImportPrivKey adds a private key exported by dumpprivkey to the wallet file, and rescans the chain for its
+//address when rescan is set. An encrypted wallet is unlocked with passphrase, which is asked for when empty.
+func (cli *Client) ImportPrivKey(key, passphrase string, rescan bool) {
+	if key == "" {
+		key = readPassphrase("Private key: ")
+	}
+	wallet, err := core.ImportKey(key)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	wallets, _ := core.NewWallets(cli.NodePort)
+	cli.unlockWallets(wallets, passphrase)
+	address, err := wallets.ImportWallet(wallet)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.NodePort)
+
+	fmt.Printf("Imported the key of %s\n", address)
+	if rescan {
+		cli.rescanAddress(wallets, address)
+	}
+}
+
+//ImportAddress watches an address whose private key is not in the wallet file: it counts in the balance of the
+//wallet but never signs. The chain is rescanned for it when rescan is set.
+func (cli *Client) ImportAddress(address string, rescan bool) {
+	wallets, _ := core.NewWallets(cli.NodePort)
+	err := wallets.ImportAddress(address)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.NodePort)
+
+	fmt.Printf("Watching %s\n", address)
+	if rescan {
+		cli.rescanAddress(wallets, address)
+	}
+}
+
+//rescanAddress scans the whole chain again for the transactions of the wallet file, so that those of an imported
+//address are recorded, and shows what the address holds
+func (cli *Client) rescanAddress(wallets *core.Wallets, address string) {
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	_, err := wallets.SyncTransactions(cli.NodePort, bc, mempool, true)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	_, pubKeyHash, _ := core.DecodeAddress(address)
+	txs := bc.AddressTransactions(pubKeyHash)
+	confirmed, _, err := UTXOSet.Balance(pubKeyHash, nil, nil)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Printf("Rescan found %d transaction(s) of %s, holding %s\n", len(txs), address, core.FormatAmount(confirmed, config.CoinDecimals, config.CoinSymbol))
+}
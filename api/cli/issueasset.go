@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//IssueAsset issues a new asset with its whole supply credited to the issuing wallet
+func (cli *Client) IssueAsset(from, symbol string, decimals uint, supplyStr string, mineNow bool) {
+	if err := core.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Issuer address is not valid: ", err)
+	}
+
+	supply, err := core.ParseAmount(supplyStr, decimals, symbol)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	if _, err := UTXOSet.FindAsset(symbol); err == nil {
+		log.Panicf("ERROR: Asset %s already exists", symbol)
+	}
+
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, "")
+	wallet := wallets.GetWallet(from)
+
+	mempool := cli.loadMempool(&UTXOSet)
+	tx := core.NewIssuanceTransaction(&wallet, symbol, decimals, supply, &UTXOSet, mempool)
+	cli.submitTx(tx, mempool, &UTXOSet, from, mineNow)
+
+	fmt.Printf("Issued %s with asset ID %x\n", core.FormatAmount(supply, decimals, tx.Issuance.Symbol), tx.Issuance.ID)
+}
+
+//ListAssets shows all assets issued on the chain
+func (cli *Client) ListAssets() {
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	for _, asset := range UTXOSet.ListAssets() {
+		fmt.Printf("%-12s %x supply %s, %d decimals\n", asset.Symbol, asset.ID, core.FormatAmount(asset.Supply, asset.Decimals, asset.Symbol), asset.Decimals)
+	}
+}
+
+//resolveAsset maps a symbol or hex asset ID to the asset ID, symbol and decimals. The native coin has a nil ID.
+func (cli *Client) resolveAsset(UTXOSet core.UTXOSet, asset string) ([]byte, string, uint) {
+	assetID, symbol, decimals, err := UTXOSet.ResolveAsset(asset)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	return assetID, symbol, decimals
+}
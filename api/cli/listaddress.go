@@ -3,19 +3,61 @@ package cli
 import (
 	"fmt"
 	"log"
+	"sort"
 
+	"github.com/NlaakStudios/Blockchain/api/config"
 	"github.com/NlaakStudios/Blockchain/api/core"
 )
 
-//ListAddresses show all addresses to stdout
-func (cli *Client) ListAddresses() {
+//ListAddresses show all addresses to stdout, watch-only ones last. With balances, each comes with its confirmed
+//balance in the native coin, its purpose and its label.
+func (cli *Client) ListAddresses(balances bool) {
 	wallets, err := core.NewWallets(cli.NodePort)
 	if err != nil {
 		log.Panic(err)
 	}
 	addresses := wallets.GetAddresses()
+	watched := wallets.GetWatchOnly()
+	sort.Strings(addresses)
+	sort.Strings(watched)
 
-	for _, address := range addresses {
-		fmt.Println(address)
+	if !balances {
+		for _, address := range addresses {
+			fmt.Println(address)
+		}
+		for _, address := range watched {
+			fmt.Printf("%s (watch-only)\n", address)
+		}
+		return
 	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	for _, address := range append(addresses, watched...) {
+		_, pubKeyHash, _ := core.DecodeAddress(address)
+		confirmed, _, err := UTXOSet.Balance(pubKeyHash, nil, nil)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		purpose := ""
+		if info, ok := wallets.Addresses[address]; ok {
+			purpose = info.Purpose
+		}
+
+		fmt.Printf("%s %s %-10s %q\n", address, core.FormatAmount(confirmed, config.CoinDecimals, config.CoinSymbol), purpose, wallets.Label(address))
+	}
+}
+
+//SetLabel labels an address of the wallet file, or someone else's address to recognize payments to it
+func (cli *Client) SetLabel(address, label string) {
+	wallets, _ := core.NewWallets(cli.NodePort)
+	err := wallets.SetLabel(address, label)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.NodePort)
+
+	fmt.Printf("Labelled %s %q\n", address, label)
 }
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//ListTransactions shows the count latest transactions of the wallet file, newest first, after recording those of
+//the blocks and the mempool it has not seen yet. All are shown when count is 0.
+func (cli *Client) ListTransactions(count int) {
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	_, err = wallets.SyncTransactions(cli.NodePort, bc, mempool, false)
+	if err != nil {
+		log.Panic(err)
+	}
+	wtxs, err := wallets.Transactions(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	history := make(map[string]*core.WalletTx)
+	for _, wtx := range wtxs {
+		history[hex.EncodeToString(wtx.Tx.ID)] = wtx
+	}
+	if count > 0 && len(wtxs) > count {
+		wtxs = wtxs[:count]
+	}
+
+	bestHeight := bc.GetBestHeight()
+	for _, wtx := range wtxs {
+		details := wallets.Details(wtx, history)
+
+		amount := core.FormatAmount(details.Received-details.Sent, config.CoinDecimals, config.CoinSymbol)
+		if details.Sent > details.Received {
+			amount = "-" + core.FormatAmount(details.Sent-details.Received, config.CoinDecimals, config.CoinSymbol)
+		}
+
+		status := fmt.Sprintf("%d confirmations", wtx.Confirmations(bestHeight))
+		if !wtx.IsConfirmed() {
+			status = "unconfirmed"
+			if !mempool.Has(wtx.Tx.ID) {
+				status = "not in mempool"
+			}
+		}
+
+		var labels []string
+		for _, address := range details.Addresses {
+			if label := wallets.Label(address); label != "" {
+				labels = append(labels, label)
+			}
+		}
+		if details.WatchOnly {
+			labels = append(labels, "watch-only")
+		}
+
+		fmt.Printf("%s %x %s (%s)", time.Unix(wtx.Time, 0).Format("2006-01-02 15:04:05"), wtx.Tx.ID, amount, status)
+		if details.Fee > 0 {
+			fmt.Printf(" fee %s", core.FormatAmount(details.Fee, config.CoinDecimals, config.CoinSymbol))
+		}
+		if len(labels) > 0 {
+			fmt.Printf(" [%s]", strings.Join(labels, ", "))
+		}
+		fmt.Println()
+	}
+}
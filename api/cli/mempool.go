@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//loadMempool loads the transactions of the node's mempool that are still valid
+func (cli *Client) loadMempool(UTXOSet *core.UTXOSet) *core.Mempool {
+	mempool, err := core.LoadMempool(cli.NodePort, UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return mempool
+}
+
+//submitTx hands a new transaction to the running node, which adds it to its mempool and relays it. With mineNow, it
+//is instead mined at once together with the transactions it may depend on, rewarding miner; the node drops them from
+//its mempool file when it next loads it.
+func (cli *Client) submitTx(tx *core.Transaction, mempool *core.Mempool, UTXOSet *core.UTXOSet, miner string, mineNow bool) {
+	err := mempool.Add(tx, UTXOSet)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	if mineNow {
+		cbTx := core.NewCoinbaseTX(miner, "", UTXOSet.Blockchain.GetBestHeight()+1)
+		txs := append([]*core.Transaction{cbTx}, mempool.BlockTransactions(core.MaxBlockSize)...)
+
+		newBlock := UTXOSet.Blockchain.MineBlock(txs)
+		UTXOSet.Update(newBlock)
+		return
+	}
+
+	client := cli.dialRPC(cli.RPCConnect)
+	defer client.Close()
+
+	_, err = client.SendRawTransaction(tx)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+}
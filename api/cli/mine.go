@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//mineBatch is the number of nonces tried before asking for a new template
+const mineBatch = 1 << 18
+
+//Mine works for the node whose RPC server listens on node: it mines the block templates it gets,
+//paying the reward to address (or the node's miner address), and submits every block found. It runs
+//until blocks have been found, or forever when blocks is 0.
+func (cli *Client) Mine(node, address string, blocks int) {
+	if address != "" {
+		if err := core.ValidateAddress(address); err != nil {
+			log.Panic("ERROR: Reward address is not valid: ", err)
+		}
+	}
+
+	client := cli.dialRPC(node)
+	defer client.Close()
+
+	lastID, next := "", 0
+	for found := 0; blocks == 0 || found < blocks; {
+		//A fresh template every batch picks up new tips and transactions
+		template, block, err := client.GetBlockTemplate(address)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		if template.ID != lastID {
+			lastID, next = template.ID, 0
+		}
+
+		nonce, _, ok := core.NewProofOfWork(block).Search(next, mineBatch)
+		next += mineBatch
+		if !ok {
+			continue
+		}
+
+		hash, err := client.SubmitBlock(template.ID, nonce)
+		if err != nil {
+			fmt.Printf("Block %d was rejected: %s\n", template.Height, err)
+			continue
+		}
+		found++
+		fmt.Printf("Mined block %d %s with %d transaction(s)\n", template.Height, hash, template.Transactions)
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//Notarize commits the hashes of files to the chain and writes a proof file next to each of them
+func (cli *Client) Notarize(from string, files []string, mineNow bool) {
+	if err := core.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender address is not valid: ", err)
+	}
+
+	var leaves [][]byte
+	for _, file := range files {
+		hash, err := core.HashFile(file)
+		if err != nil {
+			log.Panic(err)
+		}
+		leaves = append(leaves, hash)
+	}
+	root := core.NotaryRoot(leaves)
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, "")
+	wallet := wallets.GetWallet(from)
+
+	mempool := cli.loadMempool(&UTXOSet)
+	tx := core.NewDataTransaction(&wallet, core.NotaryData(root), &UTXOSet, mempool)
+	cli.submitTx(tx, mempool, &UTXOSet, from, mineNow)
+
+	for _, proof := range core.NewNotaryProofs(files, leaves) {
+		proof.TxID = hex.EncodeToString(tx.ID)
+		proofFile := proof.File + ".notary.json"
+		err := proof.Save(proofFile)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Printf("Proof for %s written to %s\n", proof.File, proofFile)
+	}
+
+	fmt.Printf("Notarized %d file(s) with root %x in transaction %x\n", len(files), root, tx.ID)
+}
+
+//VerifyNotary checks a proof file against the chain and, if given, the file it was made for
+func (cli *Client) VerifyNotary(proofFile, file string) {
+	proof, err := core.LoadNotaryProof(proofFile)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if file != "" {
+		hash, err := core.HashFile(file)
+		if err != nil {
+			log.Panic(err)
+		}
+		if hex.EncodeToString(hash) != proof.FileHash {
+			fmt.Printf("FAILED: %s does not match the hash in the proof\n", file)
+			return
+		}
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	defer bc.DB.Close()
+
+	block, err := proof.Verify(bc)
+	if err != nil {
+		fmt.Printf("FAILED: %s\n", err)
+		return
+	}
+
+	fmt.Printf("OK: %s (%s) was notarized in block %x at height %d on %s\n",
+		proof.File, proof.FileHash, block.Hash, block.Height, time.Unix(block.Timestamp, 0).UTC())
+}
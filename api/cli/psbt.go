@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//CreatePSBT writes to out an unsigned transaction sending an amount of an asset from the outputs of one or more
+//addresses to another. Only their public keys are needed: those of the wallet file, those given in hex in pubKeys,
+//or those seen on the chain. Change goes back to the first address.
+func (cli *Client) CreatePSBT(from, pubKeys []string, to, asset, amountStr, strategyName string, feeRate core.Amount, out string) {
+	if err := core.ValidateAddress(to); err != nil {
+		log.Panic("ERROR: Recipient address is not valid: ", err)
+	}
+	strategy, err := coinselect.Get(strategyName)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	var keys [][]byte
+	for _, pubKey := range pubKeys {
+		key, err := hex.DecodeString(pubKey)
+		if err != nil {
+			log.Panic("ERROR: Public key is not valid hex")
+		}
+		keys = append(keys, key)
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	wallets, _ := core.NewWallets(cli.NodePort)
+	var owners []*core.Wallet
+	for _, address := range from {
+		wallet, err := wallets.PublicWallet(address, keys, bc)
+		if err != nil {
+			log.Panicf("ERROR: %s: %s", address, err)
+		}
+		owners = append(owners, wallet)
+	}
+
+	assetID, symbol, decimals := cli.resolveAsset(UTXOSet, asset)
+	amount, err := core.ParseAmount(amountStr, decimals, symbol)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	mempool := cli.loadMempool(&UTXOSet)
+	psbt := core.NewPSBT(owners, to, "", assetID, amount, strategy, feeRate, &UTXOSet, mempool)
+
+	describePSBT(psbt)
+	writePSBT(psbt, out)
+}
+
+//SignPSBT signs the inputs of a partially signed transaction whose keys are in the wallet file, without opening
+//the chain, and writes it to out, in place by default. An encrypted wallet is unlocked with passphrase, which is
+//asked for when empty.
+func (cli *Client) SignPSBT(in, out, passphrase string) {
+	psbt := readPSBT(in)
+	describePSBT(psbt)
+
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, passphrase)
+
+	signed, err := psbt.Sign(wallets)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	if out == "" {
+		out = in
+	}
+
+	fmt.Printf("Signed %d input(s)\n", signed)
+	writePSBT(psbt, out)
+}
+
+//CombinePSBT merges the signatures of copies of the same partially signed transaction signed by different wallets
+func (cli *Client) CombinePSBT(ins []string, out string) {
+	psbt := readPSBT(ins[0])
+	for _, in := range ins[1:] {
+		err := psbt.Combine(readPSBT(in))
+		if err != nil {
+			log.Panicf("ERROR: %s: %s", in, err)
+		}
+	}
+
+	describePSBT(psbt)
+	writePSBT(psbt, out)
+}
+
+//FinalizePSBT checks the signatures of a fully signed transaction and prints it as hex
+func (cli *Client) FinalizePSBT(in string) {
+	tx, err := readPSBT(in).Finalize()
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println(core.EncodeRawTransaction(tx))
+}
+
+//BroadcastPSBT finalizes a fully signed transaction and submits it like send does, mining it at once when mineNow
+//is set, rewarding miner
+func (cli *Client) BroadcastPSBT(in, miner string, mineNow bool) {
+	tx, err := readPSBT(in).Finalize()
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	if mineNow {
+		if err := core.ValidateAddress(miner); err != nil {
+			log.Panic("ERROR: Miner address is not valid: ", err)
+		}
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	cli.submitTx(tx, mempool, &UTXOSet, miner, mineNow)
+
+	fmt.Printf("Success! Transaction %x\n", tx.ID)
+}
+
+//describePSBT shows what a partially signed transaction pays, so that it can be checked before signing
+func describePSBT(psbt *core.PSBT) {
+	signed := 0
+	for _, vin := range psbt.Tx.Vin {
+		if vin.Signature != nil {
+			signed++
+		}
+	}
+	fmt.Printf("Transaction %x, %d of %d input(s) signed, fee %s\n", psbt.Tx.ID, signed, len(psbt.Tx.Vin), core.FormatAmount(psbt.Fee(), config.CoinDecimals, config.CoinSymbol))
+
+	for i, out := range psbt.Tx.Vout {
+		if out.Asset != nil {
+			fmt.Printf("	Output %d: %d units of asset %x to %x\n", i, uint64(out.Value), out.Asset, out.PubKeyHash)
+			continue
+		}
+		fmt.Printf("	Output %d: %s to %x\n", i, core.FormatAmount(out.Value, config.CoinDecimals, config.CoinSymbol), out.PubKeyHash)
+	}
+}
+
+//readPSBT reads a partially signed transaction from a file
+func readPSBT(file string) *core.PSBT {
+	text, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	psbt, err := core.DecodePSBT(string(text))
+	if err != nil {
+		log.Panicf("ERROR: %s: %s", file, err)
+	}
+
+	return psbt
+}
+
+//writePSBT writes a partially signed transaction to a file
+func writePSBT(psbt *core.PSBT, file string) {
+	err := ioutil.WriteFile(file, []byte(psbt.Encode()+"\n"), 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Wrote %s\n", file)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//CreateRawTransaction prints as hex an unsigned transaction spending the txid:vout inputs into the outputs, written
+//address:amount for native coins, address:amount:asset for an asset given by symbol or ID, or data:hex. Nothing is
+//added: what the inputs hold beyond the native outputs is the fee. Inputs signal replace-by-fee when replaceable.
+func (cli *Client) CreateRawTransaction(inputs, outputs []string, replaceable bool) {
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	var vout []core.TXOutput
+	for _, output := range outputs {
+		vout = append(vout, cli.parseRawOutput(UTXOSet, output))
+	}
+
+	wallets, _ := core.NewWallets(cli.NodePort)
+	mempool := cli.loadMempool(&UTXOSet)
+	tx, err := core.NewRawTransaction(inputs, vout, replaceable, wallets, &UTXOSet, mempool)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println(core.EncodeRawTransaction(tx))
+}
+
+//DecodeRawTransaction shows a transaction given as hex as JSON, with the fee when the outputs it spends are known
+func (cli *Client) DecodeRawTransaction(rawHex string) {
+	tx, err := core.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	content, err := json.MarshalIndent(tx.View(&UTXOSet, mempool), "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(content))
+}
+
+//SignRawTransaction signs the inputs of a transaction given as hex whose keys are in the wallet file and prints it
+//as hex. An encrypted wallet is unlocked with passphrase, which is asked for when empty.
+func (cli *Client) SignRawTransaction(rawHex, passphrase string) {
+	tx, err := core.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets, passphrase)
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	mempool := cli.loadMempool(&UTXOSet)
+	signed, err := wallets.SignTransaction(tx, &UTXOSet, mempool)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	unsigned := 0
+	for _, vin := range tx.Vin {
+		if vin.Signature == nil {
+			unsigned++
+		}
+	}
+	fmt.Printf("Signed %d input(s), %d left unsigned\n", signed, unsigned)
+	fmt.Println(core.EncodeRawTransaction(tx))
+}
+
+//SendRawTransaction hands a signed transaction given as hex to the running node, which adds it to its mempool and
+//relays it
+func (cli *Client) SendRawTransaction(rawHex string) {
+	tx, err := core.DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	client := cli.dialRPC(cli.RPCConnect)
+	defer client.Close()
+
+	txID, err := client.SendRawTransaction(tx)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println(txID)
+}
+
+//parseRawOutput parses an output of createrawtransaction
+func (cli *Client) parseRawOutput(UTXOSet core.UTXOSet, output string) core.TXOutput {
+	parts := strings.SplitN(output, ":", 3)
+	if len(parts) < 2 {
+		log.Panicf("ERROR: Output %q is not address:amount", output)
+	}
+
+	if parts[0] == "data" {
+		data, err := hex.DecodeString(parts[1])
+		if err != nil || len(parts) > 2 {
+			log.Panicf("ERROR: Output %q is not data:hex", output)
+		}
+		return *core.NewDataOutput(data)
+	}
+
+	if err := core.ValidateAddress(parts[0]); err != nil {
+		log.Panicf("ERROR: Output %q has an invalid address: %s", output, err)
+	}
+	asset := ""
+	if len(parts) == 3 {
+		asset = parts[2]
+	}
+	assetID, symbol, decimals := cli.resolveAsset(UTXOSet, asset)
+	amount, err := core.ParseAmount(parts[1], decimals, symbol)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	return *core.NewAssetTXOutput(amount, assetID, parts[0])
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+	"github.com/NlaakStudios/Blockchain/api/hd"
+)
+
+//RestoreWallet restores the HD seed of a mnemonic into the wallet file and rescans the chain for the addresses
+//it derives, adding every used one and its transactions. An encrypted wallet is unlocked with passphrase, which is asked for when empty.
+func (cli *Client) RestoreWallet(mnemonic, passphrase string) {
+	if mnemonic == "" {
+		mnemonic = readPassphrase("Mnemonic: ")
+	}
+
+	wallets, _ := core.NewWallets(cli.NodePort)
+	cli.unlockWallets(wallets, passphrase)
+	err := wallets.SetMnemonic(mnemonic)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	bc := core.NewBlockchain(cli.NodePort)
+	UTXOSet := core.UTXOSet{bc}
+	defer bc.DB.Close()
+
+	found, err := wallets.Discover(bc.UsedPubKeyHashes())
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.NodePort)
+
+	_, err = wallets.SyncTransactions(cli.NodePort, bc, cli.loadMempool(&UTXOSet), true)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var restored []*core.Wallet
+	for _, w := range wallets.Wallets {
+		if w.Path != "" {
+			restored = append(restored, w)
+		}
+	}
+	sort.Slice(restored, func(i, j int) bool {
+		a, _ := hd.ParsePath(restored[i].Path)
+		b, _ := hd.ParsePath(restored[j].Path)
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	fmt.Printf("Found %d new used address(es), the wallet file holds %d address(es) of the seed:\n", found, len(restored))
+	for _, w := range restored {
+		fmt.Printf("%s %s\n", w.GetAddress(), w.Path)
+	}
+}
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//dialRPC connects to the RPC server of the running node listening on address and authenticates with the
+//cookie the node wrote to its data directory
+func (cli *Client) dialRPC(address string) *core.RPCClient {
+	cookie, err := core.ReadRPCCookie(cli.NodePort)
+	if err != nil {
+		log.Panic("ERROR: Could not read the RPC cookie of the node: ", err)
+	}
+
+	client, err := core.DialRPC(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = client.Authenticate(cookie)
+	if err != nil {
+		client.Close()
+		log.Panic("ERROR: ", err)
+	}
+
+	return client
+}
@@ -4,16 +4,25 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+	"github.com/NlaakStudios/Blockchain/api/config"
 	"github.com/NlaakStudios/Blockchain/api/core"
 )
 
-//Send sends an amount from one wallet to another
-func (cli *Client) Send(from, to string, amount int, mineNow bool) {
-	if !core.ValidateAddress(from) {
-		log.Panic("ERROR: Sender address is not valid")
+//Send sends an amount of an asset from one wallet to another. An empty asset sends the native coin.
+//Coins are chosen with the named coin selection strategy and pay feeRate base units per byte. A replaceable
+//transaction signals replace-by-fee so that bumpfee can raise its fee. An encrypted wallet is unlocked with
+//passphrase, which is asked for when empty.
+func (cli *Client) Send(from, to, asset, amountStr, strategyName string, feeRate core.Amount, replaceable bool, passphrase string, mineNow bool) {
+	if err := core.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender address is not valid: ", err)
 	}
-	if !core.ValidateAddress(to) {
-		log.Panic("ERROR: Recipient address is not valid")
+	if err := core.ValidateAddress(to); err != nil {
+		log.Panic("ERROR: Recipient address is not valid: ", err)
+	}
+	strategy, err := coinselect.Get(strategyName)
+	if err != nil {
+		log.Panic("ERROR: ", err)
 	}
 
 	bc := core.NewBlockchain(cli.NodePort)
@@ -24,31 +33,47 @@ func (cli *Client) Send(from, to string, amount int, mineNow bool) {
 	if err != nil {
 		log.Panic(err)
 	}
+	cli.unlockWallets(wallets, passphrase)
 	wallet := wallets.GetWallet(from)
+	assetID, symbol, decimals := cli.resolveAsset(UTXOSet, asset)
+	amount, err := core.ParseAmount(amountStr, decimals, symbol)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
 
 	//TODO: See if wallet has enough to send amount
 
-	tx := core.NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	mempool := cli.loadMempool(&UTXOSet)
+	change := wallets.ChangeAddress(wallet.Scheme)
+	tx := core.NewUTXOTransaction(&wallet, to, change, assetID, amount, strategy, feeRate, replaceable, &UTXOSet, mempool)
+	cli.submitTx(tx, mempool, &UTXOSet, from, mineNow)
+	if change != "" {
+		wallets.SaveToFile(cli.NodePort)
+	}
+
+	fmt.Println("Success!")
+}
 
-	if mineNow {
-		cbTx := core.NewCoinbaseTX(from, "")
-		txs := []*core.Transaction{cbTx, tx}
+//SendRPC sends through the running node whose RPC server listens on rpc, from one of its wallets,
+//which must be unlocked with walletpassphrase when encrypted
+func (cli *Client) SendRPC(rpc string, params core.SendParams) {
+	client := cli.dialRPC(rpc)
+	defer client.Close()
 
-		newBlock := bc.MineBlock(txs)
-		UTXOSet.Update(newBlock)
-	} else {
-		core.SendTx(core.KnownNodes[0], tx)
+	txID, err := client.Send(params)
+	if err != nil {
+		log.Panic("ERROR: ", err)
 	}
 
-	fmt.Println("Success!")
+	fmt.Printf("Success! Transaction %s\n", txID)
 }
 
 //PopulateWallets sends funds from the Master wallet to the other wallets
 func (cli *Client) PopulateWallets(from string) {
 
 	fmt.Printf("Creating core wallets and funding them from wallet %s.\n", from)
-	if !core.ValidateAddress(from) {
-		log.Panic("ERROR: Sender address is not valid")
+	if err := core.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender address is not valid: ", err)
 	}
 
 	//Open Wallets file
@@ -57,13 +82,14 @@ func (cli *Client) PopulateWallets(from string) {
 	if err != nil {
 		log.Panic(err)
 	}
+	cli.unlockWallets(wallets, "")
 
 	//Get primary blockchain wallet (with all coins)
 	fmt.Printf("Accessing wallet %s\n", from)
 	wallet := wallets.GetWallet(from)
 	fmt.Printf("Getting balance from wallet %s\n", from)
 	balance := cli.GetBalance(from)
-	fmt.Printf("Your Primary Blockchain wallet balance is %d\n", balance)
+	fmt.Printf("Your Primary Blockchain wallet balance is %s\n", balance)
 
 	//Open the blockchain
 	fmt.Println("Opening blockchain.")
@@ -73,19 +99,19 @@ func (cli *Client) PopulateWallets(from string) {
 
 	//Create our core wallets (In-House)
 	fmt.Println("Creating ICO Wallet.")
-	addressICO := wallets.CreateWallet() //Create ICO Wallet
+	addressICO := wallets.CreateWallet(core.DefaultScheme) //Create ICO Wallet
 	fmt.Printf("Your new ICO Wallet Address: %s\n", addressICO)
 
 	fmt.Println("Creating DEV Wallet.")
-	addressDEV := wallets.CreateWallet() //Create DEV Wallet
+	addressDEV := wallets.CreateWallet(core.DefaultScheme) //Create DEV Wallet
 	fmt.Printf("Your new DEV Wallet Address: %s\n", addressDEV)
 
 	fmt.Println("Creating OAM Wallet.")
-	addressOAM := wallets.CreateWallet() //Create Operations & Marketing Wallet
+	addressOAM := wallets.CreateWallet(core.DefaultScheme) //Create Operations & Marketing Wallet
 	fmt.Printf("Your new OAM Wallet Address: %s\n", addressOAM)
 
 	fmt.Println("Creating PLT Wallet.")
-	addressPLT := wallets.CreateWallet() //Create Platform Wallet
+	addressPLT := wallets.CreateWallet(core.DefaultScheme) //Create Platform Wallet
 	fmt.Printf("Your new PLT Wallet Address: %s\n", addressPLT)
 
 	//Save Wallet file
@@ -95,33 +121,43 @@ func (cli *Client) PopulateWallets(from string) {
 
 	//TODO: See if wallet has enough to send amount
 	fmt.Println("Creating Wallet Transactions.")
-	tx := core.NewUTXOTransaction(&wallet, addressICO, 10000000, &UTXOSet)
+	tx := core.NewUTXOTransaction(&wallet, addressICO, "", nil, coins(config.CoinICOSupply), coinselect.LargestFirst, core.DefaultFeeRate, false, &UTXOSet, nil)
 	//mine Now
-	cbTx := core.NewCoinbaseTX(from, "")
+	cbTx := core.NewCoinbaseTX(from, "", bc.GetBestHeight()+1)
 	txs := []*core.Transaction{cbTx, tx}
 	newBlock := bc.MineBlock(txs)
 	UTXOSet.Update(newBlock)
 
-	tx = core.NewUTXOTransaction(&wallet, addressDEV, 500000, &UTXOSet)
+	tx = core.NewUTXOTransaction(&wallet, addressDEV, "", nil, coins(config.CoinDevSupply), coinselect.LargestFirst, core.DefaultFeeRate, false, &UTXOSet, nil)
 	//mine Now
-	cbTx = core.NewCoinbaseTX(from, "")
+	cbTx = core.NewCoinbaseTX(from, "", bc.GetBestHeight()+1)
 	txs = []*core.Transaction{cbTx, tx}
 	newBlock = bc.MineBlock(txs)
 	UTXOSet.Update(newBlock)
 
-	tx = core.NewUTXOTransaction(&wallet, addressOAM, 1500000, &UTXOSet)
+	tx = core.NewUTXOTransaction(&wallet, addressOAM, "", nil, coins(config.CoinOAMSupply), coinselect.LargestFirst, core.DefaultFeeRate, false, &UTXOSet, nil)
 	//mine Now
-	cbTx = core.NewCoinbaseTX(from, "")
+	cbTx = core.NewCoinbaseTX(from, "", bc.GetBestHeight()+1)
 	txs = []*core.Transaction{cbTx, tx}
 	newBlock = bc.MineBlock(txs)
 	UTXOSet.Update(newBlock)
 
-	tx = core.NewUTXOTransaction(&wallet, addressPLT, 3000000, &UTXOSet)
+	tx = core.NewUTXOTransaction(&wallet, addressPLT, "", nil, coins(config.CoinPLTSupply), coinselect.LargestFirst, core.DefaultFeeRate, false, &UTXOSet, nil)
 	//mine Now
-	cbTx = core.NewCoinbaseTX(from, "")
+	cbTx = core.NewCoinbaseTX(from, "", bc.GetBestHeight()+1)
 	txs = []*core.Transaction{cbTx, tx}
 	newBlock = bc.MineBlock(txs)
 	UTXOSet.Update(newBlock)
 
 	fmt.Println("Success, Blockchain and Core Wallets initialized.")
 }
+
+//coins converts whole coins to base units
+func coins(n uint64) core.Amount {
+	amount, err := core.Amount(n).Mul(uint64(core.CoinUnit))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return amount
+}
@@ -7,19 +7,21 @@ import (
 	"github.com/NlaakStudios/Blockchain/api/core"
 )
 
-//StartNode start a new node with miner and listens on designated port
-func (cli *Client) StartNode(nodeID, minerAddress string) {
+//StartNode start a new node with miner and listens on designated port. It keeps as many peer connections
+//as connConfig asks for. With a miner address, the node mines in the background as configured. With
+//a listen address in rpcConfig, external miners and wallet commands can reach the node through that address.
+func (cli *Client) StartNode(nodeID string, minerConfig core.MinerConfig, connConfig core.ConnConfig, rpcConfig core.RPCConfig) {
+	minerAddress := minerConfig.Address
 
 	cli.NodePort = nodeID
 	fmt.Printf("Starting node on port %s...", cli.NodePort)
 	fmt.Printf("Miner address detected. ")
 	if len(minerAddress) > 0 {
-		if core.ValidateAddress(minerAddress) {
-			fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
-		} else {
-			log.Panic("Failed: Wrong miner address!")
+		if err := core.ValidateAddress(minerAddress); err != nil {
+			log.Panic("Failed: Wrong miner address! ", err)
 		}
+		fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
 	}
-	core.StartServer(cli.NodePort, minerAddress)
+	core.StartServer(cli.NodePort, minerConfig, connConfig, rpcConfig)
 	fmt.Printf("Success.\n")
 }
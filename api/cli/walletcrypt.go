@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/core"
+)
+
+//readPassphrase asks for a passphrase on the standard input
+func readPassphrase(prompt string) string {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Panic(err)
+	}
+
+	return strings.TrimRight(line, "\r\n")
+}
+
+//unlockWallets decrypts the private keys of encrypted wallets with the passphrase, asking for it when empty
+func (cli *Client) unlockWallets(wallets *core.Wallets, passphrase string) {
+	if !wallets.IsEncrypted() {
+		return
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+	err := wallets.Unlock(passphrase)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+}
+
+//EncryptWallet encrypts the private keys of the wallet file with a passphrase
+func (cli *Client) EncryptWallet(passphrase string) {
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("New wallet passphrase: ")
+		if readPassphrase("Repeat the passphrase: ") != passphrase {
+			log.Panic("ERROR: The passphrases do not match")
+		}
+	}
+	if passphrase == "" {
+		log.Panic("ERROR: The passphrase must not be empty")
+	}
+
+	err = wallets.Encrypt(passphrase)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.ReplaceFile(cli.NodePort)
+
+	fmt.Println("Wallet encrypted. Keep the passphrase safe, the keys cannot be recovered without it.")
+}
+
+//ChangePassphrase encrypts the wallet file with a new passphrase
+func (cli *Client) ChangePassphrase(oldPassphrase, newPassphrase string) {
+	wallets, err := core.NewWallets(cli.NodePort)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if oldPassphrase == "" {
+		oldPassphrase = readPassphrase("Current wallet passphrase: ")
+	}
+	if newPassphrase == "" {
+		newPassphrase = readPassphrase("New wallet passphrase: ")
+	}
+	if newPassphrase == "" {
+		log.Panic("ERROR: The passphrase must not be empty")
+	}
+
+	err = wallets.ChangePassphrase(oldPassphrase, newPassphrase)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.NodePort)
+
+	fmt.Println("Wallet passphrase changed.")
+}
+
+//WalletPassphrase unlocks the wallet of the running node whose RPC server listens on rpc, for timeout
+func (cli *Client) WalletPassphrase(rpc, passphrase string, timeout time.Duration) {
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+
+	client := cli.dialRPC(rpc)
+	defer client.Close()
+
+	err := client.WalletPassphrase(passphrase, timeout)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Printf("Wallet unlocked for %s\n", timeout)
+}
+
+//WalletLock locks the wallet of the running node whose RPC server listens on rpc
+func (cli *Client) WalletLock(rpc string) {
+	client := cli.dialRPC(rpc)
+	defer client.Close()
+
+	err := client.WalletLock()
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println("Wallet locked")
+}
@@ -0,0 +1,276 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInsufficientFunds is returned when the coins cannot pay the target and the fee
+var ErrInsufficientFunds = errors.New("Not enough funds")
+
+// ErrNoExactMatch is returned by BranchAndBound when no set of coins pays the
+// target without change
+var ErrNoExactMatch = errors.New("No exact match")
+
+// Coin is an unspent output that can be spent by the sender
+type Coin struct {
+	TxID  string
+	Vout  int
+	Value uint64
+}
+
+// Params describes the payment coins are selected for. Sizes are in bytes of
+// serialized transaction, the fee rate in base units per byte.
+type Params struct {
+	Target     uint64
+	FeeRate    uint64
+	BaseSize   int // transaction without inputs or change
+	InputSize  int // each input
+	ChangeSize int // the change output
+	DustLimit  uint64
+	Rand       *rand.Rand // used by RandomImprove, seeded from the clock when nil
+}
+
+// Selection is the outcome of coin selection. Change is zero when the
+// transaction has no change output; whatever is left goes to the fee.
+type Selection struct {
+	Coins  []Coin
+	Fee    uint64
+	Change uint64
+}
+
+// Strategy selects coins paying p.Target plus the fee
+type Strategy func(coins []Coin, p Params) (*Selection, error)
+
+// Strategies are the strategies selectable by name
+var Strategies = map[string]Strategy{
+	"largest-first":  LargestFirst,
+	"smallest-first": SmallestFirst,
+	"bnb":            BranchAndBound,
+	"random-improve": RandomImprove,
+}
+
+// DefaultStrategy is used when no strategy is chosen
+const DefaultStrategy = "largest-first"
+
+// Get returns the strategy with the given name. An empty name gives the default.
+func Get(name string) (Strategy, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+
+	strategy, ok := Strategies[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for n := range Strategies {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("Unknown coin selection strategy %q (use %s)", name, strings.Join(names, ", "))
+	}
+
+	return strategy, nil
+}
+
+// Fee returns the fee of a transaction spending n inputs
+func (p Params) Fee(n int, change bool) uint64 {
+	size := p.BaseSize + n*p.InputSize
+	if change {
+		size += p.ChangeSize
+	}
+
+	return uint64(size) * p.FeeRate
+}
+
+// effectiveValue is what a coin adds after paying for its own input
+func (p Params) effectiveValue(c Coin) int64 {
+	return int64(c.Value) - int64(uint64(p.InputSize)*p.FeeRate)
+}
+
+// spendable drops coins that cost more to spend than they are worth
+func (p Params) spendable(coins []Coin) []Coin {
+	var result []Coin
+	for _, c := range coins {
+		if p.effectiveValue(c) > 0 {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// finish computes the fee and change of a set of coins, or returns nil when
+// they do not cover the target. Change below the dust limit is left to the fee.
+func (p Params) finish(coins []Coin) *Selection {
+	var total uint64
+	for _, c := range coins {
+		total += c.Value
+	}
+
+	fee := p.Fee(len(coins), false)
+	if total < p.Target+fee {
+		return nil
+	}
+
+	withChange := p.Fee(len(coins), true)
+	if total >= p.Target+withChange+p.DustLimit && total-p.Target-withChange > 0 {
+		return &Selection{coins, withChange, total - p.Target - withChange}
+	}
+
+	return &Selection{coins, total - p.Target, 0}
+}
+
+// accumulate takes coins in order until they pay the target and the fee
+func accumulate(coins []Coin, p Params) (*Selection, error) {
+	var selected []Coin
+	for _, c := range coins {
+		selected = append(selected, c)
+		if s := p.finish(selected); s != nil {
+			return s, nil
+		}
+	}
+
+	return nil, ErrInsufficientFunds
+}
+
+// LargestFirst spends the largest coins first, keeping transactions small
+func LargestFirst(coins []Coin, p Params) (*Selection, error) {
+	sorted := p.spendable(coins)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value > sorted[j].Value })
+
+	return accumulate(sorted, p)
+}
+
+// SmallestFirst spends the smallest coins first, consolidating the UTXO set
+func SmallestFirst(coins []Coin, p Params) (*Selection, error) {
+	sorted := p.spendable(coins)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+
+	return accumulate(sorted, p)
+}
+
+// bnbMaxTries bounds the branch and bound search
+const bnbMaxTries = 100000
+
+// BranchAndBound searches for coins paying the target without a change
+// output, wasting at most the cost of creating change. When there is no
+// such set it falls back to LargestFirst.
+func BranchAndBound(coins []Coin, p Params) (*Selection, error) {
+	s, err := branchAndBound(coins, p)
+	if err == ErrNoExactMatch {
+		return LargestFirst(coins, p)
+	}
+
+	return s, err
+}
+
+func branchAndBound(coins []Coin, p Params) (*Selection, error) {
+	sorted := p.spendable(coins)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value > sorted[j].Value })
+
+	target := int64(p.Target + p.Fee(0, false))
+	upper := target + int64(p.Fee(0, true)-p.Fee(0, false)+p.DustLimit)
+
+	var available int64
+	for _, c := range sorted {
+		available += p.effectiveValue(c)
+	}
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	var best []bool
+	bestWaste := int64(-1)
+	current := make([]bool, len(sorted))
+	tries := 0
+
+	var search func(depth int, value, remaining int64)
+	search = func(depth int, value, remaining int64) {
+		tries++
+		if tries > bnbMaxTries || value > upper || value+remaining < target {
+			return
+		}
+		if value >= target {
+			if waste := value - target; bestWaste < 0 || waste < bestWaste {
+				bestWaste = waste
+				best = append([]bool{}, current...)
+			}
+			return
+		}
+		if depth == len(sorted) {
+			return
+		}
+
+		ev := p.effectiveValue(sorted[depth])
+		current[depth] = true
+		search(depth+1, value+ev, remaining-ev)
+		current[depth] = false
+		search(depth+1, value, remaining-ev)
+	}
+	search(0, 0, available)
+
+	if best == nil {
+		return nil, ErrNoExactMatch
+	}
+
+	var selected []Coin
+	for i, in := range best {
+		if in {
+			selected = append(selected, sorted[i])
+		}
+	}
+
+	var total uint64
+	for _, c := range selected {
+		total += c.Value
+	}
+
+	return &Selection{selected, total - p.Target, 0}, nil
+}
+
+// RandomImprove picks random coins until the target is paid, then keeps
+// adding random coins while they bring the change closer to the target
+// without exceeding twice it. Change of a similar size to the payment
+// makes it harder to tell which output is which.
+func RandomImprove(coins []Coin, p Params) (*Selection, error) {
+	r := p.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	shuffled := p.spendable(coins)
+	r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	var selected []Coin
+	var s *Selection
+	i := 0
+	for ; i < len(shuffled) && s == nil; i++ {
+		selected = append(selected, shuffled[i])
+		s = p.finish(selected)
+	}
+	if s == nil {
+		return nil, ErrInsufficientFunds
+	}
+
+	distance := func(sel *Selection) uint64 {
+		if sel.Change > p.Target {
+			return sel.Change - p.Target
+		}
+		return p.Target - sel.Change
+	}
+
+	for ; i < len(shuffled); i++ {
+		candidate := p.finish(append(append([]Coin{}, selected...), shuffled[i]))
+		if candidate.Change > 2*p.Target || distance(candidate) >= distance(s) {
+			continue
+		}
+		selected = candidate.Coins
+		s = candidate
+	}
+
+	return s, nil
+}
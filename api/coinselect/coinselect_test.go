@@ -0,0 +1,197 @@
+package coinselect
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testParams pays 1 base unit per byte: 10 for the transaction, 100 per
+// input and 30 for the change output, which must be worth at least 50
+func testParams(target uint64) Params {
+	return Params{
+		Target:     target,
+		FeeRate:    1,
+		BaseSize:   10,
+		InputSize:  100,
+		ChangeSize: 30,
+		DustLimit:  50,
+		Rand:       rand.New(rand.NewSource(1)),
+	}
+}
+
+func coins(values ...uint64) []Coin {
+	var result []Coin
+	for i, value := range values {
+		result = append(result, Coin{TxID: "tx", Vout: i, Value: value})
+	}
+
+	return result
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies []string // all of them when empty
+		coins      []Coin
+		target     uint64
+		err        error
+		inputs     int
+		fee        uint64
+		change     uint64
+	}{
+		{
+			name:   "exact match",
+			coins:  coins(1110),
+			target: 1000,
+			inputs: 1,
+			fee:    110,
+		},
+		{
+			name:   "insufficient funds",
+			coins:  coins(500, 400),
+			target: 1000,
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "coin worth less than its input is not spent",
+			coins:  coins(1000, 90),
+			target: 900,
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "dust change dropped",
+			coins:  coins(1150),
+			target: 1000,
+			inputs: 1,
+			fee:    150,
+		},
+		{
+			name:   "change kept",
+			coins:  coins(1200),
+			target: 1000,
+			inputs: 1,
+			fee:    140,
+			change: 60,
+		},
+		{
+			name:   "fee of a second input is not covered",
+			coins:  coins(600, 600),
+			target: 1000,
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "fee grows with the inputs",
+			coins:  coins(700, 700),
+			target: 1000,
+			inputs: 2,
+			fee:    240,
+			change: 160,
+		},
+		{
+			name:       "largest coin first",
+			strategies: []string{"largest-first"},
+			coins:      coins(710, 3000, 510),
+			target:     1000,
+			inputs:     1,
+			fee:        140,
+			change:     1860,
+		},
+		{
+			name:       "smallest coins first",
+			strategies: []string{"smallest-first"},
+			coins:      coins(3000, 710, 510),
+			target:     1000,
+			inputs:     2,
+			fee:        220,
+		},
+		{
+			name:       "branch and bound avoids change",
+			strategies: []string{"bnb"},
+			coins:      coins(3000, 710, 510),
+			target:     1000,
+			inputs:     2,
+			fee:        220,
+		},
+		{
+			name:       "branch and bound falls back to largest first",
+			strategies: []string{"bnb"},
+			coins:      coins(700, 3000),
+			target:     1000,
+			inputs:     1,
+			fee:        140,
+			change:     1860,
+		},
+	}
+
+	for _, test := range tests {
+		strategies := test.strategies
+		if len(strategies) == 0 {
+			strategies = []string{"largest-first", "smallest-first", "bnb", "random-improve"}
+		}
+
+		for _, name := range strategies {
+			strategy, err := Get(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := strategy(test.coins, testParams(test.target))
+			if err != test.err {
+				t.Errorf("%s, %s: got error %v, want %v", test.name, name, err, test.err)
+				continue
+			}
+			if err != nil {
+				continue
+			}
+
+			var total uint64
+			for _, c := range s.Coins {
+				total += c.Value
+			}
+			if len(s.Coins) != test.inputs || s.Fee != test.fee || s.Change != test.change {
+				t.Errorf("%s, %s: got %d input(s), fee %d, change %d, want %d, %d, %d",
+					test.name, name, len(s.Coins), s.Fee, s.Change, test.inputs, test.fee, test.change)
+			}
+			if total != test.target+s.Fee+s.Change {
+				t.Errorf("%s, %s: inputs of %d do not pay %d plus fee and change", test.name, name, total, test.target)
+			}
+		}
+	}
+}
+
+func TestRandomImproveSpendsEnough(t *testing.T) {
+	values := []uint64{300, 800, 1200, 450, 2500, 650, 900, 1700}
+
+	for seed := int64(0); seed < 50; seed++ {
+		p := testParams(2000)
+		p.Rand = rand.New(rand.NewSource(seed))
+
+		s, err := RandomImprove(coins(values...), p)
+		if err != nil {
+			t.Fatalf("seed %d: %s", seed, err)
+		}
+
+		var total uint64
+		for _, c := range s.Coins {
+			total += c.Value
+		}
+		if s.Fee < p.Fee(len(s.Coins), s.Change > 0) || total != p.Target+s.Fee+s.Change {
+			t.Errorf("seed %d: %d input(s) of %d do not pay fee %d and change %d", seed, len(s.Coins), total, s.Fee, s.Change)
+		}
+		if s.Change > 2*p.Target {
+			t.Errorf("seed %d: change %d is over twice the target", seed, s.Change)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	if _, err := Get(""); err != nil {
+		t.Errorf("default strategy: %s", err)
+	}
+	if _, err := Get("Largest-First"); err != nil {
+		t.Errorf("names are not case sensitive: %s", err)
+	}
+	if _, err := Get("unknown"); err == nil {
+		t.Error("unknown strategy was accepted")
+	}
+}
@@ -39,6 +39,14 @@ const (
 	//12M, 10M ICO, 400k Staff, 1.6M Operations & Marketing
 	CoinTotalSupply = uint64(CoinICOSupply + CoinDevSupply + CoinOAMSupply + CoinPLTSupply)
 	CoinDecimals    = 10
+	//CoinFeeRate is the default transaction fee in base units per byte
+	CoinFeeRate = 10
+	//CoinBlockInterval is the least number of seconds between two blocks mined by a node, by default
+	CoinBlockInterval = 10
+	//CoinDustLimit is the smallest change output in base units worth creating
+	CoinDustLimit = 1000
+	//CoinHDType is the coin type of the key paths of HD wallets, m/44'/{CoinHDType}'/...
+	CoinHDType = 1
 	//CoinLandingPage is the URL to your landing page (We handle this)
 	//format: https://www/gwf.io/ico/{CoinSymbol}/
 	CoinLandingPage = "https://www.gwf.io/"
@@ -60,9 +68,25 @@ const (
 	//Format: data/blockchain-{CoinPort}.db
 	FilePathBlockchain = "blockchain/blockchain-%s.db"
 
-	//FilePathWallets is the complete path to the ICO's wallets file
+	//FilePathWallets is the complete path to the ICO's wallets file of old versions, moved into FilePathWalletDB
 	//Format: data/wallets-{CoinPort}.dat
 	FilePathWallets = "blockchain/wallets-%s.dat"
+
+	//FilePathWalletDB is the complete path to the ICO's wallet database
+	//Format: data/wallets-{CoinPort}.db
+	FilePathWalletDB = "blockchain/wallets-%s.db"
+
+	//FilePathMempool is the complete path to the file keeping unconfirmed transactions across restarts
+	//Format: data/mempool-{CoinPort}.dat
+	FilePathMempool = "blockchain/mempool-%s.dat"
+
+	//FilePathPeers is the complete path to the file keeping the addresses of known peers across restarts
+	//Format: data/peers-{CoinPort}.dat
+	FilePathPeers = "blockchain/peers-%s.dat"
+
+	//FilePathRPCCookie is the complete path to the file holding the secret local tools authenticate to the RPC server with
+	//Format: data/rpc-{CoinPort}.cookie
+	FilePathRPCCookie = "blockchain/rpc-%s.cookie"
 )
 
 //*********************************************************************
@@ -83,6 +107,8 @@ const (
 const (
 	//NodePort is used for blockchain communication/syncing
 	NodePort = "3000"
+	//RPCPort is used by the local tools of a node: external miners and wallet commands
+	RPCPort = "3100"
 	//APIPort is used for REST API access to nbode
 	RESTPort = "4000"
 )
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+
+	"github.com/NlaakStudios/Blockchain/api/utils"
+)
+
+const addressChecksumLen = 4
+const pubKeyHashLen = 20
+
+// AddressFormat is the text encoding of an address
+type AddressFormat byte
+
+const (
+	// FormatBase58 is the legacy encoding: Base58 of the version byte of
+	// the network, the signature scheme, the public key hash and a 4 byte
+	// checksum. Addresses of legacy P-256 wallets carry no scheme byte.
+	FormatBase58 AddressFormat = 0x00
+	// FormatBech32 is lowercase bech32 text of the signature scheme and
+	// the public key hash, behind the human-readable part of the network
+	FormatBech32 AddressFormat = 0x01
+)
+
+// DefaultAddressFormat is the format of the addresses of new keys
+const DefaultAddressFormat = FormatBech32
+
+// AddressPrefixes start the addresses of a network, so that an address of
+// one network is never valid on another
+type AddressPrefixes struct {
+	Base58 byte   // version byte of Base58 addresses
+	Bech32 string // human-readable part of bech32 addresses
+}
+
+// Errors returned by DecodeAddress
+var (
+	ErrAddressLength   = errors.New("Address has the wrong length")
+	ErrAddressChecksum = errors.New("Address checksum does not match")
+	ErrAddressVersion  = errors.New("Address has an unknown version")
+	ErrAddressScheme   = errors.New("Address has an unknown signature scheme")
+	ErrAddressNetwork  = errors.New("Address belongs to another network")
+)
+
+// EncodeAddress returns the address of a public key hash on the network
+// the node runs on
+func EncodeAddress(scheme SigScheme, pubKeyHash []byte, format AddressFormat) string {
+	if format == FormatBech32 {
+		return utils.Bech32Encode(ActiveParams.Addresses.Bech32, append([]byte{byte(scheme)}, pubKeyHash...))
+	}
+
+	versionedPayload := append([]byte{ActiveParams.Addresses.Base58}, pubKeyHash...)
+	if scheme != SchemeP256Legacy {
+		versionedPayload = append([]byte{ActiveParams.Addresses.Base58, byte(scheme)}, pubKeyHash...)
+	}
+	fullPayload := append(versionedPayload, checksum(versionedPayload)...)
+
+	// Old versions wrote a single 1 for all the zero bytes the payload
+	// started with, which wallet files keep the keys of legacy wallets by
+	if scheme == SchemeP256Legacy && fullPayload[0] == 0x00 {
+		return "1" + string(utils.Base58Encode(bytes.TrimLeft(fullPayload, "\x00")))
+	}
+
+	return string(utils.Base58Encode(fullPayload))
+}
+
+// ValidateAddress checks that address is a valid address of the network
+// the node runs on
+func ValidateAddress(address string) error {
+	_, _, err := DecodeAddress(address)
+
+	return err
+}
+
+// DecodeAddress checks an address, in either format, and returns its signature scheme and public key hash
+func DecodeAddress(address string) (SigScheme, []byte, error) {
+	if addressFormat(address) == FormatBech32 {
+		return decodeBech32Address(address)
+	}
+
+	return decodeBase58Address(address)
+}
+
+// addressFormat returns the format of an address, which is bech32 when it
+// starts with the human-readable part of any network
+func addressFormat(address string) AddressFormat {
+	lower := strings.ToLower(address)
+	for _, params := range networks {
+		if strings.HasPrefix(lower, params.Addresses.Bech32+"1") {
+			return FormatBech32
+		}
+	}
+
+	return FormatBase58
+}
+
+// decodeBech32Address decodes an address in the bech32 format
+func decodeBech32Address(address string) (SigScheme, []byte, error) {
+	hrp, payload, err := utils.Bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if hrp != ActiveParams.Addresses.Bech32 {
+		return 0, nil, ErrAddressNetwork
+	}
+	if len(payload) != 1+pubKeyHashLen {
+		return 0, nil, ErrAddressLength
+	}
+
+	scheme := SigScheme(payload[0])
+	if _, err := GetScheme(scheme); err != nil {
+		return 0, nil, ErrAddressScheme
+	}
+
+	return scheme, payload[1:], nil
+}
+
+// decodeBase58Address decodes an address in the legacy Base58 format
+func decodeBase58Address(address string) (SigScheme, []byte, error) {
+	payload, err := utils.Base58Decode([]byte(address))
+	if err != nil {
+		return 0, nil, err
+	}
+	// Legacy addresses of old versions lost the zero bytes after the first
+	// one, which the checksum tells apart from a truncated address
+	if legacyLen := 1 + pubKeyHashLen + addressChecksumLen; len(payload) < legacyLen && strings.HasPrefix(address, "1") {
+		payload = append(make([]byte, legacyLen-len(payload)), payload...)
+	}
+	if len(payload) < 1+addressChecksumLen {
+		return 0, nil, ErrAddressLength
+	}
+
+	actualChecksum := payload[len(payload)-addressChecksumLen:]
+	versionedPayload := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(actualChecksum, checksum(versionedPayload)) {
+		return 0, nil, ErrAddressChecksum
+	}
+
+	if versionedPayload[0] != ActiveParams.Addresses.Base58 {
+		for _, params := range networks {
+			if versionedPayload[0] == params.Addresses.Base58 {
+				return 0, nil, ErrAddressNetwork
+			}
+		}
+		return 0, nil, ErrAddressVersion
+	}
+
+	switch len(versionedPayload) {
+	case 1 + pubKeyHashLen:
+		return SchemeP256Legacy, versionedPayload[1:], nil
+	case 2 + pubKeyHashLen:
+		scheme := SigScheme(versionedPayload[1])
+		if _, err := GetScheme(scheme); err != nil || scheme == SchemeP256Legacy {
+			return 0, nil, ErrAddressScheme
+		}
+		return scheme, versionedPayload[2:], nil
+	}
+
+	return 0, nil, ErrAddressLength
+}
+
+// Checksum generates a checksum for a public key
+func checksum(payload []byte) []byte {
+	firstSHA := sha256.Sum256(payload)
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:addressChecksumLen]
+}
@@ -0,0 +1,455 @@
+package core
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/utils"
+)
+
+// Addresses are kept in buckets: new ones, heard of from other nodes, and
+// tried ones, connected to at least once. The bucket of an address depends
+// on a secret key, and for new ones on the node that sent it, so that a
+// single node cannot fill the table with its own addresses.
+const (
+	newBucketCount   = 64
+	triedBucketCount = 16
+	bucketSize       = 64
+)
+
+// Rules telling an address not worth dialing anymore
+const (
+	addrHorizon        = 30 * 24 * time.Hour // not seen for that long
+	addrMaxRetries     = 3                   // failed attempts of a never connected address
+	addrMaxFailures    = 10                  // failed attempts since the last success...
+	addrMinFailureTime = 7 * 24 * time.Hour  // ...when it is that old
+	addrRetryInterval  = time.Minute         // least time between two attempts
+)
+
+// Limits of addr messages
+const (
+	maxAddrPerMsg    = 1000             // addresses in a message at most
+	addrTokenRate    = 0.1              // addresses per second a peer may send unasked
+	addrCachePercent = 23               // share of the known addresses answering a getaddr
+	addrCacheMin     = 50               // addresses answering a getaddr, unless fewer are known
+	addrRelayMax     = 10               // addresses in a message relayed at most
+	addrRelayPeers   = 2                // peers a message of new addresses is relayed to
+	addrRelayAge     = 10 * time.Minute // age of the addresses relayed at most
+	addrSaveInterval = 10 * time.Minute
+)
+
+// KnownAddress is the address of a node with what is known of it
+type KnownAddress struct {
+	Addr        string
+	Services    uint64
+	Source      string    // node the address was heard of from, empty for seeds
+	LastSeen    time.Time // last time the node was announced or connected
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Attempts    int // failed attempts since the last success
+	Tried       bool
+}
+
+// isBad tells whether an address is not worth dialing or sharing
+func (ka *KnownAddress) isBad(now time.Time) bool {
+	if now.Sub(ka.LastAttempt) < addrRetryInterval {
+		return false
+	}
+	if ka.LastSeen.After(now.Add(10 * time.Minute)) {
+		return true
+	}
+	if now.Sub(ka.LastSeen) > addrHorizon {
+		return true
+	}
+	if ka.LastSuccess.IsZero() && ka.Attempts >= addrMaxRetries {
+		return true
+	}
+	if now.Sub(ka.LastSuccess) > addrMinFailureTime && ka.Attempts >= addrMaxFailures {
+		return true
+	}
+
+	return false
+}
+
+// peersFile is the content of the peers file
+type peersFile struct {
+	Key   [32]byte
+	Addrs []KnownAddress
+}
+
+// AddrManager keeps the addresses of the nodes of the network, so that a
+// node finds peers to connect to, shares them and still knows them after a
+// restart
+type AddrManager struct {
+	file string
+
+	mu           sync.Mutex
+	key          [32]byte
+	addrs        map[string]*KnownAddress
+	newBuckets   [newBucketCount]map[string]*KnownAddress
+	triedBuckets [triedBucketCount]map[string]*KnownAddress
+}
+
+// addrManager is the address manager of the running node
+var addrManager *AddrManager
+
+// GetPeersFile returns the path of the file the known peers of a node are kept in
+func GetPeersFile(nodeID string) string {
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathPeers)
+	return fmt.Sprintf(str, nodeID)
+}
+
+// NewAddrManager creates an empty address manager saving to file
+func NewAddrManager(file string) *AddrManager {
+	am := &AddrManager{file: file}
+	if _, err := rand.Read(am.key[:]); err != nil {
+		log.Panic(err)
+	}
+	am.reset()
+
+	return am
+}
+
+// reset forgets every address
+func (am *AddrManager) reset() {
+	am.addrs = make(map[string]*KnownAddress)
+	for i := range am.newBuckets {
+		am.newBuckets[i] = make(map[string]*KnownAddress)
+	}
+	for i := range am.triedBuckets {
+		am.triedBuckets[i] = make(map[string]*KnownAddress)
+	}
+}
+
+// Load replaces the addresses with those of the peers file. A missing file
+// leaves the manager empty.
+func (am *AddrManager) Load() error {
+	fileContent, err := ioutil.ReadFile(am.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var content peersFile
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&content)
+	if err != nil {
+		return err
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.key = content.Key
+	am.reset()
+	for i := range content.Addrs {
+		ka := content.Addrs[i]
+		if ka.Tried {
+			am.addTried(&ka)
+		} else {
+			am.addNew(&ka)
+		}
+	}
+
+	return nil
+}
+
+// Save writes the known addresses and the bucket key to the peers file
+func (am *AddrManager) Save() error {
+	am.mu.Lock()
+	content := peersFile{Key: am.key}
+	for _, ka := range am.addrs {
+		content.Addrs = append(content.Addrs, *ka)
+	}
+	am.mu.Unlock()
+
+	var buff bytes.Buffer
+	err := gob.NewEncoder(&buff).Encode(content)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(am.file, buff.Bytes(), 0644)
+}
+
+// Count returns the number of known addresses
+func (am *AddrManager) Count() int {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	return len(am.addrs)
+}
+
+// AddAddresses adds addresses heard of from source to the new buckets, or
+// updates what is known of them. It returns the addresses that were not
+// known yet.
+func (am *AddrManager) AddAddresses(addrs []netAddress, source string) []netAddress {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now()
+	added := []netAddress{}
+	for _, na := range addrs {
+		if na.Addr == nodeAddress || !validPeerAddress(na.Addr) {
+			continue
+		}
+		seen := time.Unix(na.Timestamp, 0)
+		if seen.After(now) {
+			seen = now
+		}
+
+		if ka, ok := am.addrs[na.Addr]; ok {
+			if seen.After(ka.LastSeen) {
+				ka.LastSeen = seen
+			}
+			ka.Services |= na.Services
+			continue
+		}
+
+		am.addNew(&KnownAddress{Addr: na.Addr, Services: na.Services, Source: source, LastSeen: seen})
+		added = append(added, na)
+	}
+
+	return added
+}
+
+// Attempt records an attempt to connect to addr
+func (am *AddrManager) Attempt(addr string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if ka, ok := am.addrs[addr]; ok {
+		ka.LastAttempt = time.Now()
+		ka.Attempts++
+	}
+}
+
+// Good records a successful connection to addr, moving it to the tried buckets
+func (am *AddrManager) Good(addr string, services uint64) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	ka, ok := am.addrs[addr]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	ka.LastSeen = now
+	ka.LastSuccess = now
+	ka.Attempts = 0
+	ka.Services = services
+
+	if !ka.Tried {
+		delete(am.newBuckets[am.newBucket(ka)], addr)
+		delete(am.addrs, addr)
+		am.addTried(ka)
+	}
+}
+
+// Connected records that the node at addr was connected until now
+func (am *AddrManager) Connected(addr string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if ka, ok := am.addrs[addr]; ok {
+		ka.LastSeen = time.Now()
+	}
+}
+
+// GetAddress picks an address to dial at random, from the tried or the new
+// buckets with even chances, among those not bad nor tried recently and for
+// which skip is false. It returns nil when there is none.
+func (am *AddrManager) GetAddress(skip func(addr string) bool) *KnownAddress {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now()
+	var tried, fresh []*KnownAddress
+	for _, ka := range am.addrs {
+		if ka.isBad(now) || now.Sub(ka.LastAttempt) < addrRetryInterval || skip(ka.Addr) {
+			continue
+		}
+		if ka.Tried {
+			tried = append(tried, ka)
+		} else {
+			fresh = append(fresh, ka)
+		}
+	}
+
+	candidates := fresh
+	if len(fresh) == 0 || (len(tried) > 0 && randomInt(2) == 0) {
+		candidates = tried
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	ka := *candidates[randomInt(len(candidates))]
+
+	return &ka
+}
+
+// AddressCache returns a random share of the addresses that are not bad,
+// to answer a getaddr
+func (am *AddrManager) AddressCache() []netAddress {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now()
+	cache := []netAddress{}
+	for _, ka := range am.addrs {
+		if !ka.isBad(now) {
+			cache = append(cache, netAddress{ka.Addr, ka.Services, ka.LastSeen.Unix()})
+		}
+	}
+
+	count := len(cache) * addrCachePercent / 100
+	if count < addrCacheMin {
+		count = addrCacheMin
+	}
+	if count > maxAddrPerMsg {
+		count = maxAddrPerMsg
+	}
+	if count > len(cache) {
+		count = len(cache)
+	}
+	shuffled := make([]netAddress, count)
+	for i, j := range randomPerm(len(cache))[:count] {
+		shuffled[i] = cache[j]
+	}
+
+	return shuffled
+}
+
+// addNew puts an address in its new bucket, evicting the worst address of
+// a full bucket
+func (am *AddrManager) addNew(ka *KnownAddress) {
+	ka.Tried = false
+	bucket := am.newBuckets[am.newBucket(ka)]
+	if len(bucket) >= bucketSize {
+		am.evict(bucket)
+	}
+	bucket[ka.Addr] = ka
+	am.addrs[ka.Addr] = ka
+}
+
+// addTried puts an address in its tried bucket. The address of a full
+// bucket connected to least recently goes back to the new buckets.
+func (am *AddrManager) addTried(ka *KnownAddress) {
+	ka.Tried = true
+	bucket := am.triedBuckets[am.triedBucket(ka)]
+	if len(bucket) >= bucketSize {
+		var oldest *KnownAddress
+		for _, other := range bucket {
+			if oldest == nil || other.LastSuccess.Before(oldest.LastSuccess) {
+				oldest = other
+			}
+		}
+		delete(bucket, oldest.Addr)
+		delete(am.addrs, oldest.Addr)
+		am.addNew(oldest)
+	}
+	bucket[ka.Addr] = ka
+	am.addrs[ka.Addr] = ka
+}
+
+// evict removes from a new bucket a bad address, or the one seen least recently
+func (am *AddrManager) evict(bucket map[string]*KnownAddress) {
+	now := time.Now()
+	var worst *KnownAddress
+	for _, ka := range bucket {
+		if ka.isBad(now) {
+			worst = ka
+			break
+		}
+		if worst == nil || ka.LastSeen.Before(worst.LastSeen) {
+			worst = ka
+		}
+	}
+	delete(bucket, worst.Addr)
+	delete(am.addrs, worst.Addr)
+}
+
+// newBucket returns the new bucket of an address, chosen by the groups of
+// the address and of its source
+func (am *AddrManager) newBucket(ka *KnownAddress) int {
+	return am.bucket(newBucketCount, addrGroup(ka.Source), addrGroup(ka.Addr))
+}
+
+// triedBucket returns the tried bucket of an address
+func (am *AddrManager) triedBucket(ka *KnownAddress) int {
+	return am.bucket(triedBucketCount, ka.Addr)
+}
+
+// bucket hashes the key of the manager with parts into one of count buckets
+func (am *AddrManager) bucket(count int, parts ...string) int {
+	data := append([]byte{}, am.key[:]...)
+	for _, part := range parts {
+		data = append(data, []byte(part)...)
+		data = append(data, 0)
+	}
+	hash := sha256.Sum256(data)
+
+	return int(binary.LittleEndian.Uint64(hash[:8]) % uint64(count))
+}
+
+// addrGroup returns the network group of an address: the first two bytes
+// of an IPv4 address, the first four of an IPv6 one, the host name otherwise
+func addrGroup(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return strings.ToLower(host)
+	case ip.To4() != nil:
+		return fmt.Sprintf("%d.%d", ip.To4()[0], ip.To4()[1])
+	}
+
+	return fmt.Sprintf("%x", []byte(ip[:4]))
+}
+
+// validPeerAddress checks that addr is a host and a port
+func validPeerAddress(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+
+	return err == nil && host != "" && port != "" && port != "0"
+}
+
+// randomInt returns a random integer in [0, n)
+func randomInt(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return int(i.Int64())
+}
+
+// randomPerm returns a random permutation of [0, n)
+func randomPerm(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		j := randomInt(i + 1)
+		perm[i] = perm[j]
+		perm[j] = i
+	}
+
+	return perm
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+)
+
+// Amount is a quantity of coins or asset units counted in base units. One
+// whole coin is CoinUnit base units, as set by config.CoinDecimals.
+type Amount uint64
+
+// CoinUnit is the number of base units in one whole native coin
+var CoinUnit = mustPow10(config.CoinDecimals)
+
+// ErrAmountOverflow is returned when arithmetic on amounts leaves the uint64 range
+var ErrAmountOverflow = errors.New("Amount overflow")
+
+// Add returns a+b or an error if the sum overflows
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a > math.MaxUint64-b {
+		return 0, ErrAmountOverflow
+	}
+
+	return a + b, nil
+}
+
+// Sub returns a-b or an error if b is larger than a
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b > a {
+		return 0, ErrAmountOverflow
+	}
+
+	return a - b, nil
+}
+
+// Mul returns a*n or an error if the product overflows
+func (a Amount) Mul(n uint64) (Amount, error) {
+	if n != 0 && uint64(a) > math.MaxUint64/n {
+		return 0, ErrAmountOverflow
+	}
+
+	return a * Amount(n), nil
+}
+
+// String formats the amount as native coins, ie 1.2500000000 GWFI
+func (a Amount) String() string {
+	return FormatAmount(a, config.CoinDecimals, config.CoinSymbol)
+}
+
+// FormatAmount formats base units with the given number of decimals followed by symbol
+func FormatAmount(a Amount, decimals uint, symbol string) string {
+	unit := mustPow10(decimals)
+	str := fmt.Sprintf("%d", a/unit)
+	if decimals > 0 {
+		str = fmt.Sprintf("%s.%0*d", str, decimals, a%unit)
+	}
+	if symbol != "" {
+		str = fmt.Sprintf("%s %s", str, symbol)
+	}
+
+	return str
+}
+
+// ParseAmount parses a decimal string such as "1.25" or "1.2500000000 GWFI"
+// into base units. A trailing symbol, if present, must match symbol.
+func ParseAmount(str string, decimals uint, symbol string) (Amount, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, fmt.Errorf("Invalid amount %q", str)
+	}
+	if len(fields) == 2 && !strings.EqualFold(fields[1], symbol) {
+		return 0, fmt.Errorf("Amount %q is not in %s", str, symbol)
+	}
+
+	whole, frac := fields[0], ""
+	if i := strings.IndexByte(whole, '.'); i >= 0 {
+		whole, frac = whole[:i], whole[i+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("Invalid amount %q", str)
+	}
+	if uint(len(frac)) > decimals {
+		return 0, fmt.Errorf("Amount %q has more than %d decimals", str, decimals)
+	}
+	frac += strings.Repeat("0", int(decimals)-len(frac))
+
+	var amount Amount
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("Invalid amount %q", str)
+		}
+		var err error
+		amount, err = amount.Mul(10)
+		if err == nil {
+			amount, err = amount.Add(Amount(c - '0'))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Amount %q is too large", str)
+		}
+	}
+
+	return amount, nil
+}
+
+// ParseCoinAmount parses an amount of the native coin
+func ParseCoinAmount(str string) (Amount, error) {
+	return ParseAmount(str, config.CoinDecimals, config.CoinSymbol)
+}
+
+func mustPow10(decimals uint) Amount {
+	unit := Amount(1)
+	for i := uint(0); i < decimals; i++ {
+		var err error
+		unit, err = unit.Mul(10)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return unit
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		str      string
+		decimals uint
+		amount   Amount
+		ok       bool
+	}{
+		{name: "whole", str: "12", decimals: 2, amount: 1200, ok: true},
+		{name: "decimals", str: "1.25", decimals: 2, amount: 125, ok: true},
+		{name: "fewer decimals", str: "1.5", decimals: 2, amount: 150, ok: true},
+		{name: "no whole part", str: ".05", decimals: 2, amount: 5, ok: true},
+		{name: "no decimals after the point", str: "3.", decimals: 2, amount: 300, ok: true},
+		{name: "no decimals at all", str: "42", decimals: 0, amount: 42, ok: true},
+		{name: "surrounding spaces", str: "  1.25 ", decimals: 2, amount: 125, ok: true},
+		{name: "symbol", str: "1.25 GWFI", decimals: 2, amount: 125, ok: true},
+		{name: "symbol in lower case", str: "1.25 gwfi", decimals: 2, amount: 125, ok: true},
+		{name: "largest", str: "184467440737095516.15", decimals: 2, amount: math.MaxUint64, ok: true},
+		{name: "extra precision", str: "1.255", decimals: 2},
+		{name: "decimals when there are none", str: "1.0", decimals: 0},
+		{name: "overflow", str: "184467440737095516.16", decimals: 2},
+		{name: "overflow of the whole part", str: "99999999999999999999", decimals: 0},
+		{name: "negative", str: "-1", decimals: 2},
+		{name: "plus sign", str: "+1", decimals: 2},
+		{name: "empty", str: "", decimals: 2},
+		{name: "blank", str: "   ", decimals: 2},
+		{name: "point only", str: ".", decimals: 2},
+		{name: "two points", str: "1.2.3", decimals: 2},
+		{name: "letters", str: "1e5", decimals: 2},
+		{name: "other symbol", str: "1.25 BTC", decimals: 2},
+		{name: "symbol only", str: "GWFI", decimals: 2},
+		{name: "too many fields", str: "1.25 GWFI GWFI", decimals: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount, err := ParseAmount(test.str, test.decimals, "GWFI")
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("ParseAmount(%q) = %d, want an error", test.str, amount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAmount(%q): %s", test.str, err)
+			}
+			if amount != test.amount {
+				t.Errorf("ParseAmount(%q) = %d, want %d", test.str, amount, test.amount)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		decimals uint
+		symbol   string
+		str      string
+	}{
+		{0, 2, "GWFI", "0.00 GWFI"},
+		{5, 2, "GWFI", "0.05 GWFI"},
+		{125, 2, "GWFI", "1.25 GWFI"},
+		{125, 2, "", "1.25"},
+		{42, 0, "GWFI", "42 GWFI"},
+		{42, 0, "", "42"},
+		{12500000000, 10, "GWFI", "1.2500000000 GWFI"},
+		{math.MaxUint64, 2, "", "184467440737095516.15"},
+	}
+
+	for _, test := range tests {
+		if str := FormatAmount(test.amount, test.decimals, test.symbol); str != test.str {
+			t.Errorf("FormatAmount(%d, %d, %q) = %q, want %q", test.amount, test.decimals, test.symbol, str, test.str)
+		}
+	}
+}
+
+func TestFormatAmountRoundTrip(t *testing.T) {
+	for _, amount := range []Amount{0, 1, 99, 100, 123456789, math.MaxUint64} {
+		str := FormatAmount(amount, 8, "GWFI")
+		parsed, err := ParseAmount(str, 8, "GWFI")
+		if err != nil || parsed != amount {
+			t.Errorf("ParseAmount(%q) = %d, %v, want %d", str, parsed, err, amount)
+		}
+	}
+}
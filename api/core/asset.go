@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/utils"
+	"github.com/boltdb/bolt"
+)
+
+const assetsBucket = "assets"
+
+// maxAssetDecimals is the largest number of decimals an asset may declare
+const maxAssetDecimals = 18
+
+var assetSymbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,12}$`)
+
+// AssetIssuance defines an asset created by an issuance transaction
+type AssetIssuance struct {
+	ID       []byte
+	Symbol   string
+	Decimals uint
+	Supply   Amount
+}
+
+// NewAssetID derives the ID of an asset from the first input of its issuance
+// transaction. An output can be spent only once, so IDs never collide.
+func NewAssetID(in TXInput) []byte {
+	data := append(append([]byte{}, in.Txid...), utils.IntToHex(int64(in.Vout))...)
+	hash := sha256.Sum256(data)
+
+	return hash[:20]
+}
+
+// NewIssuanceTransaction creates a transaction issuing the full supply of a
+// new asset to the wallet. The wallet's coins pay the fee, and the first one
+// spent derives the asset ID.
+func NewIssuanceTransaction(wallet *Wallet, symbol string, decimals uint, supply Amount, UTXOSet *UTXOSet, mempool *Mempool) *Transaction {
+	issuance := &AssetIssuance{make([]byte, 20), strings.ToUpper(symbol), decimals, supply}
+	if err := issuance.check(); err != nil {
+		log.Panic(err)
+	}
+
+	from := string(wallet.GetAddress())
+	outputs := []TXOutput{*NewAssetTXOutput(supply, issuance.ID, from)}
+
+	params := selectParams(wallet, Transaction{nil, nil, outputs, issuance}, from, DefaultFeeRate)
+	inputs, selection := selectInputs(wallet, nil, coinselect.LargestFirst, params, UTXOSet, mempool)
+	if selection.Change > 0 {
+		outputs = append(outputs, *NewTXOutput(Amount(selection.Change), from))
+	}
+
+	issuance.ID = NewAssetID(inputs[0])
+	outputs[0].Asset = issuance.ID
+
+	tx := Transaction{nil, inputs, outputs, issuance}
+	tx.ID = tx.Hash()
+	signTransaction(&tx, wallet, UTXOSet, mempool)
+
+	return &tx
+}
+
+// check validates the fields of an issuance
+func (a *AssetIssuance) check() error {
+	if !assetSymbolPattern.MatchString(a.Symbol) {
+		return errors.New("ERROR: Asset symbol must be 2 to 12 uppercase letters or digits")
+	}
+	if a.Symbol == config.CoinSymbol {
+		return errors.New("ERROR: Asset symbol is reserved for the native coin")
+	}
+	if a.Decimals > maxAssetDecimals {
+		return errors.New("ERROR: Asset has too many decimals")
+	}
+	if a.Supply == 0 {
+		return errors.New("ERROR: Asset supply must be positive")
+	}
+
+	return nil
+}
+
+// checkAssets makes sure the transaction conserves every asset. The native
+// coin may shrink (the difference is left to the miner) but issued assets
+// must balance exactly, except for the supply minted by an issuance. Sums
+// that overflow make the transaction invalid.
+func (tx *Transaction) checkAssets(prevTXs map[string]Transaction) bool {
+	ins := make(map[string]Amount)
+	outs := make(map[string]Amount)
+	var err error
+
+	for _, vin := range tx.Vin {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+		asset := hex.EncodeToString(prevOut.Asset)
+		if ins[asset], err = ins[asset].Add(prevOut.Value); err != nil {
+			return false
+		}
+	}
+
+	for _, out := range tx.Vout {
+		if out.IsData() {
+			continue
+		}
+		if out.Value == 0 {
+			return false
+		}
+		asset := hex.EncodeToString(out.Asset)
+		if outs[asset], err = outs[asset].Add(out.Value); err != nil {
+			return false
+		}
+	}
+
+	if tx.Issuance != nil {
+		if tx.Issuance.check() != nil || len(tx.Vin) == 0 {
+			return false
+		}
+		if !bytes.Equal(tx.Issuance.ID, NewAssetID(tx.Vin[0])) {
+			return false
+		}
+		asset := hex.EncodeToString(tx.Issuance.ID)
+		if ins[asset], err = ins[asset].Add(tx.Issuance.Supply); err != nil {
+			return false
+		}
+	}
+
+	for asset, value := range outs {
+		if asset == "" {
+			if value > ins[asset] {
+				return false
+			}
+		} else if value != ins[asset] {
+			return false
+		}
+	}
+	for asset, value := range ins {
+		if asset != "" && outs[asset] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindAsset looks up an issued asset by its symbol or hex ID
+func (u UTXOSet) FindAsset(symbolOrID string) (*AssetIssuance, error) {
+	var found *AssetIssuance
+
+	for _, asset := range u.ListAssets() {
+		if asset.Symbol == strings.ToUpper(symbolOrID) || hex.EncodeToString(asset.ID) == symbolOrID {
+			a := asset
+			found = &a
+			break
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("Asset is not found")
+	}
+
+	return found, nil
+}
+
+// ResolveAsset maps the native coin symbol, an asset symbol or a hex asset
+// ID to the asset ID, symbol and decimals. The native coin, also given by
+// an empty string, has a nil ID.
+func (u UTXOSet) ResolveAsset(asset string) ([]byte, string, uint, error) {
+	if asset == "" || asset == config.CoinSymbol {
+		return nil, config.CoinSymbol, config.CoinDecimals, nil
+	}
+
+	issuance, err := u.FindAsset(asset)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("Unknown asset %s", asset)
+	}
+
+	return issuance.ID, issuance.Symbol, issuance.Decimals, nil
+}
+
+// ListAssets returns all assets issued on the chain
+func (u UTXOSet) ListAssets() []AssetIssuance {
+	var assets []AssetIssuance
+	db := u.Blockchain.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(assetsBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			assets = append(assets, deserializeAsset(v))
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return assets
+}
+
+// reindexAssets rebuilds the asset registry from the chain
+func (u UTXOSet) reindexAssets() {
+	db := u.Blockchain.DB
+	bucketName := []byte(assetsBucket)
+
+	var issuances []*Transaction
+	bci := u.Blockchain.Iterator()
+	for {
+		block := bci.Next()
+		for _, tx := range block.Transactions {
+			if tx.Issuance != nil {
+				issuances = append(issuances, tx)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != bolt.ErrBucketNotFound {
+			log.Panic(err)
+		}
+
+		b, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		putAssets(b, issuances)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// putAssets records the assets issued by transactions
+func putAssets(b *bolt.Bucket, txs []*Transaction) {
+	for _, tx := range txs {
+		if tx.Issuance == nil {
+			continue
+		}
+
+		var buff bytes.Buffer
+		err := gob.NewEncoder(&buff).Encode(tx.Issuance)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = b.Put(tx.Issuance.ID, buff.Bytes())
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+func deserializeAsset(data []byte) AssetIssuance {
+	var asset AssetIssuance
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&asset)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return asset
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/gob"
 	"log"
-	"time"
 )
 
+// MaxBlockSize is the most bytes of transactions a mined block carries
+const MaxBlockSize = 1 << 20
+
 // Block represents a block in the blockchain
 type Block struct {
 	Timestamp     int64
@@ -17,9 +19,21 @@ type Block struct {
 	Height        int
 }
 
+// BlockHeader is a block without its transactions, only the root of their
+// Merkle tree. It is enough to check the proof-of-work of the block and
+// its place in the chain.
+type BlockHeader struct {
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	Nonce         int
+	Height        int
+	Hash          []byte
+}
+
 // NewBlock creates and returns Block
 func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Block {
-	block := &Block{time.Now().Unix(), transactions, prevBlockHash, []byte{}, 0, height}
+	block := &Block{Now().Unix(), transactions, prevBlockHash, []byte{}, 0, height}
 	pow := NewProofOfWork(block)
 	nonce, hash := pow.Run()
 
@@ -46,6 +60,11 @@ func (b *Block) HashTransactions() []byte {
 	return mTree.RootNode.Data
 }
 
+// Header returns the header of the block
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{b.PrevBlockHash, b.HashTransactions(), b.Timestamp, b.Nonce, b.Height, b.Hash}
+}
+
 // Serialize serializes the block
 func (b *Block) Serialize() []byte {
 	var result bytes.Buffer
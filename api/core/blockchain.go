@@ -2,7 +2,6 @@ package core
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -14,7 +13,6 @@ import (
 )
 
 const blocksBucket = "blocks"
-const genesisCoinbaseData = "Global Wealth & Freedom Genesis block."
 
 // Blockchain implements interactions with a DB
 type Blockchain struct {
@@ -22,8 +20,9 @@ type Blockchain struct {
 	DB  *bolt.DB
 }
 
+// GetBlockChainFile returns the path of the blockchain database of a node on the active network
 func GetBlockChainFile(nodeID string) string {
-	str := fmt.Sprintf("%s/%s", config.FilePathData, config.FilePathBlockchain)
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathBlockchain)
 	return fmt.Sprintf(str, nodeID)
 }
 
@@ -38,7 +37,7 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 
 	var tip []byte
 
-	cbtx := NewBlockchainTX(address, genesisCoinbaseData)
+	cbtx := NewBlockchainTX(address, ActiveParams.GenesisData)
 
 	genesis := NewGenesisBlock(cbtx)
 
@@ -103,6 +102,12 @@ func NewBlockchain(nodeID string) *Blockchain {
 
 	bc := Blockchain{tip, db}
 
+	// Nodes upgraded from an older release have a UTXO set they cannot read
+	if UTXOSet := (UTXOSet{&bc}); !UTXOSet.isCurrent() {
+		fmt.Println("Rebuilding the UTXO set in the current format...")
+		UTXOSet.Reindex()
+	}
+
 	return &bc
 }
 
@@ -141,6 +146,98 @@ func (bc *Blockchain) AddBlock(block *Block) {
 	}
 }
 
+// putBlock stores a block without making it the tip
+func (bc *Blockchain) putBlock(block *Block) {
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		if b.Get(block.Hash) != nil {
+			return nil
+		}
+
+		return b.Put(block.Hash, block.Serialize())
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// setTip makes the stored block with the given hash the last block
+func (bc *Blockchain) setTip(hash []byte) {
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blocksBucket)).Put([]byte("l"), hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	bc.Tip = hash
+}
+
+// reorganize switches the chain to the branch ending with block, which is
+// stored and higher than the tip. The blocks of the current chain are
+// disconnected down to the fork point, then the blocks of the branch are
+// checked and connected one by one. When one of them is invalid the current
+// chain is connected again and the error returned. It returns the blocks
+// disconnected and those connected, from the fork point up.
+func (bc *Blockchain) reorganize(block *Block) ([]*Block, []*Block, error) {
+	tipHash, _ := bc.tip()
+	tip, err := bc.GetBlock(tipHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var detached, attached []*Block
+	old, branch := &tip, block
+	for branch.Height > old.Height {
+		attached = append([]*Block{branch}, attached...)
+		if branch, err = bc.prevBlock(branch); err != nil {
+			return nil, nil, err
+		}
+	}
+	for !bytes.Equal(old.Hash, branch.Hash) {
+		detached = append([]*Block{old}, detached...)
+		attached = append([]*Block{branch}, attached...)
+		if old, err = bc.prevBlock(old); err != nil {
+			return nil, nil, err
+		}
+		if branch, err = bc.prevBlock(branch); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	UTXOSet := UTXOSet{bc}
+	for i := len(detached) - 1; i >= 0; i-- {
+		UTXOSet.disconnect(detached[i])
+		bc.setTip(detached[i].PrevBlockHash)
+	}
+	for i, b := range attached {
+		if err := bc.checkTransactions(b); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				UTXOSet.disconnect(attached[j])
+				bc.setTip(attached[j].PrevBlockHash)
+			}
+			for _, b := range detached {
+				UTXOSet.Update(b)
+				bc.setTip(b.Hash)
+			}
+			return nil, nil, err
+		}
+		UTXOSet.Update(b)
+		bc.setTip(b.Hash)
+	}
+
+	return detached, attached, nil
+}
+
+// prevBlock returns the stored parent of a block
+func (bc *Blockchain) prevBlock(block *Block) (*Block, error) {
+	prev, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prev, nil
+}
+
 // FindTransaction finds a transaction by its ID
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	bci := bc.Iterator()
@@ -162,6 +259,70 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("Transaction is not found")
 }
 
+// findPrevTransactions finds the transactions spent by the inputs of txs in
+// a single pass over the chain. Inputs may also spend earlier transactions
+// of txs. An error is returned when one of them is not found.
+func (bc *Blockchain) findPrevTransactions(txs []*Transaction) (map[string]Transaction, error) {
+	prevTXs := make(map[string]Transaction)
+	wanted := make(map[string]bool)
+
+	for _, tx := range txs {
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				txID := hex.EncodeToString(vin.Txid)
+				if _, ok := prevTXs[txID]; !ok {
+					wanted[txID] = true
+				}
+			}
+		}
+		prevTXs[hex.EncodeToString(tx.ID)] = *tx
+	}
+
+	bci := bc.Iterator()
+	for len(wanted) > 0 {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+			if wanted[txID] {
+				prevTXs[txID] = *tx
+				delete(wanted, txID)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	if len(wanted) > 0 {
+		return nil, errors.New("Transaction is not found")
+	}
+
+	return prevTXs, nil
+}
+
+// FindTransactionBlock finds the block containing the transaction with the given ID
+func (bc *Blockchain) FindTransactionBlock(ID []byte) (*Block, error) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			if bytes.Compare(tx.ID, ID) == 0 {
+				return block, nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, errors.New("Transaction is not found")
+}
+
 // FindUTXO finds all unspent transaction outputs and returns transactions with spent outputs removed
 func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 	UTXO := make(map[string]TXOutputs)
@@ -176,6 +337,11 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				// Data outputs can never be spent
+				if out.IsData() {
+					continue
+				}
+
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
@@ -185,8 +351,11 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 					}
 				}
 
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
+				outs, ok := UTXO[txID]
+				if !ok {
+					outs = NewTXOutputs()
+				}
+				outs.Outputs[outIdx] = out
 				UTXO[txID] = outs
 			}
 
@@ -276,34 +445,15 @@ func (bc *Blockchain) GetBlockHashes() [][]byte {
 
 // MineBlock mines a new block with the provided transactions
 func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
-	var lastHash []byte
-	var lastHeight int
-
-	for _, tx := range transactions {
-		// TODO: ignore transaction if it's not valid
-		if bc.VerifyTransaction(tx) != true {
-			log.Panic("ERROR: Invalid transaction")
-		}
-	}
-
-	err := bc.DB.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
-
-		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
-
-		lastHeight = block.Height
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
+	// TODO: ignore transaction if it's not valid
+	if bc.VerifyTransactions(transactions) != true {
+		log.Panic("ERROR: Invalid transaction")
 	}
 
+	lastHash, lastHeight := bc.tip()
 	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
 
-	err = bc.DB.Update(func(tx *bolt.Tx) error {
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		err := b.Put(newBlock.Hash, newBlock.Serialize())
 		if err != nil {
@@ -327,7 +477,7 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 }
 
 // SignTransaction signs inputs of a Transaction
-func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
+func (bc *Blockchain) SignTransaction(tx *Transaction, wallet *Wallet) {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
@@ -338,26 +488,53 @@ func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	tx.Sign(privKey, prevTXs)
+	tx.Sign(wallet, prevTXs)
 }
 
 // VerifyTransaction verifies transaction input signatures
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
-	if tx.IsCoinbase() {
-		return true
+	return bc.VerifyTransactions([]*Transaction{tx})
+}
+
+// VerifyTransactions verifies the transactions of a block. The input
+// signatures of all of them are checked together across CPU cores, and
+// signatures verified earlier are taken from the signature cache. An asset
+// symbol is issued once, on the chain or within the block.
+func (bc *Blockchain) VerifyTransactions(txs []*Transaction) bool {
+	prevTXs, err := bc.findPrevTransactions(txs)
+	if err != nil {
+		return false
 	}
 
-	prevTXs := make(map[string]Transaction)
+	var checks []sigCheck
+	symbols := make(map[string]bool)
+	for _, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
 
-	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
+		if !tx.checkDataOutputs(prevTXs) || !tx.checkAssets(prevTXs) {
+			return false
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+
+		if tx.Issuance != nil {
+			if symbols[tx.Issuance.Symbol] {
+				return false
+			}
+			if _, err := (UTXOSet{bc}).FindAsset(tx.Issuance.Symbol); err == nil {
+				return false
+			}
+			symbols[tx.Issuance.Symbol] = true
+		}
+
+		txChecks, ok := tx.sigChecks(prevTXs)
+		if !ok {
+			return false
+		}
+		checks = append(checks, txChecks...)
 	}
 
-	return tx.Verify(prevTXs)
+	return verifySigChecks(checks)
 }
 
 func dbExists(dbFile string) bool {
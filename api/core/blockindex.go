@@ -0,0 +1,234 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// maxFutureBlockTime is how far in the future of the node's clock the
+// timestamp of a header may be
+const maxFutureBlockTime = 2 * time.Hour
+
+// Errors returned when a header is refused
+var (
+	ErrHeaderOrphan = errors.New("Header does not connect to a known block")
+	ErrHeaderHeight = errors.New("Header height does not follow its parent")
+	ErrHeaderProof  = errors.New("Header hash does not meet the proof-of-work target")
+	ErrHeaderTime   = errors.New("Header timestamp is too far in the future")
+)
+
+// blockNode is a header of the block index, linked to its parent
+type blockNode struct {
+	header   *BlockHeader
+	parent   *blockNode
+	haveData bool // whether the block is stored in the blockchain
+}
+
+// ancestor returns the ancestor of the node at height, the node itself at
+// its own height, nil above it
+func (n *blockNode) ancestor(height int) *blockNode {
+	for n != nil && n.header.Height > height {
+		n = n.parent
+	}
+
+	return n
+}
+
+// blockIndex keeps the headers of every block known to the node, stored
+// or not yet downloaded, and the best header chain: the longest one, as
+// every block has the same proof-of-work target
+type blockIndex struct {
+	mu         sync.RWMutex
+	nodes      map[string]*blockNode
+	bestHeader *blockNode
+}
+
+// newBlockIndex creates an index of the blocks stored in the blockchain
+func newBlockIndex(bc *Blockchain) *blockIndex {
+	bi := &blockIndex{nodes: make(map[string]*blockNode)}
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+
+		return b.ForEach(func(k, v []byte) error {
+			if bytes.Equal(k, []byte("l")) {
+				return nil
+			}
+			block := DeserializeBlock(v)
+			bi.nodes[hex.EncodeToString(block.Hash)] = &blockNode{header: block.Header(), haveData: true}
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, node := range bi.nodes {
+		node.parent = bi.nodes[hex.EncodeToString(node.header.PrevBlockHash)]
+	}
+	bi.bestHeader = bi.nodes[hex.EncodeToString(bc.Tip)]
+	for _, node := range bi.nodes {
+		if node.header.Height > bi.bestHeader.header.Height {
+			bi.bestHeader = node
+		}
+	}
+
+	return bi
+}
+
+// lookup returns the node of a block hash, nil when it is unknown
+func (bi *blockIndex) lookup(hash []byte) *blockNode {
+	bi.mu.RLock()
+	defer bi.mu.RUnlock()
+
+	return bi.nodes[hex.EncodeToString(hash)]
+}
+
+// best returns the tip of the best header chain
+func (bi *blockIndex) best() *blockNode {
+	bi.mu.RLock()
+	defer bi.mu.RUnlock()
+
+	return bi.bestHeader
+}
+
+// addHeader checks a header against its parent and adds it to the index.
+// A known header is returned as it is.
+func (bi *blockIndex) addHeader(h *BlockHeader) (*blockNode, error) {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	if node, ok := bi.nodes[hex.EncodeToString(h.Hash)]; ok {
+		return node, nil
+	}
+
+	parent, ok := bi.nodes[hex.EncodeToString(h.PrevBlockHash)]
+	if !ok {
+		return nil, ErrHeaderOrphan
+	}
+	if h.Height != parent.header.Height+1 {
+		return nil, ErrHeaderHeight
+	}
+	if !ValidateHeader(h) {
+		return nil, ErrHeaderProof
+	}
+	if time.Unix(h.Timestamp, 0).After(Now().Add(maxFutureBlockTime)) {
+		return nil, ErrHeaderTime
+	}
+
+	return bi.insert(h, parent), nil
+}
+
+// addBlock records that a block is stored in the blockchain, adding its
+// header when it is not indexed yet
+func (bi *blockIndex) addBlock(block *Block) {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	node, ok := bi.nodes[hex.EncodeToString(block.Hash)]
+	if !ok {
+		node = bi.insert(block.Header(), bi.nodes[hex.EncodeToString(block.PrevBlockHash)])
+	}
+	node.haveData = true
+}
+
+// insert adds a header under its parent, making it the best header when it
+// is higher
+func (bi *blockIndex) insert(h *BlockHeader, parent *blockNode) *blockNode {
+	node := &blockNode{header: h, parent: parent}
+	bi.nodes[hex.EncodeToString(h.Hash)] = node
+	if h.Height > bi.bestHeader.header.Height {
+		bi.bestHeader = node
+	}
+
+	return node
+}
+
+// missing returns up to max blocks of the best header chain that are not
+// stored yet, lowest first
+func (bi *blockIndex) missing(max int) []*blockNode {
+	bi.mu.RLock()
+	defer bi.mu.RUnlock()
+
+	var chain []*blockNode
+	for node := bi.bestHeader; node != nil && !node.haveData; node = node.parent {
+		chain = append(chain, node)
+	}
+
+	missing := []*blockNode{}
+	for i := len(chain) - 1; i >= 0 && len(missing) < max; i-- {
+		missing = append(missing, chain[i])
+	}
+
+	return missing
+}
+
+// locator returns the hashes of a chain from node back to the genesis block:
+// the last ten, then ever more spaced ones, so that a peer finds the last
+// block it shares with the chain in a few hashes
+func (bi *blockIndex) locator(node *blockNode) [][]byte {
+	bi.mu.RLock()
+	defer bi.mu.RUnlock()
+
+	locator := [][]byte{}
+	step := 1
+	for node != nil {
+		locator = append(locator, node.header.Hash)
+		if node.parent == nil {
+			break
+		}
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		height := node.header.Height - step
+		if height < 0 {
+			height = 0
+		}
+		node = node.ancestor(height)
+	}
+
+	return locator
+}
+
+// headersAfter returns up to max headers of the chain ending at tip, from
+// the block after the first locator hash on that chain up to stop
+func (bi *blockIndex) headersAfter(tip []byte, locator [][]byte, stop []byte, max int) []BlockHeader {
+	bi.mu.RLock()
+	defer bi.mu.RUnlock()
+
+	tipNode := bi.nodes[hex.EncodeToString(tip)]
+	if tipNode == nil {
+		return nil
+	}
+
+	forkHeight := -1
+	for _, hash := range locator {
+		node := bi.nodes[hex.EncodeToString(hash)]
+		if node != nil && tipNode.ancestor(node.header.Height) == node {
+			forkHeight = node.header.Height
+			break
+		}
+	}
+
+	var chain []*blockNode
+	for node := tipNode; node != nil && node.header.Height > forkHeight; node = node.parent {
+		chain = append(chain, node)
+	}
+
+	headers := []BlockHeader{}
+	for i := len(chain) - 1; i >= 0 && len(headers) < max; i-- {
+		headers = append(headers, *chain[i].header)
+		if bytes.Equal(chain[i].header.Hash, stop) {
+			break
+		}
+	}
+
+	return headers
+}
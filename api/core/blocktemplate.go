@@ -0,0 +1,245 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+// Errors returned when a submitted block is refused
+var (
+	ErrBlockStale       = errors.New("Block does not build on the current tip")
+	ErrBlockBadProof    = errors.New("Block hash does not meet the proof-of-work target")
+	ErrBlockBadCoinbase = errors.New("Block must start with its only coinbase transaction")
+	ErrBlockBadReward   = errors.New("Block coinbase pays more than the subsidy and fees")
+	ErrBlockCoinbaseOut = errors.New("Block coinbase may only pay native coins")
+	ErrBlockBadTxID     = errors.New("Block contains a transaction whose ID is not its hash")
+	ErrBlockDuplicateTx = errors.New("Block contains a transaction whose ID is already used")
+	ErrBlockTooLarge    = errors.New("Block transactions exceed the maximum block size")
+	ErrBlockBadSpend    = errors.New("Block spends unknown or already spent outputs")
+	ErrBlockInvalid     = errors.New("Block contains invalid transactions")
+	ErrBlockMismatch    = errors.New("Block does not match its header")
+)
+
+// BlockTemplate is a block ready to be mined: everything but the nonce and
+// the hash. The coinbase comes first, followed by mempool transactions
+// selected by package fee rate.
+type BlockTemplate struct {
+	PrevBlockHash []byte
+	Height        int
+	Timestamp     int64
+	TargetBits    int
+	Transactions  []*Transaction
+	Fees          Amount
+}
+
+// NewBlockTemplate builds a template on top of the current tip paying the
+// block reward and the fees of its transactions to minerAddress, with at
+// most maxSize bytes of transactions from the mempool
+func NewBlockTemplate(bc *Blockchain, mempool *Mempool, minerAddress string, maxSize int) *BlockTemplate {
+	lastHash, lastHeight := bc.tip()
+
+	coinbase := NewCoinbaseTX(minerAddress, "", lastHeight+1)
+	txs := []*Transaction{coinbase}
+	var fees Amount
+	for _, tx := range mempool.BlockTransactions(maxSize) {
+		if entry, ok := mempool.Entry(tx.ID); ok {
+			fees += entry.Fee
+		}
+		txs = append(txs, tx)
+	}
+	coinbase.Vout[0].Value += fees
+	coinbase.ID = coinbase.Hash()
+
+	return &BlockTemplate{lastHash, lastHeight + 1, Now().Unix(), ActiveParams.TargetBits, txs, fees}
+}
+
+// ID identifies a template by the hash of its block with a zero nonce
+func (t *BlockTemplate) ID() string {
+	return hex.EncodeToString(t.Block(0).Hash)
+}
+
+// Block returns the block of the template with the given nonce
+func (t *BlockTemplate) Block(nonce int) *Block {
+	block := &Block{t.Timestamp, t.Transactions, t.PrevBlockHash, []byte{}, nonce, t.Height}
+	block.Hash = NewProofOfWork(block).hash(nonce)
+
+	return block
+}
+
+// tip returns the hash and height of the last block
+func (bc *Blockchain) tip() ([]byte, int) {
+	var lastHash []byte
+	var lastHeight int
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		// Bolt's slices are only valid during the transaction
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
+		lastHeight = DeserializeBlock(b.Get(lastHash)).Height
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return lastHash, lastHeight
+}
+
+// SubmitBlock checks a block mined outside of the node builds on the tip,
+// meets the proof-of-work target, only spends unspent outputs and does not
+// pay its miner more than the subsidy and fees, then adds it to the chain
+func (bc *Blockchain) SubmitBlock(block *Block) error {
+	lastHash, lastHeight := bc.tip()
+	if !bytes.Equal(block.PrevBlockHash, lastHash) || block.Height != lastHeight+1 {
+		return ErrBlockStale
+	}
+
+	if !NewProofOfWork(block).Validate() {
+		return ErrBlockBadProof
+	}
+
+	if err := checkBlock(block); err != nil {
+		return err
+	}
+	if err := bc.checkTransactions(block); err != nil {
+		return err
+	}
+
+	bc.AddBlock(block)
+
+	return nil
+}
+
+// checkBlock checks that a block starts with its only coinbase transaction,
+// which pays native coins only, that the ID of every transaction is its hash
+// and appears once, and that the block does not exceed the maximum block
+// size. Repeating transactions would otherwise give a block the Merkle root,
+// and so the hash, of another one, see NewMerkleTree.
+func checkBlock(block *Block) error {
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return ErrBlockBadCoinbase
+	}
+	coinbase := block.Transactions[0]
+	if coinbase.Issuance != nil {
+		return ErrBlockCoinbaseOut
+	}
+	for _, vout := range coinbase.Vout {
+		if len(vout.Asset) > 0 || vout.IsData() {
+			return ErrBlockCoinbaseOut
+		}
+	}
+	ids := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		if !bytes.Equal(tx.ID, tx.unsignedHash()) {
+			return ErrBlockBadTxID
+		}
+		id := hex.EncodeToString(tx.ID)
+		if ids[id] {
+			return ErrBlockDuplicateTx
+		}
+		ids[id] = true
+	}
+	size := 0
+	for _, tx := range block.Transactions[1:] {
+		if tx.IsCoinbase() {
+			return ErrBlockBadCoinbase
+		}
+		size += len(tx.Serialize())
+	}
+	if size > MaxBlockSize {
+		return ErrBlockTooLarge
+	}
+
+	return nil
+}
+
+// checkTransactions checks that a block building on the tip creates no
+// transaction whose outputs are in the UTXO set already, only spends unspent
+// outputs, with valid transactions, and does not pay its miner more than the
+// subsidy and fees
+func (bc *Blockchain) checkTransactions(block *Block) error {
+	UTXOSet := UTXOSet{bc}
+	for _, tx := range block.Transactions {
+		if _, ok := UTXOSet.FindOutputs(tx.ID); ok {
+			return ErrBlockDuplicateTx
+		}
+	}
+	if !bc.checkSpends(block) {
+		return ErrBlockBadSpend
+	}
+	if !bc.VerifyTransactions(block.Transactions) {
+		return ErrBlockInvalid
+	}
+
+	return bc.checkReward(block)
+}
+
+// checkSpends reports whether every input of the block spends an output in
+// the UTXO set or created earlier in the block, and no output twice
+func (bc *Blockchain) checkSpends(block *Block) bool {
+	UTXOSet := UTXOSet{bc}
+	created := make(map[string]int)
+	spent := make(map[string]bool)
+
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				op := outpoint(vin.Txid, vin.Vout)
+				if spent[op] {
+					return false
+				}
+				spent[op] = true
+
+				if n, ok := created[hex.EncodeToString(vin.Txid)]; ok {
+					if vin.Vout < 0 || vin.Vout >= n {
+						return false
+					}
+					continue
+				}
+				outs, ok := UTXOSet.FindOutputs(vin.Txid)
+				if !ok {
+					return false
+				}
+				if _, ok := outs.Outputs[vin.Vout]; !ok {
+					return false
+				}
+			}
+		}
+		created[hex.EncodeToString(tx.ID)] = len(tx.Vout)
+	}
+
+	return true
+}
+
+// checkReward checks that the coinbase of a block whose spends were checked
+// pays no more than the subsidy of its height and the fees of its transactions
+func (bc *Blockchain) checkReward(block *Block) error {
+	prevTXs, err := bc.findPrevTransactions(block.Transactions)
+	if err != nil {
+		return ErrBlockBadSpend
+	}
+
+	reward := ActiveParams.BlockSubsidy(block.Height)
+	for _, tx := range block.Transactions[1:] {
+		if reward, err = reward.Add(tx.Fee(prevTXs)); err != nil {
+			return ErrBlockBadReward
+		}
+	}
+
+	var paid Amount
+	for _, vout := range block.Transactions[0].Vout {
+		if paid, err = paid.Add(vout.Value); err != nil {
+			return ErrBlockBadReward
+		}
+	}
+	if paid > reward {
+		return ErrBlockBadReward
+	}
+
+	return nil
+}
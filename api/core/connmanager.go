@@ -0,0 +1,243 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default connection counts of a node
+const (
+	DefaultTargetOutbound = 8
+	DefaultMaxInbound     = 32
+)
+
+// connectInterval is how often the connection manager dials known addresses
+// while it has fewer outbound peers than its target
+const connectInterval = 30 * time.Second
+
+// maxDialsPerRound is the number of addresses dialed at most each connectInterval
+const maxDialsPerRound = 16
+
+// ConnConfig configures the peer connections of a node
+type ConnConfig struct {
+	TargetOutbound int // outbound connections the node keeps open
+	MaxInbound     int // inbound connections accepted at most
+}
+
+// ConnManager keeps the peers of a node. It dials addresses of the address
+// manager until the target number of outbound connections is reached and
+// refuses inbound connections beyond the maximum.
+type ConnManager struct {
+	bc     *Blockchain
+	config ConnConfig
+
+	mu       sync.Mutex
+	peers    map[*Peer]bool  // peers whose handshake is done
+	dialing  map[string]bool // addresses being dialed
+	inbound  int             // inbound connections, handshaking ones included
+	outbound int             // outbound connections, dials included
+}
+
+// connManager is the connection manager of the running node, nil in
+// command line clients
+var connManager *ConnManager
+
+// NewConnManager creates a connection manager for the blockchain. It does
+// nothing until started.
+func NewConnManager(bc *Blockchain, config ConnConfig) *ConnManager {
+	if config.TargetOutbound < 0 {
+		config.TargetOutbound = 0
+	}
+	if config.MaxInbound < 0 {
+		config.MaxInbound = 0
+	}
+
+	return &ConnManager{
+		bc:      bc,
+		config:  config,
+		peers:   make(map[*Peer]bool),
+		dialing: make(map[string]bool),
+	}
+}
+
+// Start dials known addresses in the background, then again every
+// connectInterval while outbound connections are missing
+func (cm *ConnManager) Start() {
+	go func() {
+		cm.fillOutbound()
+		for range time.Tick(connectInterval) {
+			cm.fillOutbound()
+		}
+	}()
+}
+
+// fillOutbound dials addresses of the address manager not connected yet
+// until the target number of outbound connections is reached
+func (cm *ConnManager) fillOutbound() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	skip := func(addr string) bool {
+		return addr == nodeAddress || cm.dialing[addr] || cm.peerLocked(addr) != nil
+	}
+	for i := 0; i < maxDialsPerRound && cm.outbound < cm.config.TargetOutbound; i++ {
+		ka := addrManager.GetAddress(skip)
+		if ka == nil {
+			return
+		}
+
+		addrManager.Attempt(ka.Addr)
+		cm.dialing[ka.Addr] = true
+		cm.outbound++
+		go cm.connect(ka.Addr)
+	}
+}
+
+// connect dials a node and runs the peer until it disconnects
+func (cm *ConnManager) connect(addr string) {
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
+	if err != nil {
+		fmt.Printf("%s is not available\n", addr)
+		cm.mu.Lock()
+		delete(cm.dialing, addr)
+		cm.outbound--
+		cm.mu.Unlock()
+		return
+	}
+
+	cm.runPeer(newPeer(conn, addr, false))
+}
+
+// HandleInbound takes a connection accepted by the listener of the node.
+// It is closed at once when the node has its maximum of inbound peers.
+func (cm *ConnManager) HandleInbound(conn net.Conn) {
+	cm.mu.Lock()
+	if cm.inbound >= cm.config.MaxInbound {
+		cm.mu.Unlock()
+		fmt.Printf("Refused connection from %s: too many inbound peers\n", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+	cm.inbound++
+	cm.mu.Unlock()
+
+	go cm.runPeer(newPeer(conn, "", true))
+}
+
+// runPeer runs the handshake with a peer, adds it and handles its messages
+// until it disconnects
+func (cm *ConnManager) runPeer(p *Peer) {
+	defer cm.release(p)
+
+	err := p.handshake(cm.bc.GetBestHeight())
+	if err != nil {
+		fmt.Printf("Handshake with %s failed: %s\n", p, err)
+		p.Disconnect()
+		return
+	}
+	if !cm.add(p) {
+		fmt.Printf("Already connected to %s\n", p)
+		p.Disconnect()
+		return
+	}
+	defer cm.remove(p)
+
+	fmt.Printf("Connected to %s, protocol %d, height %d\n", p, p.Version, p.BestHeight)
+	p.start(cm.bc)
+	if p.Addr != "" {
+		cm.exchangeAddrs(p)
+	}
+	syncManager.PeerConnected(p)
+
+	<-p.Done()
+	syncManager.PeerDisconnected(p)
+	if !p.Inbound {
+		addrManager.Connected(p.Addr)
+	}
+	fmt.Printf("Disconnected from %s\n", p)
+}
+
+// exchangeAddrs records the address of a new peer, advertises the address
+// of this node to it and asks outbound peers for the addresses they know
+func (cm *ConnManager) exchangeAddrs(p *Peer) {
+	now := time.Now().Unix()
+	if p.Inbound {
+		if addr := p.listenAddress(); addr != "" {
+			addrManager.AddAddresses([]netAddress{{addr, p.Services, now}}, addr)
+		}
+	} else {
+		addrManager.Good(p.Addr, p.Services)
+		sendGetAddr(p)
+	}
+	if nodeAddress != "" {
+		sendAddr(p, []netAddress{{nodeAddress, localServices, now}})
+	}
+}
+
+// add adds a peer whose handshake is done, unless the node is already
+// connected to the same address
+func (cm *ConnManager) add(p *Peer) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if addr := p.address(); addr != "" && cm.peerLocked(addr) != nil {
+		return false
+	}
+	cm.peers[p] = true
+
+	return true
+}
+
+// remove forgets a disconnected peer
+func (cm *ConnManager) remove(p *Peer) {
+	cm.mu.Lock()
+	delete(cm.peers, p)
+	cm.mu.Unlock()
+}
+
+// release gives back the connection slot of a peer
+func (cm *ConnManager) release(p *Peer) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if p.Inbound {
+		cm.inbound--
+	} else {
+		delete(cm.dialing, p.Addr)
+		cm.outbound--
+	}
+}
+
+// Peer returns the connected peer listening on addr, nil when there is none
+func (cm *ConnManager) Peer(addr string) *Peer {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return cm.peerLocked(addr)
+}
+
+// peerLocked is Peer for callers holding the lock
+func (cm *ConnManager) peerLocked(addr string) *Peer {
+	for p := range cm.peers {
+		if p.address() == addr {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Peers returns the connected peers
+func (cm *ConnManager) Peers() []*Peer {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	peers := make([]*Peer, 0, len(cm.peers))
+	for p := range cm.peers {
+		peers = append(peers, p)
+	}
+
+	return peers
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+	"github.com/NlaakStudios/Blockchain/api/config"
+)
+
+// DefaultFeeRate is the fee in base units per byte paid when none is given
+var DefaultFeeRate = Amount(config.CoinFeeRate)
+
+// dustLimit is the smallest change output worth creating
+var dustLimit = Amount(config.CoinDustLimit)
+
+// Errors returned when the fee of a transaction cannot be bumped
+var (
+	ErrBumpFeeNotFound       = errors.New("Transaction is not in the mempool")
+	ErrBumpFeeNotReplaceable = errors.New("Transaction did not opt in to replace-by-fee")
+	ErrBumpFeeNotOwned       = errors.New("Transaction does not spend the wallet's outputs")
+	ErrBumpFeeNoChange       = errors.New("Transaction has no change output large enough to pay the new fee")
+)
+
+// sigSize is the size of P-256 and Ed25519 signatures
+const sigSize = 64
+
+// selectParams measures a transaction about to spend more of the wallet's
+// coins, with change going to the given address, and returns coin selection
+// parameters for it. The sizes are those of the serialized transaction, so
+// they are estimates of the real ones.
+func selectParams(wallet *Wallet, tx Transaction, change string, feeRate Amount) coinselect.Params {
+	tx.ID = make([]byte, 32)
+	base := len(tx.Serialize())
+
+	dummy := TXInput{make([]byte, 32), 0, make([]byte, sigSize), wallet.PublicKey, wallet.InputScheme(), SequenceFinal}
+	tx.Vin = append(append([]TXInput{}, tx.Vin...), dummy)
+	withInput := len(tx.Serialize())
+
+	tx.Vout = append(append([]TXOutput{}, tx.Vout...), *NewTXOutput(1, change))
+	withChange := len(tx.Serialize())
+
+	return coinselect.Params{
+		FeeRate:    uint64(feeRate),
+		BaseSize:   base,
+		InputSize:  withInput - base,
+		ChangeSize: withChange - withInput,
+		DustLimit:  uint64(dustLimit),
+	}
+}
+
+// selectInputs runs a coin selection strategy over the wallet's outputs of
+// an asset and returns the chosen inputs in a stable order. With a mempool,
+// its unconfirmed outputs may be chosen and the outputs it spends are not.
+func selectInputs(wallet *Wallet, asset []byte, strategy coinselect.Strategy, params coinselect.Params, UTXOSet *UTXOSet, mempool *Mempool) ([]TXInput, *coinselect.Selection) {
+	return selectPooledInputs([]*Wallet{wallet}, asset, strategy, params, UTXOSet, mempool)
+}
+
+// selectPooledInputs is selectInputs over the outputs of several wallets,
+// each input carrying the public key of the wallet whose output it spends
+func selectPooledInputs(wallets []*Wallet, asset []byte, strategy coinselect.Strategy, params coinselect.Params, UTXOSet *UTXOSet, mempool *Mempool) ([]TXInput, *coinselect.Selection) {
+	var coins []coinselect.Coin
+	owners := make(map[string]*Wallet)
+	for _, wallet := range wallets {
+		for _, coin := range UTXOSet.FindCoins(HashPubKey(wallet.PublicKey), asset, mempool) {
+			owners[fmt.Sprintf("%s:%d", coin.TxID, coin.Vout)] = wallet
+			coins = append(coins, coin)
+		}
+	}
+
+	selection, err := strategy(coins, params)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	var inputs []TXInput
+	for _, coin := range selection.Coins {
+		inputs = append(inputs, newInput(owners[fmt.Sprintf("%s:%d", coin.TxID, coin.Vout)], coin.TxID, coin.Vout))
+	}
+
+	return inputs, selection
+}
+
+// signTransaction signs the inputs of tx, looking up the outputs they spend
+// in the mempool, if any, and the UTXO set
+func signTransaction(tx *Transaction, wallet *Wallet, UTXOSet *UTXOSet, mempool *Mempool) {
+	tx.Sign(wallet, findPrevTXs(tx, UTXOSet, mempool))
+}
+
+// findPrevTXs returns the transactions whose outputs the inputs of tx spend,
+// from the mempool, if any, or rebuilt from the UTXO set
+func findPrevTXs(tx *Transaction, UTXOSet *UTXOSet, mempool *Mempool) map[string]Transaction {
+	prevTXs := make(map[string]Transaction)
+
+	for _, vin := range tx.Vin {
+		prevID := hex.EncodeToString(vin.Txid)
+		if mempool != nil {
+			if parent, ok := mempool.Get(vin.Txid); ok {
+				prevTXs[prevID] = parent
+				continue
+			}
+		}
+
+		outs, ok := UTXOSet.FindOutputs(vin.Txid)
+		if !ok {
+			log.Panic("ERROR: Previous transaction is not correct")
+		}
+		prevTXs[prevID] = outs.transaction(vin.Txid)
+	}
+
+	return prevTXs
+}
+
+// Fee returns the native coins left to the miner by a transaction: its
+// native inputs minus its native outputs
+func (tx *Transaction) Fee(prevTXs map[string]Transaction) Amount {
+	if tx.IsCoinbase() {
+		return 0
+	}
+
+	var in, out Amount
+	for _, vin := range tx.Vin {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+		if len(prevOut.Asset) == 0 {
+			in += prevOut.Value
+		}
+	}
+	for _, vout := range tx.Vout {
+		if len(vout.Asset) == 0 {
+			out += vout.Value
+		}
+	}
+	if out > in {
+		return 0
+	}
+
+	return in - out
+}
+
+// BumpFee creates a replacement of a transaction waiting in the mempool
+// paying feeRate base units per byte, or the old rate plus DefaultFeeRate
+// when feeRate is 0. The fee is raised to at least what the replacement
+// rules ask for, and taken from the change output, back to the sender or
+// on the change chain of the wallets, which is dropped when what is left of
+// it would be dust.
+func BumpFee(txID []byte, wallets *Wallets, feeRate Amount, UTXOSet *UTXOSet, mempool *Mempool) (*Transaction, error) {
+	entry, ok := mempool.Entry(txID)
+	if !ok {
+		return nil, ErrBumpFeeNotFound
+	}
+	if !entry.Tx.IsReplaceable() {
+		return nil, ErrBumpFeeNotReplaceable
+	}
+	_, wallet := wallets.FindByPubKey(entry.Tx.Vin[0].PubKey)
+	if wallet == nil {
+		return nil, ErrBumpFeeNotOwned
+	}
+	for _, vin := range entry.Tx.Vin {
+		if !bytes.Equal(vin.PubKey, wallet.PublicKey) {
+			return nil, ErrBumpFeeNotOwned
+		}
+	}
+
+	var err error
+	if feeRate == 0 {
+		if feeRate, err = (entry.Fee / Amount(entry.Size)).Add(DefaultFeeRate); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lowering an output never makes the transaction larger
+	size := uint64(entry.Size)
+	fee, err := feeRate.Mul(size)
+	if err != nil {
+		return nil, err
+	}
+	min, err := minRelayFeeRate.Mul(size)
+	if err != nil {
+		return nil, err
+	}
+	if min, err = min.Add(mempool.DescendantFees(txID)); err != nil {
+		return nil, err
+	}
+	if fee < min {
+		fee = min
+	}
+
+	tx := Transaction{nil, append([]TXInput{}, entry.Tx.Vin...), append([]TXOutput{}, entry.Tx.Vout...), entry.Tx.Issuance}
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+	change := -1
+	for outIdx := len(tx.Vout) - 1; outIdx > 0; outIdx-- {
+		out := tx.Vout[outIdx]
+		if len(out.Asset) == 0 && (out.IsLockedWithKey(pubKeyHash) || wallets.isChange(out.PubKeyHash)) {
+			change = outIdx
+			break
+		}
+	}
+	if change < 0 {
+		return nil, ErrBumpFeeNoChange
+	}
+
+	extra := fee - entry.Fee
+	switch value := tx.Vout[change].Value; {
+	case value < extra:
+		return nil, ErrBumpFeeNoChange
+	case value-extra < dustLimit:
+		tx.Vout = append(tx.Vout[:change], tx.Vout[change+1:]...)
+	default:
+		tx.Vout[change].Value = value - extra
+	}
+
+	for i := range tx.Vin {
+		tx.Vin[i].Signature = nil
+	}
+	tx.ID = tx.Hash()
+	signTransaction(&tx, wallet, UTXOSet, mempool)
+
+	return &tx, nil
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/hd"
+)
+
+// HDGapLimit is the number of unused addresses in a row after which a
+// chain of an HD wallet is assumed to hold no more used addresses
+const HDGapLimit = 20
+
+// Chains of the addresses of an HD wallet
+const (
+	// ChainReceive holds the addresses handed out for payments
+	ChainReceive uint32 = 0
+	// ChainChange holds the addresses receiving change
+	ChainChange uint32 = 1
+)
+
+// Errors returned by HD wallets
+var (
+	ErrWalletNotHD  = errors.New("Wallet has no HD seed")
+	ErrWalletHasHD  = errors.New("Wallet already has a different HD seed")
+	ErrSchemeNotHD  = errors.New("Keys of this signature scheme cannot be derived from the HD seed")
+	ErrHDSeedLocked = errors.New("HD seed is locked, unlock the wallet with its passphrase first")
+)
+
+// HDChain is the seed of the deterministic keys of a wallet file, kept as a
+// BIP39 mnemonic, and how many keys were handed out on each chain. Keys of a
+// scheme live at m/44'/coin type'/scheme'/chain/index, every level hardened
+// for Ed25519.
+type HDChain struct {
+	Mnemonic string                 // empty while an encrypted wallet file is locked
+	Next     map[SigScheme][]uint32 // next index of the receive and change chains
+}
+
+// IsHD reports whether the wallet file derives its keys from a seed
+func (ws *Wallets) IsHD() bool {
+	return ws.HD != nil
+}
+
+// SetMnemonic makes the wallet file derive new keys from the seed of a
+// mnemonic. Giving the mnemonic the wallet already has is not an error.
+func (ws *Wallets) SetMnemonic(mnemonic string) error {
+	mnemonic, err := hd.NormalizeMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	if ws.HD != nil {
+		if ws.HD.Mnemonic != mnemonic {
+			return ErrWalletHasHD
+		}
+		return nil
+	}
+
+	ws.HD = &HDChain{mnemonic, make(map[SigScheme][]uint32)}
+	ws.seed = nil
+
+	return ws.sealMnemonic()
+}
+
+// NewAddress derives the next key of a chain, adds it to the wallets and
+// returns its address
+func (ws *Wallets) NewAddress(scheme SigScheme, chain uint32) (string, error) {
+	next := ws.nextIndexes(scheme)
+	wallet, err := ws.deriveWallet(scheme, chain, next[chain])
+	if err != nil {
+		return "", err
+	}
+
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+	if err := ws.addKey(address, wallet); err != nil {
+		return "", err
+	}
+	ws.Wallets[address] = wallet
+	ws.noteAddress(address, chainPurpose(chain))
+	next[chain]++
+
+	return address, nil
+}
+
+// ChangeAddress returns a new change address for a wallet of the given
+// scheme, or an empty one, meaning the change goes back to the sender, when
+// its keys are not derived from a seed
+func (ws *Wallets) ChangeAddress(scheme SigScheme) string {
+	if !ws.IsHD() {
+		return ""
+	}
+	if _, err := hdCurve(scheme); err != nil {
+		return ""
+	}
+
+	address, err := ws.NewAddress(scheme, ChainChange)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return address
+}
+
+// Discover adds the used addresses of the seed to the wallets. Each chain
+// is scanned until HDGapLimit addresses in a row were never paid to, and
+// continues after the last used one. It returns the number of addresses
+// found. used holds the hex encoded public key hashes seen on the chain.
+func (ws *Wallets) Discover(used map[string]bool) (int, error) {
+	found := 0
+	for _, scheme := range []SigScheme{SchemeP256, SchemeEd25519} {
+		next := ws.nextIndexes(scheme)
+		for _, chain := range []uint32{ChainReceive, ChainChange} {
+			var wallets []*Wallet
+			for index, gap := uint32(0), 0; gap < HDGapLimit; index++ {
+				wallet, err := ws.deriveWallet(scheme, chain, index)
+				if err != nil {
+					return found, err
+				}
+				wallets = append(wallets, wallet)
+
+				gap++
+				if used[hex.EncodeToString(HashPubKey(wallet.PublicKey))] {
+					gap = 0
+					if index >= next[chain] {
+						next[chain] = index + 1
+					}
+				}
+			}
+
+			for index, wallet := range wallets {
+				if uint32(index) >= next[chain] {
+					break
+				}
+				if _, known := ws.FindByPubKey(wallet.PublicKey); known != nil {
+					continue
+				}
+				address := fmt.Sprintf("%s", wallet.GetAddress())
+				if err := ws.addKey(address, wallet); err != nil {
+					return found, err
+				}
+				ws.Wallets[address] = wallet
+				ws.noteAddress(address, chainPurpose(chain))
+				found++
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// isChange reports whether a public key hash is of an address on the
+// change chain of the seed
+func (ws *Wallets) isChange(pubKeyHash []byte) bool {
+	for _, w := range ws.Wallets {
+		if w.Path != "" && bytes.Equal(HashPubKey(w.PublicKey), pubKeyHash) {
+			return hdChain(w.Path) == ChainChange
+		}
+	}
+
+	return false
+}
+
+// hdChain returns the chain of an HD derivation path
+func hdChain(path string) uint32 {
+	indexes, err := hd.ParsePath(path)
+	if err != nil || len(indexes) < 2 {
+		return ChainReceive
+	}
+
+	return indexes[len(indexes)-2] &^ hd.Hardened
+}
+
+// nextIndexes returns the next indexes of the chains of a scheme, which
+// may be updated in place
+func (ws *Wallets) nextIndexes(scheme SigScheme) []uint32 {
+	next, ok := ws.HD.Next[scheme]
+	if !ok {
+		next = make([]uint32, 2)
+		ws.HD.Next[scheme] = next
+	}
+
+	return next
+}
+
+// deriveWallet derives the key at an index of a chain of the seed
+func (ws *Wallets) deriveWallet(scheme SigScheme, chain, index uint32) (*Wallet, error) {
+	if !ws.IsHD() {
+		return nil, ErrWalletNotHD
+	}
+	if ws.HD.Mnemonic == "" {
+		return nil, ErrHDSeedLocked
+	}
+	curve, err := hdCurve(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	if ws.seed == nil {
+		ws.seed = hd.NewSeed(ws.HD.Mnemonic, "")
+	}
+	master, err := hd.NewMasterKey(ws.seed, curve)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []uint32{44 + hd.Hardened, config.CoinHDType + hd.Hardened, uint32(scheme) + hd.Hardened, chain, index}
+	if curve == hd.Ed25519 {
+		path[3] += hd.Hardened
+		path[4] += hd.Hardened
+	}
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	impl, err := GetScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := impl.WalletFromKey(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	wallet.Path = hd.FormatPath(path)
+
+	return wallet, nil
+}
+
+// chainPurpose returns the purpose of the addresses of a chain
+func chainPurpose(chain uint32) string {
+	if chain == ChainChange {
+		return PurposeChange
+	}
+
+	return PurposeReceive
+}
+
+// hdCurve returns the curve keys of a scheme are derived on
+func hdCurve(scheme SigScheme) (hd.Curve, error) {
+	switch scheme {
+	case SchemeP256:
+		return hd.P256, nil
+	case SchemeEd25519:
+		return hd.Ed25519, nil
+	}
+
+	return 0, ErrSchemeNotHD
+}
+
+// UsedPubKeyHashes returns the hex encoded public key hashes that were ever
+// paid to or spent from on the chain
+func (bc *Blockchain) UsedPubKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if !out.IsData() {
+					used[hex.EncodeToString(out.PubKeyHash)] = true
+				}
+			}
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Vin {
+					used[hex.EncodeToString(HashPubKey(in.PubKey))] = true
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
@@ -0,0 +1,723 @@
+package core
+
+import (
+	"container/heap"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+)
+
+// defaultMaxMempoolSize is the total size in bytes of the transactions a
+// mempool holds before it starts evicting the cheapest ones
+const defaultMaxMempoolSize = 32 << 20
+
+// defaultMempoolTTL is how long a transaction may wait for a block
+const defaultMempoolTTL = 72 * time.Hour
+
+// maxUnconfirmedChain is the longest chain of unconfirmed transactions
+// spending each other, counting the last one
+const maxUnconfirmedChain = 25
+
+// maxReplacementEvictions is the most transactions a replacement may evict
+const maxReplacementEvictions = 100
+
+// minRelayFeeRate is the lowest fee in base units per byte accepted into the mempool
+var minRelayFeeRate = Amount(1)
+
+// Errors returned when a transaction is refused by the mempool
+var (
+	ErrMempoolDuplicate     = errors.New("Transaction is already in the mempool")
+	ErrMempoolConfirmed     = errors.New("Transaction is already confirmed")
+	ErrMempoolCoinbase      = errors.New("Coinbase transactions are not accepted into the mempool")
+	ErrMempoolMissingInputs = errors.New("Transaction spends unknown or spent outputs")
+	ErrMempoolConflict      = errors.New("Transaction conflicts with a transaction in the mempool")
+	ErrMempoolInvalid       = errors.New("Transaction is invalid")
+	ErrMempoolFeeTooLow     = errors.New("Transaction fee is below the minimum relay fee")
+	ErrMempoolFull          = errors.New("Mempool is full")
+	ErrMempoolExpired       = errors.New("Transaction waited too long for a block")
+	ErrMempoolChainTooLong  = errors.New("Transaction has too many unconfirmed ancestors")
+	ErrMempoolReplacement   = errors.New("Replacement does not pay enough fee or evicts too many transactions")
+)
+
+// MempoolEntry is a transaction waiting in the mempool
+type MempoolEntry struct {
+	Tx   Transaction
+	Fee  Amount
+	Size int
+	Time time.Time
+	seq  uint64
+
+	// Totals of the entry with its ancestors, or with its descendants, in
+	// the pool, kept up to date as transactions come and go
+	ancestorFee    Amount
+	ancestorSize   int
+	ancestorCount  int
+	descendantFee  Amount
+	descendantSize int
+}
+
+// FeeRate returns the fee of the entry in base units per byte
+func (e *MempoolEntry) FeeRate() float64 {
+	return float64(e.Fee) / float64(e.Size)
+}
+
+// Mempool holds validated transactions that are not in a block yet. It is
+// safe for concurrent use.
+type Mempool struct {
+	mu      sync.RWMutex
+	entries map[string]*MempoolEntry
+	spends  map[string]string // outpoint -> ID of the transaction spending it
+	size    int
+	seq     uint64
+	maxSize int
+	ttl     time.Duration
+}
+
+// NewMempool creates an empty mempool holding at most maxSize bytes of
+// transactions, each for at most ttl
+func NewMempool(maxSize int, ttl time.Duration) *Mempool {
+	return &Mempool{
+		entries: make(map[string]*MempoolEntry),
+		spends:  make(map[string]string),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+// outpoint identifies an output spent by an input
+func outpoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// Add validates a transaction against the UTXO set and the transactions
+// already in the pool, then adds it. A transaction spending outputs already
+// spent in the pool replaces the conflicting transactions, and their
+// descendants, if they opted in to replace-by-fee and it pays more. The
+// cheapest transactions are evicted when the pool grows over its size
+// limit, which may be the new one.
+func (m *Mempool) Add(tx *Transaction, UTXOSet *UTXOSet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.add(tx, UTXOSet, Now())
+}
+
+// add adds a transaction that arrived at the given time
+func (m *Mempool) add(tx *Transaction, UTXOSet *UTXOSet, arrived time.Time) error {
+	m.expire(Now())
+	if Now().Sub(arrived) > m.ttl {
+		return ErrMempoolExpired
+	}
+
+	entry, conflicts, err := m.validate(tx, UTXOSet)
+	if err != nil {
+		return err
+	}
+	entry.Time = arrived
+
+	for txID := range conflicts {
+		m.remove(txID)
+	}
+	m.insert(entry)
+	m.trim()
+
+	if _, ok := m.entries[hex.EncodeToString(tx.ID)]; !ok {
+		return ErrMempoolFull
+	}
+
+	return nil
+}
+
+// validate checks a transaction may enter the pool and returns its entry
+// and the IDs of the transactions it replaces, with their descendants
+func (m *Mempool) validate(tx *Transaction, UTXOSet *UTXOSet) (*MempoolEntry, map[string]bool, error) {
+	txID := hex.EncodeToString(tx.ID)
+
+	if tx.IsCoinbase() {
+		return nil, nil, ErrMempoolCoinbase
+	}
+	if _, ok := m.entries[txID]; ok {
+		return nil, nil, ErrMempoolDuplicate
+	}
+	if _, ok := UTXOSet.FindOutputs(tx.ID); ok {
+		return nil, nil, ErrMempoolConfirmed
+	}
+
+	prevTXs, conflicts, err := m.prevTransactions(tx, UTXOSet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !tx.checkDataOutputs(prevTXs) || !tx.checkAssets(prevTXs) {
+		return nil, nil, ErrMempoolInvalid
+	}
+
+	if tx.Issuance != nil {
+		if _, err := UTXOSet.FindAsset(tx.Issuance.Symbol); err == nil {
+			return nil, nil, ErrMempoolInvalid
+		}
+		for id, e := range m.entries {
+			if e.Tx.Issuance != nil && e.Tx.Issuance.Symbol == tx.Issuance.Symbol && !conflicts[id] {
+				return nil, nil, ErrMempoolConflict
+			}
+		}
+	}
+
+	ancestors := m.ancestors(tx)
+	if len(ancestors)+1 > maxUnconfirmedChain {
+		return nil, nil, ErrMempoolChainTooLong
+	}
+
+	checks, ok := tx.sigChecks(prevTXs)
+	if !ok || !verifySigChecks(checks) {
+		return nil, nil, ErrMempoolInvalid
+	}
+
+	entry := &MempoolEntry{Tx: *tx, Fee: tx.Fee(prevTXs), Size: len(tx.Serialize()), Time: Now()}
+	if minFee, err := minRelayFeeRate.Mul(uint64(entry.Size)); err != nil || entry.Fee < minFee {
+		return nil, nil, ErrMempoolFeeTooLow
+	}
+
+	// The package totals insert keeps must fit in an Amount
+	ancestorFee := entry.Fee
+	for ancestorID := range ancestors {
+		ancestor := m.entries[ancestorID]
+		if ancestorFee, err = ancestorFee.Add(ancestor.Fee); err != nil {
+			return nil, nil, ErrMempoolInvalid
+		}
+		if _, err = ancestor.descendantFee.Add(entry.Fee); err != nil {
+			return nil, nil, ErrMempoolInvalid
+		}
+	}
+
+	evicted, err := m.checkReplacement(entry, conflicts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, evicted, nil
+}
+
+// checkReplacement applies the replace-by-fee rules to a transaction
+// conflicting with transactions in the pool. Every conflicting transaction
+// must have opted in and pay a lower fee rate, and the replacement must pay
+// the fees of all it evicts plus the minimum relay fee for itself. It
+// returns the IDs of the transactions evicted.
+func (m *Mempool) checkReplacement(entry *MempoolEntry, conflicts map[string]bool) (map[string]bool, error) {
+	evicted := make(map[string]bool)
+
+	for txID := range conflicts {
+		conflict := m.entries[txID]
+		if !conflict.Tx.IsReplaceable() {
+			return nil, ErrMempoolConflict
+		}
+		if entry.FeeRate() <= conflict.FeeRate() {
+			return nil, ErrMempoolReplacement
+		}
+		m.descendants(txID, evicted)
+	}
+	if len(evicted) > maxReplacementEvictions {
+		return nil, ErrMempoolReplacement
+	}
+
+	minFee, err := minRelayFeeRate.Mul(uint64(entry.Size))
+	if err != nil {
+		return nil, ErrMempoolReplacement
+	}
+	for txID := range evicted {
+		if minFee, err = minFee.Add(m.entries[txID].Fee); err != nil {
+			return nil, ErrMempoolReplacement
+		}
+	}
+	if entry.Fee < minFee {
+		return nil, ErrMempoolReplacement
+	}
+
+	for txID := range m.ancestors(&entry.Tx) {
+		if evicted[txID] {
+			return nil, ErrMempoolConflict
+		}
+	}
+
+	return evicted, nil
+}
+
+// prevTransactions collects the outputs spent by tx from the pool and the
+// UTXO set. Transactions found in the UTXO set only carry their unspent
+// outputs, which is all validation looks at. It also returns the IDs of the
+// transactions in the pool spending the same outputs.
+func (m *Mempool) prevTransactions(tx *Transaction, UTXOSet *UTXOSet) (map[string]Transaction, map[string]bool, error) {
+	prevTXs := make(map[string]Transaction)
+	conflicts := make(map[string]bool)
+	spent := make(map[string]bool)
+
+	for _, vin := range tx.Vin {
+		op := outpoint(vin.Txid, vin.Vout)
+		if spent[op] {
+			return nil, nil, ErrMempoolInvalid
+		}
+		spent[op] = true
+		if conflict, ok := m.spends[op]; ok {
+			conflicts[conflict] = true
+		}
+
+		prevID := hex.EncodeToString(vin.Txid)
+		if parent, ok := m.entries[prevID]; ok {
+			if vin.Vout < 0 || vin.Vout >= len(parent.Tx.Vout) || parent.Tx.Vout[vin.Vout].IsData() {
+				return nil, nil, ErrMempoolMissingInputs
+			}
+			prevTXs[prevID] = parent.Tx
+			continue
+		}
+
+		outs, ok := UTXOSet.FindOutputs(vin.Txid)
+		if !ok {
+			return nil, nil, ErrMempoolMissingInputs
+		}
+		if _, ok := outs.Outputs[vin.Vout]; !ok {
+			return nil, nil, ErrMempoolMissingInputs
+		}
+		if _, ok := prevTXs[prevID]; !ok {
+			prevTXs[prevID] = outs.transaction(vin.Txid)
+		}
+	}
+
+	return prevTXs, conflicts, nil
+}
+
+// ancestors returns the IDs of the transactions in the pool tx depends on
+func (m *Mempool) ancestors(tx *Transaction) map[string]bool {
+	result := make(map[string]bool)
+	queue := []*Transaction{tx}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, vin := range current.Vin {
+			prevID := hex.EncodeToString(vin.Txid)
+			if parent, ok := m.entries[prevID]; ok && !result[prevID] {
+				result[prevID] = true
+				queue = append(queue, &parent.Tx)
+			}
+		}
+	}
+
+	return result
+}
+
+// descendants adds txID and every transaction in the pool spending its
+// outputs, directly or not, to result
+func (m *Mempool) descendants(txID string, result map[string]bool) {
+	entry, ok := m.entries[txID]
+	if !ok || result[txID] {
+		return
+	}
+	result[txID] = true
+
+	for outIdx := range entry.Tx.Vout {
+		if child, ok := m.spends[outpoint(entry.Tx.ID, outIdx)]; ok {
+			m.descendants(child, result)
+		}
+	}
+}
+
+// descendantScore is the fee rate evicting an entry loses: its own rate, or
+// the rate of its package with its descendants when they pay more, so that
+// a child paying for its parent keeps both in the pool
+func (e *MempoolEntry) descendantScore() float64 {
+	score := e.FeeRate()
+	if rate := float64(e.descendantFee) / float64(e.descendantSize); rate > score {
+		score = rate
+	}
+
+	return score
+}
+
+// insert adds a validated entry and counts it in the totals of its ancestors
+func (m *Mempool) insert(entry *MempoolEntry) {
+	m.seq++
+	entry.seq = m.seq
+
+	entry.ancestorFee, entry.ancestorSize, entry.ancestorCount = entry.Fee, entry.Size, 1
+	entry.descendantFee, entry.descendantSize = entry.Fee, entry.Size
+	for ancestorID := range m.ancestors(&entry.Tx) {
+		ancestor := m.entries[ancestorID]
+		entry.ancestorFee += ancestor.Fee
+		entry.ancestorSize += ancestor.Size
+		entry.ancestorCount++
+		ancestor.descendantFee += entry.Fee
+		ancestor.descendantSize += entry.Size
+	}
+
+	txID := hex.EncodeToString(entry.Tx.ID)
+	m.entries[txID] = entry
+	for _, vin := range entry.Tx.Vin {
+		m.spends[outpoint(vin.Txid, vin.Vout)] = txID
+	}
+	m.size += entry.Size
+}
+
+// unlink drops an entry, leaving the transactions spending its outputs in
+// the pool, and takes it out of the totals of its ancestors and descendants
+func (m *Mempool) unlink(txID string) {
+	entry := m.entries[txID]
+
+	for ancestorID := range m.ancestors(&entry.Tx) {
+		ancestor := m.entries[ancestorID]
+		ancestor.descendantFee -= entry.Fee
+		ancestor.descendantSize -= entry.Size
+	}
+	family := make(map[string]bool)
+	m.descendants(txID, family)
+	delete(family, txID)
+	for descendantID := range family {
+		descendant := m.entries[descendantID]
+		descendant.ancestorFee -= entry.Fee
+		descendant.ancestorSize -= entry.Size
+		descendant.ancestorCount--
+	}
+
+	delete(m.entries, txID)
+	for _, vin := range entry.Tx.Vin {
+		delete(m.spends, outpoint(vin.Txid, vin.Vout))
+	}
+	m.size -= entry.Size
+}
+
+// remove drops a transaction and everything in the pool spending its outputs
+func (m *Mempool) remove(txID string) {
+	family := make(map[string]bool)
+	m.descendants(txID, family)
+
+	// Children first, while they are still linked to the ancestors counting them
+	var entries []*MempoolEntry
+	for id := range family {
+		entries = append(entries, m.entries[id])
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq > entries[j].seq })
+	for _, e := range entries {
+		m.unlink(hex.EncodeToString(e.Tx.ID))
+	}
+}
+
+// trim evicts the entries with the lowest descendant score, with their
+// descendants, until the pool fits its size limit
+func (m *Mempool) trim() {
+	for m.size > m.maxSize && len(m.entries) > 0 {
+		var cheapest *MempoolEntry
+		var cheapestScore float64
+		for _, e := range m.entries {
+			score := e.descendantScore()
+			if cheapest == nil || score < cheapestScore ||
+				(score == cheapestScore && e.seq > cheapest.seq) {
+				cheapest, cheapestScore = e, score
+			}
+		}
+		m.remove(hex.EncodeToString(cheapest.Tx.ID))
+	}
+}
+
+// expire drops entries that waited longer than the TTL
+func (m *Mempool) expire(now time.Time) {
+	for txID, e := range m.entries {
+		if now.Sub(e.Time) > m.ttl {
+			m.remove(txID)
+		}
+	}
+}
+
+// Expire drops entries that waited longer than the TTL
+func (m *Mempool) Expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire(Now())
+}
+
+// Remove drops a transaction and its descendants from the pool
+func (m *Mempool) Remove(txID []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(hex.EncodeToString(txID))
+}
+
+// RemoveConfirmed drops the transactions of a connected block, and the
+// transactions spending the same outputs with their descendants
+func (m *Mempool) RemoveConfirmed(block *Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		if _, ok := m.entries[txID]; ok {
+			// Its outputs are in the UTXO set now, so children stay
+			m.unlink(txID)
+			continue
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			if conflict, ok := m.spends[outpoint(vin.Txid, vin.Vout)]; ok {
+				m.remove(conflict)
+			}
+		}
+	}
+}
+
+// Has reports whether a transaction is in the pool
+func (m *Mempool) Has(txID []byte) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.entries[hex.EncodeToString(txID)]
+
+	return ok
+}
+
+// Get returns a transaction in the pool
+func (m *Mempool) Get(txID []byte) (Transaction, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return Transaction{}, false
+	}
+
+	return entry.Tx, true
+}
+
+// Entries returns the entries of the pool in the order they were added, so
+// that parents come before their children
+func (m *Mempool) Entries() []MempoolEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []MempoolEntry
+	for _, e := range m.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	return entries
+}
+
+// Transactions returns the transactions of the pool, parents first
+func (m *Mempool) Transactions() []*Transaction {
+	var txs []*Transaction
+	for _, e := range m.Entries() {
+		tx := e.Tx
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
+
+// packageCandidate is a transaction that may be selected for a block, with
+// the fee and size of its package: itself and its ancestors not selected yet
+type packageCandidate struct {
+	entry *MempoolEntry
+	fee   Amount
+	size  int
+}
+
+// packageHeap orders candidates by package fee rate, highest first, then by
+// arrival
+type packageHeap []packageCandidate
+
+func (h packageHeap) Len() int { return len(h) }
+
+func (h packageHeap) Less(i, j int) bool {
+	rateI := float64(h[i].fee) / float64(h[i].size)
+	rateJ := float64(h[j].fee) / float64(h[j].size)
+	if rateI != rateJ {
+		return rateI > rateJ
+	}
+
+	return h[i].entry.seq < h[j].entry.seq
+}
+
+func (h packageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *packageHeap) Push(x interface{}) { *h = append(*h, x.(packageCandidate)) }
+
+func (h *packageHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return c
+}
+
+// BlockTransactions selects transactions for a block of at most maxSize
+// bytes of transactions. Each transaction is taken with its unconfirmed
+// ancestors, highest package fee rate first, so a child paying a high fee
+// pulls in a cheap parent. Parents come before their children.
+func (m *Mempool) BlockTransactions(maxSize int) []*Transaction {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Current package of each transaction not selected or skipped yet. The
+	// heap may hold older packages of a transaction, which are ignored.
+	packages := make(map[string]packageCandidate)
+	candidates := make(packageHeap, 0, len(m.entries))
+	for txID, e := range m.entries {
+		c := packageCandidate{e, e.ancestorFee, e.ancestorSize}
+		packages[txID] = c
+		candidates = append(candidates, c)
+	}
+	heap.Init(&candidates)
+
+	selected := make(map[string]bool)
+	size := 0
+	for candidates.Len() > 0 {
+		c := heap.Pop(&candidates).(packageCandidate)
+		txID := hex.EncodeToString(c.entry.Tx.ID)
+		if current, ok := packages[txID]; !ok || current != c {
+			continue
+		}
+
+		if size+c.size > maxSize {
+			// Nothing depending on it fits either
+			skipped := make(map[string]bool)
+			m.descendants(txID, skipped)
+			for id := range skipped {
+				delete(packages, id)
+			}
+			continue
+		}
+
+		pkg := map[string]bool{txID: true}
+		for ancestor := range m.ancestors(&c.entry.Tx) {
+			if !selected[ancestor] {
+				pkg[ancestor] = true
+			}
+		}
+		for id := range pkg {
+			selected[id] = true
+			delete(packages, id)
+		}
+		size += c.size
+
+		// The packages of their descendants shrink
+		for id := range pkg {
+			e := m.entries[id]
+			family := make(map[string]bool)
+			m.descendants(id, family)
+			for descendant := range family {
+				if p, ok := packages[descendant]; ok {
+					p.fee -= e.Fee
+					p.size -= e.Size
+					packages[descendant] = p
+					heap.Push(&candidates, p)
+				}
+			}
+		}
+	}
+
+	var entries []*MempoolEntry
+	for txID := range selected {
+		entries = append(entries, m.entries[txID])
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	var txs []*Transaction
+	for _, e := range entries {
+		tx := e.Tx
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
+
+// Entry returns the mempool entry of a transaction
+func (m *Mempool) Entry(txID []byte) (MempoolEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return MempoolEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// DescendantFees returns the fees paid by a transaction in the pool and all
+// its descendants, which a replacement has to pay back
+func (m *Mempool) DescendantFees(txID []byte) Amount {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return 0
+	}
+
+	return entry.descendantFee
+}
+
+// IsSpent reports whether an output is spent by a transaction in the pool
+func (m *Mempool) IsSpent(txID []byte, vout int) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.spends[outpoint(txID, vout)]
+
+	return ok
+}
+
+// ChainLength returns the length of the chain of unconfirmed transactions
+// ending with a transaction in the pool, or 0 if it is not in the pool
+func (m *Mempool) ChainLength(txID []byte) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return 0
+	}
+
+	return entry.ancestorCount
+}
+
+// FindCoins lists the outputs of an asset for a public key hash created by
+// transactions in the pool and not spent in it, parents first
+func (m *Mempool) FindCoins(pubKeyHash []byte, asset []byte) []coinselect.Coin {
+	var coins []coinselect.Coin
+
+	for _, e := range m.Entries() {
+		for outIdx, out := range e.Tx.Vout {
+			if out.IsLockedWithKey(pubKeyHash) && out.IsAsset(asset) && !m.IsSpent(e.Tx.ID, outIdx) {
+				coins = append(coins, coinselect.Coin{TxID: hex.EncodeToString(e.Tx.ID), Vout: outIdx, Value: uint64(out.Value)})
+			}
+		}
+	}
+
+	return coins
+}
+
+// Count returns the number of transactions in the pool
+func (m *Mempool) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.entries)
+}
+
+// Size returns the total size in bytes of the transactions in the pool
+func (m *Mempool) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.size
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/NlaakStudios/Blockchain/api/utils"
+)
+
+// GetMempoolFile returns the path of the file the mempool of a node is kept in
+func GetMempoolFile(nodeID string) string {
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathMempool)
+	return fmt.Sprintf(str, nodeID)
+}
+
+// SaveToFile writes the transactions of the pool to the node's mempool file
+func (m *Mempool) SaveToFile(nodeID string) error {
+	var content bytes.Buffer
+
+	err := gob.NewEncoder(&content).Encode(m.Entries())
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteFileAtomic(GetMempoolFile(nodeID), content.Bytes(), 0644)
+}
+
+// LoadFromFile adds the transactions saved in the node's mempool file to the
+// pool. Each one is validated again against the UTXO set and keeps its
+// original arrival time, so transactions confirmed, double spent or expired
+// while the node was down are dropped. It returns the number of transactions
+// loaded and dropped.
+func (m *Mempool) LoadFromFile(nodeID string, UTXOSet *UTXOSet) (int, int, error) {
+	fileContent, err := ioutil.ReadFile(GetMempoolFile(nodeID))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var entries []MempoolEntry
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&entries)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loaded, dropped := 0, 0
+	for i := range entries {
+		if m.add(&entries[i].Tx, UTXOSet, entries[i].Time) != nil {
+			dropped++
+			continue
+		}
+		loaded++
+	}
+
+	return loaded, dropped, nil
+}
+
+// LoadMempool creates a mempool with the default limits filled from the
+// node's mempool file
+func LoadMempool(nodeID string, UTXOSet *UTXOSet) (*Mempool, error) {
+	mempool := NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+	_, _, err := mempool.LoadFromFile(nodeID, UTXOSet)
+
+	return mempool, err
+}
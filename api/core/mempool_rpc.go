@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// SendRawTransactionParams are the parameters of sendrawtransaction: a
+// signed transaction as hex, as printed by signrawtransaction
+type SendRawTransactionParams struct {
+	Hex string `json:"hex"`
+}
+
+// sendRawTransaction adds a signed transaction to the mempool of the node
+// and relays it. It returns the transaction ID.
+func (s *rpcServer) sendRawTransaction(data json.RawMessage) (interface{}, error) {
+	var params SendRawTransactionParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	tx, err := DecodeRawTransaction(params.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	err = acceptTransaction(s.bc, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// acceptTransaction adds a transaction made or received by the node's
+// tools to its mempool, then relays it to the peers and hands it to the miner
+func acceptTransaction(bc *Blockchain, tx *Transaction) error {
+	err := mempool.Add(tx, &UTXOSet{bc})
+	if err != nil {
+		return err
+	}
+
+	relayInv("tx", [][]byte{tx.ID}, nil)
+	notifyMiner()
+
+	return nil
+}
+
+// SendRawTransaction adds a signed transaction to the mempool of the node
+// and returns its ID
+func (c *RPCClient) SendRawTransaction(tx *Transaction) (string, error) {
+	var txID string
+	err := c.Call("sendrawtransaction", SendRawTransactionParams{EncodeRawTransaction(tx)}, &txID)
+
+	return txID, err
+}
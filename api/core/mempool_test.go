@@ -0,0 +1,188 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+	"github.com/boltdb/bolt"
+)
+
+// newTestBlockchain creates a regtest blockchain in a temporary directory
+// whose blocks each pay their coinbase to one of the wallets, in order
+func newTestBlockchain(t *testing.T, wallets ...*Wallet) *Blockchain {
+	saved := ActiveParams
+	if err := SelectNetwork("regtest"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ActiveParams = saved })
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "blockchain.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	genesis := NewGenesisBlock(NewCoinbaseTX(string(wallets[0].GetAddress()), "", 0))
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(blocksBucket))
+		if err != nil {
+			return err
+		}
+		if err := b.Put(genesis.Hash, genesis.Serialize()); err != nil {
+			return err
+		}
+
+		return b.Put([]byte("l"), genesis.Hash)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc := &Blockchain{genesis.Hash, db}
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+	for height, wallet := range wallets[1:] {
+		block := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "", height+1)}, bc.Tip, height+1)
+		bc.AddBlock(block)
+		UTXOSet.Update(block)
+	}
+
+	return bc
+}
+
+// testSend creates a transaction of wallet, spending its confirmed coins only
+func testSend(wallet *Wallet, feeRate Amount, replaceable bool, UTXOSet *UTXOSet) *Transaction {
+	to := NewWallet(SchemeEd25519)
+
+	return NewUTXOTransaction(wallet, string(to.GetAddress()), "", nil, 1000, coinselect.LargestFirst, feeRate, replaceable, UTXOSet, nil)
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	wallet := NewWallet(SchemeEd25519)
+	UTXOSet := &UTXOSet{newTestBlockchain(t, wallet)}
+
+	tests := []struct {
+		name        string
+		replaceable bool
+		feeRate     Amount
+		err         error
+	}{
+		{name: "higher fee replaces", replaceable: true, feeRate: 20},
+		{name: "equal fee is refused", replaceable: true, feeRate: 10, err: ErrMempoolReplacement},
+		{name: "lower fee is refused", replaceable: true, feeRate: 5, err: ErrMempoolReplacement},
+		{name: "not opted in is kept", replaceable: false, feeRate: 20, err: ErrMempoolConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mempool := NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+			original := testSend(wallet, 10, test.replaceable, UTXOSet)
+			if err := mempool.Add(original, UTXOSet); err != nil {
+				t.Fatal(err)
+			}
+
+			replacement := testSend(wallet, test.feeRate, true, UTXOSet)
+			if err := mempool.Add(replacement, UTXOSet); err != test.err {
+				t.Fatalf("Add: %v, want %v", err, test.err)
+			}
+
+			replaced := test.err == nil
+			if mempool.Has(original.ID) == replaced || mempool.Has(replacement.ID) != replaced {
+				t.Errorf("original in the pool: %v, replacement: %v", mempool.Has(original.ID), mempool.Has(replacement.ID))
+			}
+			if mempool.Count() != 1 {
+				t.Errorf("Count() = %d, want 1", mempool.Count())
+			}
+		})
+	}
+}
+
+func TestMempoolReplacementEvictsDescendants(t *testing.T) {
+	wallet := NewWallet(SchemeEd25519)
+	UTXOSet := &UTXOSet{newTestBlockchain(t, wallet)}
+	mempool := NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+
+	parent := testSend(wallet, 10, true, UTXOSet)
+	if err := mempool.Add(parent, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	// Spends the change of parent
+	child := NewUTXOTransaction(wallet, string(wallet.GetAddress()), "", nil, 1000, coinselect.LargestFirst, 10, false, UTXOSet, mempool)
+	if err := mempool.Add(child, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough to beat the rate of parent, but not to pay for child too
+	replacement := testSend(wallet, 11, true, UTXOSet)
+	if err := mempool.Add(replacement, UTXOSet); err != ErrMempoolReplacement {
+		t.Fatalf("Add: %v, want %v", err, ErrMempoolReplacement)
+	}
+
+	replacement = testSend(wallet, 40, true, UTXOSet)
+	if err := mempool.Add(replacement, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	if mempool.Has(parent.ID) || mempool.Has(child.ID) || !mempool.Has(replacement.ID) {
+		t.Errorf("parent and child should have been replaced")
+	}
+}
+
+func TestMempoolEviction(t *testing.T) {
+	cheap, dear, cheaper := NewWallet(SchemeEd25519), NewWallet(SchemeEd25519), NewWallet(SchemeEd25519)
+	UTXOSet := &UTXOSet{newTestBlockchain(t, cheap, dear, cheaper)}
+
+	cheapTx := testSend(cheap, 5, false, UTXOSet)
+	dearTx := testSend(dear, 20, false, UTXOSet)
+	cheaperTx := testSend(cheaper, 2, false, UTXOSet)
+
+	// Room for one transaction only
+	size := len(cheapTx.Serialize())
+	mempool := NewMempool(size+size/2, defaultMempoolTTL)
+
+	if err := mempool.Add(cheapTx, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	if err := mempool.Add(dearTx, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	if mempool.Has(cheapTx.ID) || !mempool.Has(dearTx.ID) {
+		t.Errorf("the cheapest transaction should have been evicted")
+	}
+
+	if err := mempool.Add(cheaperTx, UTXOSet); err != ErrMempoolFull {
+		t.Errorf("Add: %v, want %v", err, ErrMempoolFull)
+	}
+	if !mempool.Has(dearTx.ID) || mempool.Count() != 1 {
+		t.Errorf("the pool should only hold the dearest transaction")
+	}
+	if mempool.Size() > size+size/2 {
+		t.Errorf("Size() = %d over the limit", mempool.Size())
+	}
+}
+
+func TestMempoolEvictionKeepsPackages(t *testing.T) {
+	parentWallet, other := NewWallet(SchemeEd25519), NewWallet(SchemeEd25519)
+	UTXOSet := &UTXOSet{newTestBlockchain(t, parentWallet, other)}
+	mempool := NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+
+	parent := testSend(parentWallet, 2, false, UTXOSet)
+	if err := mempool.Add(parent, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	// A child paying a high fee for its cheap parent
+	child := NewUTXOTransaction(parentWallet, string(parentWallet.GetAddress()), "", nil, 1000, coinselect.LargestFirst, 50, false, UTXOSet, mempool)
+	if err := mempool.Add(child, UTXOSet); err != nil {
+		t.Fatal(err)
+	}
+	otherTx := testSend(other, 10, false, UTXOSet)
+
+	// Over the limit once otherTx is in, which must go instead of parent
+	mempool.maxSize = mempool.Size() + len(otherTx.Serialize()) - 1
+	if err := mempool.Add(otherTx, UTXOSet); err != ErrMempoolFull {
+		t.Fatalf("Add: %v, want %v", err, ErrMempoolFull)
+	}
+	if !mempool.Has(parent.ID) || !mempool.Has(child.ID) {
+		t.Errorf("the parent paid for by its child should have been kept")
+	}
+}
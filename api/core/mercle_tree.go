@@ -16,7 +16,10 @@ type MerkleNode struct {
 	Data  []byte
 }
 
-// NewMerkleTree creates a new Merkle tree from a sequence of data
+// NewMerkleTree creates a new Merkle tree from a sequence of data. The last
+// node of a level with an odd number of nodes is paired with itself, so data
+// ending with a repeated pair has the root of the data without it: blocks
+// with repeated transactions are refused by checkBlock.
 func NewMerkleTree(data [][]byte) *MerkleTree {
 	var nodes []MerkleNode
 
@@ -29,9 +32,13 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 		nodes = append(nodes, *node)
 	}
 
-	for i := 0; i < len(data)/2; i++ {
+	for len(nodes) > 1 {
 		var newLevel []MerkleNode
 
+		if len(nodes)%2 != 0 {
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
+
 		for j := 0; j < len(nodes); j += 2 {
 			node := NewMerkleNode(&nodes[j], &nodes[j+1], nil)
 			newLevel = append(newLevel, *node)
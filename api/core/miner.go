@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minerBatch is the number of nonces a mining thread tries before checking
+// whether it should stop
+const minerBatch = 1 << 12
+
+// MinerConfig configures the background miner of a node
+type MinerConfig struct {
+	Address        string        // receives the block rewards, mining is off when empty
+	MinInterval    time.Duration // least time between two blocks mined by the node
+	MaxEmptyBlocks int           // empty blocks mined in a row before waiting for transactions, -1 for no limit
+	Threads        int           // nonce search threads, one per CPU core when 0
+}
+
+// Miner keeps mining blocks on top of the current tip with the transactions
+// of the mempool. It starts over whenever the tip changes or new
+// transactions arrive.
+type Miner struct {
+	bc          *Blockchain
+	config      MinerConfig
+	notify      chan struct{}
+	quit        chan struct{}
+	lastBlock   time.Time
+	emptyBlocks int
+}
+
+// NewMiner creates a miner for the blockchain. It does nothing until started.
+func NewMiner(bc *Blockchain, config MinerConfig) *Miner {
+	if config.Threads <= 0 {
+		config.Threads = runtime.NumCPU()
+	}
+
+	return &Miner{
+		bc:     bc,
+		config: config,
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start runs the miner in the background
+func (m *Miner) Start() {
+	fmt.Printf("Mining to %s with %d thread(s)\n", m.config.Address, m.config.Threads)
+	go m.run()
+}
+
+// Stop stops the miner, abandoning the block being mined
+func (m *Miner) Stop() {
+	close(m.quit)
+}
+
+// Notify tells the miner the tip or the mempool changed, so that it starts
+// over with a new block template. It never blocks.
+func (m *Miner) Notify() {
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Miner) run() {
+	for {
+		if wait := m.config.MinInterval - time.Since(m.lastBlock); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-m.quit:
+				return
+			}
+		}
+
+		// Whatever happened until now is in the template
+		select {
+		case <-m.notify:
+		default:
+		}
+
+		template := NewBlockTemplate(m.bc, mempool, m.config.Address, MaxBlockSize)
+		empty := len(template.Transactions) == 1
+		if empty && m.config.MaxEmptyBlocks >= 0 && m.emptyBlocks >= m.config.MaxEmptyBlocks {
+			select {
+			case <-m.notify:
+				continue
+			case <-m.quit:
+				return
+			}
+		}
+
+		block := m.solve(template.Block(0))
+		if block == nil {
+			select {
+			case <-m.quit:
+				return
+			default:
+				continue
+			}
+		}
+
+		err := submitMinedBlock(m.bc, block)
+		if err != nil {
+			fmt.Printf("Mined block %x was refused: %s\n", block.Hash, err)
+			continue
+		}
+
+		m.lastBlock = time.Now()
+		if empty {
+			m.emptyBlocks++
+		} else {
+			m.emptyBlocks = 0
+		}
+	}
+}
+
+// solve searches the nonce of a block in several threads, each trying
+// interleaved batches of nonces. It returns nil when interrupted by a
+// notification or Stop, or when no nonce meets the target.
+func (m *Miner) solve(block *Block) *Block {
+	var abort int32
+	found := make(chan *Block, m.config.Threads)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	stride := m.config.Threads * minerBatch
+	for i := 0; i < m.config.Threads; i++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			pow := NewProofOfWork(block)
+
+			for ; atomic.LoadInt32(&abort) == 0 && start <= maxNonce-stride; start += stride {
+				nonce, hash, ok := pow.Search(start, minerBatch)
+				if ok {
+					solved := *block
+					solved.Nonce, solved.Hash = nonce, hash
+					found <- &solved
+					return
+				}
+			}
+		}(i * minerBatch)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var result *Block
+	select {
+	case result = <-found:
+	case <-m.notify:
+	case <-m.quit:
+	case <-done:
+	}
+	atomic.StoreInt32(&abort, 1)
+	<-done
+
+	if result == nil {
+		select {
+		case result = <-found:
+		default:
+		}
+	}
+
+	return result
+}
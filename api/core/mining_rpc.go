@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrGenerateNotAllowed is returned when generating blocks on a network where they are not found at once
+var ErrGenerateNotAllowed = errors.New("Blocks can only be generated on regtest")
+
+// maxTemplates is the number of templates the RPC server remembers for the
+// current tip, so that miners working on a slightly older one can still submit
+const maxTemplates = 16
+
+// GetBlockTemplateParams are the parameters of getblocktemplate. The reward
+// goes to the node's miner address when Address is empty.
+type GetBlockTemplateParams struct {
+	Address string `json:"address,omitempty"`
+}
+
+// BlockTemplateReply is the result of getblocktemplate. Block is the
+// serialized block with a zero nonce; miners not decoding it hash
+// PrevBlockHash, MerkleRoot, Timestamp, TargetBits and the nonce, each of
+// the last three as 8 big-endian bytes, and look for a hash below
+// 2^(256-TargetBits).
+type BlockTemplateReply struct {
+	ID            string `json:"id"`
+	PrevBlockHash string `json:"prev_block_hash"`
+	Height        int    `json:"height"`
+	Timestamp     int64  `json:"timestamp"`
+	TargetBits    int    `json:"target_bits"`
+	MerkleRoot    string `json:"merkle_root"`
+	Transactions  int    `json:"transactions"`
+	Fees          Amount `json:"fees"`
+	Block         string `json:"block"`
+}
+
+// SubmitBlockParams are the parameters of submitblock: either the ID of a
+// template and the nonce found for it, or a whole serialized block
+type SubmitBlockParams struct {
+	ID    string `json:"id,omitempty"`
+	Nonce int    `json:"nonce,omitempty"`
+	Block string `json:"block,omitempty"`
+}
+
+// getBlockTemplate returns a new block template and remembers it until the tip changes
+func (s *rpcServer) getBlockTemplate(data json.RawMessage) (interface{}, error) {
+	var params GetBlockTemplateParams
+	if err := decodeParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	address := params.Address
+	if address == "" {
+		address = miningAddress
+	}
+	if err := ValidateAddress(address); err != nil {
+		return nil, fmt.Errorf("A valid reward address is required: %s", err)
+	}
+
+	t := NewBlockTemplate(s.bc, mempool, address, MaxBlockSize)
+	block := t.Block(0)
+	id := hex.EncodeToString(block.Hash)
+
+	s.mu.Lock()
+	if !bytes.Equal(s.tip, t.PrevBlockHash) {
+		s.tip = t.PrevBlockHash
+		s.templates = make(map[string]*BlockTemplate)
+		s.order = nil
+	}
+	s.templates[id] = t
+	s.order = append(s.order, id)
+	if len(s.order) > maxTemplates {
+		delete(s.templates, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.mu.Unlock()
+
+	return &BlockTemplateReply{
+		ID:            id,
+		PrevBlockHash: hex.EncodeToString(t.PrevBlockHash),
+		Height:        t.Height,
+		Timestamp:     t.Timestamp,
+		TargetBits:    t.TargetBits,
+		MerkleRoot:    hex.EncodeToString(block.HashTransactions()),
+		Transactions:  len(t.Transactions),
+		Fees:          t.Fees,
+		Block:         hex.EncodeToString(block.Serialize()),
+	}, nil
+}
+
+// submitBlock checks a block mined from a template, or a whole block, and adds it to the chain
+func (s *rpcServer) submitBlock(data json.RawMessage) (interface{}, error) {
+	var params SubmitBlockParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+
+	var block *Block
+
+	if params.Block != "" {
+		raw, err := hex.DecodeString(params.Block)
+		if err != nil {
+			return nil, err
+		}
+		block, err = decodeBlock(raw)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		s.mu.Lock()
+		t, ok := s.templates[params.ID]
+		s.mu.Unlock()
+		if !ok {
+			return nil, ErrBlockStale
+		}
+		block = t.Block(params.Nonce)
+	}
+
+	err := submitMinedBlock(s.bc, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(block.Hash), nil
+}
+
+// GenerateParams are the parameters of generate: the number of blocks to
+// mine and the address of their rewards, the node's miner address when empty
+type GenerateParams struct {
+	Blocks  int    `json:"blocks"`
+	Address string `json:"address,omitempty"`
+}
+
+// generate mines blocks at once with the transactions of the mempool, at
+// the time of the node's clock, and adds them to the chain like the blocks
+// of the miner. It returns their hashes.
+func (s *rpcServer) generate(data json.RawMessage) (interface{}, error) {
+	if !ActiveParams.AllowMockTime {
+		return nil, ErrGenerateNotAllowed
+	}
+
+	var params GenerateParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if params.Blocks <= 0 {
+		return nil, errors.New("Number of blocks must be positive")
+	}
+	address := params.Address
+	if address == "" {
+		address = miningAddress
+	}
+	if err := ValidateAddress(address); err != nil {
+		return nil, fmt.Errorf("A valid reward address is required: %s", err)
+	}
+
+	hashes := []string{}
+	for i := 0; i < params.Blocks; i++ {
+		block := NewBlockTemplate(s.bc, mempool, address, MaxBlockSize).Block(0)
+		nonce, hash, ok := NewProofOfWork(block).Search(0, maxNonce)
+		if !ok {
+			return nil, errors.New("No nonce meets the target")
+		}
+		block.Nonce, block.Hash = nonce, hash
+
+		err := submitMinedBlock(s.bc, block)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hex.EncodeToString(block.Hash))
+	}
+
+	return hashes, nil
+}
+
+// decodeBlock deserializes a block received from outside of the node
+func decodeBlock(data []byte) (block *Block, err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("Block could not be decoded")
+		}
+	}()
+
+	return DeserializeBlock(data), nil
+}
+
+// submitMu makes sure one mined block at a time is checked against the tip
+// and connected
+var submitMu sync.Mutex
+
+// submitMinedBlock adds a block mined for this node to the chain and connects it
+func submitMinedBlock(bc *Blockchain, block *Block) error {
+	submitMu.Lock()
+	defer submitMu.Unlock()
+
+	err := bc.SubmitBlock(block)
+	if err != nil {
+		return err
+	}
+	connectMinedBlock(bc, block)
+
+	return nil
+}
+
+// connectMinedBlock updates the UTXO set and the mempool for a block mined
+// by this node, then announces it to the other nodes
+func connectMinedBlock(bc *Blockchain, block *Block) {
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Update(block)
+	mempool.RemoveConfirmed(block)
+	syncManager.index.addBlock(block)
+
+	fmt.Printf("New block %x is mined!\n", block.Hash)
+	notifyMiner()
+
+	relayInv("block", [][]byte{block.Hash}, nil)
+}
+
+// GetBlockTemplate asks for a block template paying the reward to address,
+// or to the node's miner address when empty, and decodes its block
+func (c *RPCClient) GetBlockTemplate(address string) (*BlockTemplateReply, *Block, error) {
+	var reply BlockTemplateReply
+	err := c.Call("getblocktemplate", GetBlockTemplateParams{address}, &reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := hex.DecodeString(reply.Block)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := decodeBlock(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &reply, block, nil
+}
+
+// SubmitBlock submits the nonce found for a template and returns the hash
+// of the accepted block
+func (c *RPCClient) SubmitBlock(id string, nonce int) (string, error) {
+	var hash string
+	err := c.Call("submitblock", SubmitBlockParams{ID: id, Nonce: nonce}, &hash)
+
+	return hash, err
+}
+
+// Generate asks the node to mine blocks at once, paying the rewards to
+// address or to the node's miner address when empty, and returns their hashes
+func (c *RPCClient) Generate(blocks int, address string) ([]string, error) {
+	var hashes []string
+	err := c.Call("generate", GenerateParams{blocks, address}, &hashes)
+
+	return hashes, err
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// mockTime is the unix time the clock of the node is stopped at, 0 while
+// it runs
+var mockTime int64
+
+// ErrMockTimeNotAllowed is returned when setting the mock time on a network that does not allow it
+var ErrMockTimeNotAllowed = errors.New("Mock time can only be set on regtest")
+
+// SetMockTimeParams are the parameters of setmocktime: the unix time to
+// stop the clock at, 0 to let it run again
+type SetMockTimeParams struct {
+	Time int64 `json:"time"`
+}
+
+// SetMockTime stops the clock of the node at the unix time t, or lets it run
+// again when t is 0. Block timestamps, mempool arrival times and expiry and
+// the creation times of addresses follow it, so that rules depending on
+// them can be tested deterministically.
+func SetMockTime(t int64) error {
+	if t < 0 {
+		return errors.New("Mock time must not be negative")
+	}
+	if t != 0 && !ActiveParams.AllowMockTime {
+		return ErrMockTimeNotAllowed
+	}
+	atomic.StoreInt64(&mockTime, t)
+
+	return nil
+}
+
+// Now returns the time of the clock of the node: the mock time when set,
+// the current time otherwise
+func Now() time.Time {
+	if t := atomic.LoadInt64(&mockTime); t != 0 {
+		return time.Unix(t, 0)
+	}
+
+	return time.Now()
+}
+
+// setMockTime sets the clock of the running node
+func (s *rpcServer) setMockTime(data json.RawMessage) (interface{}, error) {
+	if !ActiveParams.AllowMockTime {
+		return nil, ErrMockTimeNotAllowed
+	}
+
+	var params SetMockTimeParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if err := SetMockTime(params.Time); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+// SetMockTime sets the clock of the node to the unix time t, 0 to let it run
+func (c *RPCClient) SetMockTime(t int64) error {
+	var ok bool
+
+	return c.Call("setmocktime", SetMockTimeParams{t}, &ok)
+}
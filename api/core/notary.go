@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+)
+
+// notaryMarker prefixes the data output of a notarization transaction
+var notaryMarker = []byte("NTRY")
+
+// Prefixes of the hashes of the notary tree, so that an inner node can never
+// be passed off as a file hash
+const (
+	notaryLeafPrefix = 0x00
+	notaryNodePrefix = 0x01
+)
+
+// NotaryProof proves that a file hash is committed to by a notarization transaction
+type NotaryProof struct {
+	File     string            `json:"file"`
+	FileHash string            `json:"file_hash"`
+	Path     []NotaryProofStep `json:"path"`
+	Root     string            `json:"root"`
+	TxID     string            `json:"txid"`
+}
+
+// NotaryProofStep is one sibling hash on the way from a file hash to the root
+type NotaryProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// HashFile returns the SHA-256 hash of a file's contents
+func HashFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+
+	return hash[:], nil
+}
+
+// NotaryData builds the payload of a data output committing to root
+func NotaryData(root []byte) []byte {
+	return append(append([]byte{}, notaryMarker...), root...)
+}
+
+// NotaryRoot returns the Merkle root of a list of file hashes. An odd node at
+// any level is carried up to the next level as it is.
+func NotaryRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		log.Panic("ERROR: Nothing to notarize")
+	}
+
+	level := notaryLeaves(leaves)
+	for len(level) > 1 {
+		level = notaryLevel(level)
+	}
+
+	return level[0]
+}
+
+// NewNotaryProofs builds one proof per file hash. TxID is left empty until
+// the root has been committed.
+func NewNotaryProofs(files []string, leaves [][]byte) []NotaryProof {
+	root := hex.EncodeToString(NotaryRoot(leaves))
+	proofs := make([]NotaryProof, len(leaves))
+
+	for i := range leaves {
+		proofs[i] = NotaryProof{
+			File:     files[i],
+			FileHash: hex.EncodeToString(leaves[i]),
+			Root:     root,
+		}
+	}
+
+	level := notaryLeaves(leaves)
+	positions := make([]int, len(leaves))
+	for i := range positions {
+		positions[i] = i
+	}
+
+	for len(level) > 1 {
+		for i := range proofs {
+			pos := positions[i]
+			sibling := pos ^ 1
+			if sibling < len(level) {
+				step := NotaryProofStep{hex.EncodeToString(level[sibling]), sibling < pos}
+				proofs[i].Path = append(proofs[i].Path, step)
+			}
+			positions[i] = pos / 2
+		}
+		level = notaryLevel(level)
+	}
+
+	return proofs
+}
+
+// ComputeRoot folds the proof path over the file hash and returns the root
+func (p *NotaryProof) ComputeRoot() ([]byte, error) {
+	fileHash, err := hex.DecodeString(p.FileHash)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashNotaryLeaf(fileHash)
+	for _, step := range p.Path {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if step.Left {
+			hash = hashNotaryPair(sibling, hash)
+		} else {
+			hash = hashNotaryPair(hash, sibling)
+		}
+	}
+
+	return hash, nil
+}
+
+// Verify checks the proof against the chain and returns the block holding
+// the notarization transaction
+func (p *NotaryProof) Verify(bc *Blockchain) (*Block, error) {
+	root, err := p.ComputeRoot()
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(root) != p.Root {
+		return nil, errors.New("Proof path does not lead to its root")
+	}
+
+	txID, err := hex.DecodeString(p.TxID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := bc.FindTransactionBlock(txID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := NotaryData(root)
+	for _, tx := range block.Transactions {
+		if bytes.Compare(tx.ID, txID) != 0 {
+			continue
+		}
+		for _, out := range tx.Vout {
+			if out.IsData() && bytes.Compare(out.Data, data) == 0 {
+				return block, nil
+			}
+		}
+	}
+
+	return nil, errors.New("Transaction does not commit to the proof root")
+}
+
+// Save writes the proof to a JSON file
+func (p *NotaryProof) Save(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// LoadNotaryProof reads a proof from a JSON file
+func LoadNotaryProof(path string) (*NotaryProof, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var proof NotaryProof
+	err = json.Unmarshal(content, &proof)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proof, nil
+}
+
+func notaryLeaves(leaves [][]byte) [][]byte {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashNotaryLeaf(leaf)
+	}
+
+	return level
+}
+
+func notaryLevel(level [][]byte) [][]byte {
+	var next [][]byte
+
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			break
+		}
+		next = append(next, hashNotaryPair(level[i], level[i+1]))
+	}
+
+	return next
+}
+
+func hashNotaryLeaf(fileHash []byte) []byte {
+	hash := sha256.Sum256(append([]byte{notaryLeafPrefix}, fileHash...))
+
+	return hash[:]
+}
+
+func hashNotaryPair(left, right []byte) []byte {
+	data := append(append([]byte{notaryNodePrefix}, left...), right...)
+	hash := sha256.Sum256(data)
+
+	return hash[:]
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+)
+
+// ChainParams defines a network. Nodes of different networks never accept
+// each other's blocks, messages or addresses.
+type ChainParams struct {
+	Name string
+
+	// Genesis block
+	GenesisData   string // text of the genesis transaction
+	GenesisSupply Amount // paid to the address the blockchain is created with
+
+	// Peers
+	Magic       [4]byte  // starts every message between nodes of the network
+	DefaultPort string   // port nodes listen on when none is given
+	Seeds       []string // nodes contacted while no peer is known, the first one is the central node
+
+	// DefaultRPCPort is the port the RPC server of nodes listens on, and
+	// command line tools connect to, when none is given
+	DefaultRPCPort string
+
+	// Difficulty
+	TargetBits int // leading zero bits of the hash of a valid block
+
+	// Reward schedule
+	Subsidy         Amount // reward of the first blocks
+	HalvingInterval int    // blocks after which the reward halves, 0 to never halve
+
+	Addresses AddressPrefixes
+
+	// AllowMockTime lets tests stop the clock of nodes, see SetMockTime
+	AllowMockTime bool
+
+	// DataDir is the directory of the network's files inside config.FilePathData
+	DataDir string
+}
+
+// MainNetParams are the parameters of the main network
+var MainNetParams = ChainParams{
+	Name:            "mainnet",
+	GenesisData:     "Global Wealth & Freedom Genesis block.",
+	GenesisSupply:   Amount(totalSupplyCoins) * CoinUnit,
+	Magic:           [4]byte{0x67, 0x77, 0x66, 0xd9},
+	DefaultPort:     config.NodePort,
+	Seeds:           []string{"localhost:" + config.NodePort},
+	DefaultRPCPort:  config.RPCPort,
+	TargetBits:      16,
+	Subsidy:         1 * CoinUnit,
+	HalvingInterval: 0,
+	Addresses:       AddressPrefixes{0x00, "gwf"},
+	DataDir:         "",
+}
+
+// TestNetParams are the parameters of the public test network, whose coins
+// are worthless
+var TestNetParams = ChainParams{
+	Name:            "testnet",
+	GenesisData:     "Global Wealth & Freedom Testnet Genesis block.",
+	GenesisSupply:   Amount(totalSupplyCoins) * CoinUnit,
+	Magic:           [4]byte{0x0b, 0x67, 0x77, 0x66},
+	DefaultPort:     "13000",
+	Seeds:           []string{"localhost:13000"},
+	DefaultRPCPort:  "13100",
+	TargetBits:      12,
+	Subsidy:         1 * CoinUnit,
+	HalvingInterval: 0,
+	Addresses:       AddressPrefixes{0x6f, "tgwf"},
+	DataDir:         "testnet",
+}
+
+// RegTestParams are the parameters of a private network for tests, where
+// blocks are found at once, mined on demand with generate, and the clock
+// may be stopped
+var RegTestParams = ChainParams{
+	Name:            "regtest",
+	GenesisData:     "Global Wealth & Freedom Regtest Genesis block.",
+	GenesisSupply:   Amount(totalSupplyCoins) * CoinUnit,
+	Magic:           [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	DefaultPort:     "23000",
+	Seeds:           []string{"localhost:23000"},
+	DefaultRPCPort:  "23100",
+	TargetBits:      1,
+	Subsidy:         1 * CoinUnit,
+	HalvingInterval: 150,
+	Addresses:       AddressPrefixes{0x7a, "rgwf"},
+	AllowMockTime:   true,
+	DataDir:         "regtest",
+}
+
+// networks lists the parameters of every network by name
+var networks = []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// ActiveParams are the parameters of the network the node runs on
+var ActiveParams = &MainNetParams
+
+// SelectNetwork makes the network of the given name the one the node runs on
+func SelectNetwork(name string) error {
+	for _, params := range networks {
+		if params.Name == name {
+			ActiveParams = params
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown network %q, use mainnet, testnet or regtest", name)
+}
+
+// BlockSubsidy returns the reward of the block at the given height
+func (p *ChainParams) BlockSubsidy(height int) Amount {
+	if p.HalvingInterval == 0 {
+		return p.Subsidy
+	}
+
+	halvings := height / p.HalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+
+	return p.Subsidy >> uint(halvings)
+}
+
+// GetDataDir returns the directory the files of the active network are kept in
+func GetDataDir() string {
+	return filepath.Join(config.FilePathData, ActiveParams.DataDir)
+}
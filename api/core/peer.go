@@ -0,0 +1,398 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProtocolVersion is the version of the protocol spoken between nodes.
+// Peers older than minProtocolVersion are dropped during the handshake.
+// Version 3 gives the services and the last-seen time of addresses,
+// version 4 syncs headers first with getheaders instead of getblocks.
+const ProtocolVersion = 4
+const minProtocolVersion = 4
+
+// Services a node announces in its version message
+const (
+	SFNodeNetwork uint64 = 1 << iota // serves the blocks of the whole chain
+	SFNodeRelay                      // relays unconfirmed transactions
+)
+
+// Timeouts of peer connections. A peer that does not answer a ping before
+// the next one is due, or sends nothing for idleTimeout, is disconnected.
+const (
+	dialTimeout      = 10 * time.Second
+	handshakeTimeout = 30 * time.Second
+	writeTimeout     = 30 * time.Second
+	pingInterval     = time.Minute
+	idleTimeout      = 3 * pingInterval
+)
+
+// peerSendQueueSize is the number of messages queued for a peer before it
+// is considered too slow and disconnected
+const peerSendQueueSize = 256
+
+// Errors returned by the handshake and when queueing messages
+var (
+	ErrPeerVersion   = errors.New("Peer protocol version is too old")
+	ErrPeerSelf      = errors.New("Peer is this node")
+	ErrPeerHandshake = errors.New("Peer sent a message before completing the handshake")
+	ErrPeerQueueFull = errors.New("Peer does not read its messages")
+	ErrPeerPong      = errors.New("Peer did not answer a ping")
+)
+
+// localNonce identifies this node in its version messages, so that
+// connections to itself are detected
+var localNonce = randomNonce()
+
+// localServices are the services this node offers, none when it only sends
+// a message from the command line
+var localServices uint64
+
+type ping struct {
+	Nonce uint64
+}
+
+type pong struct {
+	Nonce uint64
+}
+
+// Peer is a connection to another node. Once the version/verack handshake
+// is done, messages are read and written by their own goroutines, writes
+// going through a queue so that a slow peer never blocks its sender.
+type Peer struct {
+	conn    net.Conn
+	Addr    string // address the peer listens on, empty for command line clients
+	Inbound bool
+
+	// Announced by the peer in its version message
+	Version    int
+	Services   uint64
+	BestHeight int
+
+	sendQueue chan *Message
+	quit      chan struct{}
+	closeOnce sync.Once
+	pingNonce uint64 // nonce of the ping awaiting a pong, 0 when none
+
+	// Guarded by addrMu, as a getaddr sent from the connection manager grants
+	// tokens while the read goroutine spends them
+	addrMu     sync.Mutex
+	addrTokens float64   // addresses the peer may still send, see handleAddr
+	addrRefill time.Time // last time addrTokens was refilled
+
+	sentAddrs bool // whether a getaddr of the peer was answered, read goroutine only
+}
+
+// newPeer wraps a connection. addr is the address dialed for outbound
+// connections and learnt from the version message for inbound ones.
+func newPeer(conn net.Conn, addr string, inbound bool) *Peer {
+	return &Peer{
+		conn:       conn,
+		Addr:       addr,
+		Inbound:    inbound,
+		sendQueue:  make(chan *Message, peerSendQueueSize),
+		quit:       make(chan struct{}),
+		addrTokens: 1,
+		addrRefill: time.Now(),
+	}
+}
+
+// String describes the peer in log messages
+func (p *Peer) String() string {
+	direction := "outbound"
+	if p.Inbound {
+		direction = "inbound"
+	}
+	if p.Addr == "" {
+		return fmt.Sprintf("%s (%s)", p.conn.RemoteAddr(), direction)
+	}
+
+	return fmt.Sprintf("%s (%s)", p.Addr, direction)
+}
+
+// listenAddress returns the address an inbound peer is reachable at: the
+// host its connection comes from and the port it announced, which is all
+// of its announced address that can be trusted. It is empty when the peer
+// announced no port.
+func (p *Peer) listenAddress() string {
+	host, _, err := net.SplitHostPort(p.conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(p.Addr)
+	if err != nil {
+		return ""
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// address returns the address the peer listens on: the one dialed for an
+// outbound peer, listenAddress for an inbound one, never the address an
+// inbound peer claims
+func (p *Peer) address() string {
+	if p.Inbound {
+		return p.listenAddress()
+	}
+
+	return p.Addr
+}
+
+// handshake exchanges version and verack messages with the peer. The
+// outbound side sends its version first, the inbound side answers the
+// version it receives with its own. Both acknowledge the version of the
+// other with a verack.
+func (p *Peer) handshake(bestHeight int) error {
+	p.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer p.conn.SetDeadline(time.Time{})
+
+	if !p.Inbound {
+		if err := p.writeVersion(bestHeight); err != nil {
+			return err
+		}
+	}
+
+	gotVersion, gotVerack := false, false
+	for !gotVersion || !gotVerack {
+		msg, err := ReadMessage(p.conn)
+		if err != nil {
+			return err
+		}
+
+		switch msg.Command {
+		case "version":
+			if gotVersion {
+				return ErrPeerHandshake
+			}
+			if err := p.readVersion(msg.Payload); err != nil {
+				return err
+			}
+			if p.Inbound {
+				if err := p.writeVersion(bestHeight); err != nil {
+					return err
+				}
+			}
+			if err := WriteMessage(p.conn, "verack", nil); err != nil {
+				return err
+			}
+			gotVersion = true
+		case "verack":
+			if gotVerack || (p.Inbound && !gotVersion) {
+				return ErrPeerHandshake
+			}
+			gotVerack = true
+		default:
+			return ErrPeerHandshake
+		}
+	}
+
+	return nil
+}
+
+// writeVersion sends the version message of this node
+func (p *Peer) writeVersion(bestHeight int) error {
+	payload := verzion{ProtocolVersion, localServices, bestHeight, nodeAddress, localNonce}
+
+	return WriteMessage(p.conn, "version", gobEncode(payload))
+}
+
+// readVersion checks the version message of the peer and keeps what it announces
+func (p *Peer) readVersion(request []byte) error {
+	var payload verzion
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if payload.Nonce == localNonce {
+		return ErrPeerSelf
+	}
+	if payload.Version < minProtocolVersion {
+		return ErrPeerVersion
+	}
+
+	p.Version = payload.Version
+	p.Services = payload.Services
+	p.BestHeight = payload.BestHeight
+	if p.Inbound {
+		p.Addr = payload.AddrFrom
+	}
+
+	return nil
+}
+
+// start runs the read, write and ping goroutines of a peer whose handshake is done
+func (p *Peer) start(bc *Blockchain) {
+	go p.readLoop(bc)
+	go p.writeLoop()
+	go p.pingLoop()
+}
+
+// QueueMessage queues a command with its payload, gob encoded, to be sent
+// to the peer. A peer whose queue is full is disconnected.
+func (p *Peer) QueueMessage(command string, payload interface{}) {
+	msg := &Message{command, gobEncode(payload)}
+
+	select {
+	case p.sendQueue <- msg:
+	case <-p.quit:
+	default:
+		p.disconnect(ErrPeerQueueFull)
+	}
+}
+
+// Disconnect closes the connection to the peer
+func (p *Peer) Disconnect() {
+	p.disconnect(nil)
+}
+
+// disconnect closes the connection once, logging why when err is set
+func (p *Peer) disconnect(err error) {
+	p.closeOnce.Do(func() {
+		if err != nil {
+			fmt.Printf("Disconnecting %s: %s\n", p, err)
+		}
+		close(p.quit)
+		p.conn.Close()
+	})
+}
+
+// Done is closed once the peer is disconnected
+func (p *Peer) Done() <-chan struct{} {
+	return p.quit
+}
+
+// readLoop handles the messages of the peer one after another until the
+// connection is closed. A malformed frame drops the peer, a malformed
+// payload only its message.
+func (p *Peer) readLoop(bc *Blockchain) {
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		msg, err := ReadMessage(p.conn)
+		if err == io.EOF {
+			p.disconnect(nil)
+			return
+		}
+		if err != nil {
+			p.disconnect(err)
+			return
+		}
+
+		switch msg.Command {
+		case "ping":
+			err = p.handlePing(msg.Payload)
+		case "pong":
+			err = p.handlePong(msg.Payload)
+		case "version", "verack":
+			err = errors.New("Handshake is already done")
+		default:
+			fmt.Printf("Received %s command from %s\n", msg.Command, p)
+			err = handleMessage(p, msg, bc)
+		}
+		if err != nil {
+			fmt.Printf("Rejected %s message from %s: %s\n", msg.Command, p, err)
+		}
+	}
+}
+
+// writeLoop writes the queued messages to the peer
+func (p *Peer) writeLoop() {
+	for {
+		select {
+		case msg := <-p.sendQueue:
+			p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err := WriteMessage(p.conn, msg.Command, msg.Payload)
+			if err != nil {
+				p.disconnect(err)
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// pingLoop pings the peer every pingInterval and disconnects it when the
+// previous ping is still unanswered
+func (p *Peer) pingLoop() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.LoadUint64(&p.pingNonce) != 0 {
+				p.disconnect(ErrPeerPong)
+				return
+			}
+			nonce := randomNonce()
+			atomic.StoreUint64(&p.pingNonce, nonce)
+			p.QueueMessage("ping", ping{nonce})
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// handlePing answers a ping with a pong carrying the same nonce
+func (p *Peer) handlePing(request []byte) error {
+	var payload ping
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	p.QueueMessage("pong", pong{payload.Nonce})
+
+	return nil
+}
+
+// handlePong clears the ping it answers
+func (p *Peer) handlePong(request []byte) error {
+	var payload pong
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if !atomic.CompareAndSwapUint64(&p.pingNonce, payload.Nonce, 0) {
+		return errors.New("Pong does not answer the last ping")
+	}
+
+	return nil
+}
+
+// sendOneShot connects to the node listening on addr, runs the handshake,
+// sends a single message and disconnects. It lets command line clients,
+// which keep no peers, reach a node.
+func sendOneShot(addr, command string, payload interface{}) error {
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	p := newPeer(conn, addr, false)
+	if err := p.handshake(0); err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	return WriteMessage(conn, command, gobEncode(payload))
+}
+
+// randomNonce returns a random non-zero nonce
+func randomNonce() uint64 {
+	buf := make([]byte, 8)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			log.Panic(err)
+		}
+		if nonce := binary.LittleEndian.Uint64(buf); nonce != 0 {
+			return nonce
+		}
+	}
+}
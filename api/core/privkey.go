@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NlaakStudios/Blockchain/api/utils"
+)
+
+// privKeyVersion starts the payload of exported private keys
+const privKeyVersion = byte(0x80)
+
+// privKeyLen is the length of exported private keys: P-256 scalars and
+// Ed25519 seeds
+const privKeyLen = 32
+
+// ErrWalletWatchOnly is returned when signing with an address whose private key is not in the wallet
+var ErrWalletWatchOnly = errors.New("Address is watch-only, its private key is not in the wallet")
+
+// ExportKey encodes the private key of the wallet as text: Base58 of the
+// version, the signature scheme, the 32 byte key and a checksum
+func (w *Wallet) ExportKey() (string, error) {
+	if w.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	key := make([]byte, privKeyLen)
+	if w.Scheme == SchemeEd25519 {
+		copy(key, w.SecretKey[:privKeyLen])
+	} else {
+		w.PrivateKey.D.FillBytes(key)
+	}
+
+	payload := append([]byte{privKeyVersion, byte(w.Scheme)}, key...)
+	payload = append(payload, checksum(payload)...)
+
+	return string(utils.Base58Encode(payload)), nil
+}
+
+// ImportKey decodes a private key encoded by ExportKey and returns its wallet
+func ImportKey(encoded string) (*Wallet, error) {
+	payload, err := utils.Base58Decode([]byte(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 2+privKeyLen+addressChecksumLen {
+		return nil, errors.New("Private key has the wrong length")
+	}
+
+	versionedPayload := payload[:len(payload)-addressChecksumLen]
+	if !bytes.Equal(payload[len(payload)-addressChecksumLen:], checksum(versionedPayload)) {
+		return nil, errors.New("Private key checksum does not match")
+	}
+	if versionedPayload[0] != privKeyVersion {
+		return nil, errors.New("Private key has an unknown version")
+	}
+
+	impl, err := GetScheme(SigScheme(versionedPayload[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return impl.WalletFromKey(versionedPayload[2:])
+}
+
+// ImportWallet adds a wallet with a private key from elsewhere, which
+// stops its address from being watch-only. Encrypted wallets must be
+// unlocked.
+func (ws *Wallets) ImportWallet(wallet *Wallet) (string, error) {
+	if address, known := ws.FindByPubKey(wallet.PublicKey); known != nil {
+		return address, nil
+	}
+	address := string(wallet.GetAddress())
+
+	if err := ws.addKey(address, wallet); err != nil {
+		return "", err
+	}
+	ws.Wallets[address] = wallet
+	ws.noteAddress(address, PurposeImported)
+
+	return address, nil
+}
+
+// ImportAddress watches an address without its private key. Its outputs
+// count in the balance of the wallet, but it can never sign.
+func (ws *Wallets) ImportAddress(address string) error {
+	_, pubKeyHash, err := DecodeAddress(address)
+	if err != nil {
+		return err
+	}
+	if _, _, err := ws.findPubKey(pubKeyHash, nil); err == nil {
+		return errors.New("Address is already in the wallet with its private key")
+	}
+
+	ws.noteAddress(address, PurposeWatchOnly)
+
+	return nil
+}
+
+// IsWatchOnly reports whether an address is watched without its private key
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	info, ok := ws.Addresses[address]
+
+	return ok && info.Purpose == PurposeWatchOnly
+}
+
+// GetWatchOnly returns the watch-only addresses of the wallet file
+func (ws *Wallets) GetWatchOnly() []string {
+	var addresses []string
+
+	for address, info := range ws.Addresses {
+		if info.Purpose == PurposeWatchOnly {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+// AddressTransactions returns the transactions of the chain paying to or
+// spending from a public key hash, newest first
+func (bc *Blockchain) AddressTransactions(pubKeyHash []byte) []*Transaction {
+	var txs []*Transaction
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+	Transactions:
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if !out.IsData() && out.IsLockedWithKey(pubKeyHash) {
+					txs = append(txs, tx)
+					continue Transactions
+				}
+			}
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Vin {
+					if bytes.Equal(HashPubKey(in.PubKey), pubKeyHash) {
+						txs = append(txs, tx)
+						continue Transactions
+					}
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return txs
+}
@@ -14,8 +14,6 @@ var (
 	maxNonce = math.MaxInt64
 )
 
-const targetBits = 16
-
 // ProofOfWork represents a proof-of-work
 type ProofOfWork struct {
 	block  *Block
@@ -25,7 +23,7 @@ type ProofOfWork struct {
 // NewProofOfWork builds and returns a ProofOfWork
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	target.Lsh(target, uint(256-ActiveParams.TargetBits))
 
 	pow := &ProofOfWork{b, target}
 
@@ -33,12 +31,17 @@ func NewProofOfWork(b *Block) *ProofOfWork {
 }
 
 func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	return powData(pow.block.PrevBlockHash, pow.block.HashTransactions(), pow.block.Timestamp, nonce)
+}
+
+// powData returns the data hashed by the proof-of-work of a block
+func powData(prevBlockHash, merkleRoot []byte, timestamp int64, nonce int) []byte {
 	data := bytes.Join(
 		[][]byte{
-			pow.block.PrevBlockHash,
-			pow.block.HashTransactions(),
-			utils.IntToHex(pow.block.Timestamp),
-			utils.IntToHex(int64(targetBits)),
+			prevBlockHash,
+			merkleRoot,
+			utils.IntToHex(timestamp),
+			utils.IntToHex(int64(ActiveParams.TargetBits)),
 			utils.IntToHex(int64(nonce)),
 		},
 		[]byte{},
@@ -47,6 +50,13 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	return data
 }
 
+// hash returns the hash of the block with the given nonce
+func (pow *ProofOfWork) hash(nonce int) []byte {
+	hash := sha256.Sum256(pow.prepareData(nonce))
+
+	return hash[:]
+}
+
 // Run performs a proof-of-work
 func (pow *ProofOfWork) Run() (int, []byte) {
 	var hashInt big.Int
@@ -72,6 +82,23 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 	return nonce, hash[:]
 }
 
+// Search tries count nonces from start, quietly, and returns the first one
+// whose hash meets the target
+func (pow *ProofOfWork) Search(start, count int) (int, []byte, bool) {
+	var hashInt big.Int
+
+	for nonce := start; nonce-start < count && nonce < maxNonce; nonce++ {
+		hash := sha256.Sum256(pow.prepareData(nonce))
+		hashInt.SetBytes(hash[:])
+
+		if hashInt.Cmp(pow.target) == -1 {
+			return nonce, hash[:], true
+		}
+	}
+
+	return 0, nil, false
+}
+
 // Validate validates block's PoW
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
@@ -80,7 +107,21 @@ func (pow *ProofOfWork) Validate() bool {
 	hash := sha256.Sum256(data)
 	hashInt.SetBytes(hash[:])
 
-	isValid := hashInt.Cmp(pow.target) == -1
+	isValid := hashInt.Cmp(pow.target) == -1 && bytes.Equal(pow.block.Hash, hash[:])
 
 	return isValid
 }
+
+// ValidateHeader checks that the hash of a block header is the hash of its
+// fields and meets the proof-of-work target, without the transactions
+func ValidateHeader(h *BlockHeader) bool {
+	var hashInt big.Int
+
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-ActiveParams.TargetBits))
+
+	hash := sha256.Sum256(powData(h.PrevBlockHash, h.MerkleRoot, h.Timestamp, h.Nonce))
+	hashInt.SetBytes(hash[:])
+
+	return hashInt.Cmp(target) == -1 && bytes.Equal(h.Hash, hash[:])
+}
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+)
+
+// psbtMagic starts every encoded partially signed transaction
+var psbtMagic = []byte("psbt\xff")
+
+// Errors returned by partially signed transactions
+var (
+	ErrPSBTMalformed  = errors.New("Partially signed transaction is malformed")
+	ErrPSBTMismatch   = errors.New("Partially signed transactions are not of the same transaction")
+	ErrPSBTIncomplete = errors.New("Partially signed transaction is missing signatures")
+	ErrPSBTInvalid    = errors.New("Partially signed transaction has an invalid signature")
+)
+
+// PSBT is a partially signed transaction: a transaction whose inputs are
+// signed one by one, possibly by different wallets on machines without the
+// chain, together with the transactions whose outputs they spend. Those are
+// checked against the IDs the inputs refer to, so that signers can trust
+// the values of the outputs, which the signatures do not commit to.
+type PSBT struct {
+	Tx      Transaction   // inputs carry the public keys signing them
+	PrevTXs []Transaction // the transactions spent by the inputs
+}
+
+// NewPSBT creates an unsigned transaction sending amount of an asset from
+// the outputs of several wallets, of which only the public keys are needed.
+// Inputs are chosen among all their outputs by the coin selection strategy,
+// and the fee of feeRate base units per byte is paid in native coins. Change
+// goes to the change address, or back to the first wallet when it is empty.
+func NewPSBT(from []*Wallet, to, change string, asset []byte, amount Amount, strategy coinselect.Strategy, feeRate Amount, UTXOSet *UTXOSet, mempool *Mempool) *PSBT {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	if len(from) == 0 {
+		log.Panic("ERROR: No wallet to spend from")
+	}
+	if amount == 0 {
+		log.Panic("ERROR: Amount must be positive")
+	}
+
+	if change == "" {
+		change = fmt.Sprintf("%s", from[0].GetAddress())
+	}
+	outputs = append(outputs, *NewAssetTXOutput(amount, asset, to))
+
+	// Inputs are measured with the largest public key among the wallets
+	widest := from[0]
+	for _, wallet := range from {
+		if len(wallet.PublicKey) > len(widest.PublicKey) {
+			widest = wallet
+		}
+	}
+
+	if asset != nil {
+		params := coinselect.Params{Target: uint64(amount)}
+		assetInputs, selection := selectPooledInputs(from, asset, strategy, params, UTXOSet, mempool)
+		inputs = append(inputs, assetInputs...)
+		if selection.Change > 0 {
+			outputs = append(outputs, *NewAssetTXOutput(Amount(selection.Change), asset, change)) // a change
+		}
+	}
+
+	if asset == nil || feeRate > 0 {
+		params := selectParams(widest, Transaction{nil, inputs, outputs, nil}, change, feeRate)
+		if asset == nil {
+			params.Target = uint64(amount)
+		}
+		coinInputs, selection := selectPooledInputs(from, nil, strategy, params, UTXOSet, mempool)
+		inputs = append(inputs, coinInputs...)
+		if selection.Change > 0 {
+			outputs = append(outputs, *NewTXOutput(Amount(selection.Change), change)) // a change
+		}
+	}
+
+	tx := Transaction{nil, inputs, outputs, nil}
+	tx.ID = tx.Hash()
+
+	var prevTXs []Transaction
+	found := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		prevID := hex.EncodeToString(vin.Txid)
+		if found[prevID] {
+			continue
+		}
+		found[prevID] = true
+
+		if mempool != nil {
+			if parent, ok := mempool.Get(vin.Txid); ok {
+				prevTXs = append(prevTXs, parent)
+				continue
+			}
+		}
+		prevTx, err := UTXOSet.Blockchain.FindTransaction(vin.Txid)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		prevTXs = append(prevTXs, prevTx)
+	}
+
+	return &PSBT{tx, prevTXs}
+}
+
+// PublicWallet returns a wallet holding only the public key of an address.
+// The key is looked up among pubKeys, in the wallet file, then in the
+// inputs of the chain that spent from the address.
+func (ws *Wallets) PublicWallet(address string, pubKeys [][]byte, bc *Blockchain) (*Wallet, error) {
+	scheme, pubKeyHash, err := DecodeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pubKey := range pubKeys {
+		if bytes.Equal(HashPubKey(pubKey), pubKeyHash) {
+			return &Wallet{PublicKey: pubKey, Scheme: scheme, Format: addressFormat(address)}, nil
+		}
+	}
+	pubKey, _, err := ws.findPubKey(pubKeyHash, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{PublicKey: pubKey, Scheme: scheme, Format: addressFormat(address)}, nil
+}
+
+// findPubKey returns the public key of a public key hash and the scheme
+// inputs of its outputs are signed with, from the wallet file or from an
+// input of the chain that spent from it
+func (ws *Wallets) findPubKey(pubKeyHash []byte, bc *Blockchain) ([]byte, SigScheme, error) {
+	for _, w := range ws.Wallets {
+		if bytes.Equal(HashPubKey(w.PublicKey), pubKeyHash) {
+			return w.PublicKey, w.InputScheme(), nil
+		}
+	}
+	if bc != nil {
+		for _, tx := range bc.AddressTransactions(pubKeyHash) {
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, vin := range tx.Vin {
+				if vin.UsesKey(pubKeyHash) {
+					return vin.PubKey, vin.Scheme, nil
+				}
+			}
+		}
+	}
+
+	return nil, 0, ErrPubKeyUnknown
+}
+
+// Sign signs the unsigned inputs whose keys are in the wallet file, which
+// must be unlocked when encrypted. It returns the number of inputs signed.
+func (p *PSBT) Sign(wallets *Wallets) (int, error) {
+	if err := p.check(); err != nil {
+		return 0, err
+	}
+
+	return wallets.signInputs(&p.Tx, p.prevTXs())
+}
+
+// Combine adds the signatures of another copy of the same transaction
+func (p *PSBT) Combine(other *PSBT) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	if err := other.check(); err != nil {
+		return err
+	}
+	if !bytes.Equal(p.Tx.ID, other.Tx.ID) {
+		return ErrPSBTMismatch
+	}
+
+	for inID, vin := range other.Tx.Vin {
+		if p.Tx.Vin[inID].Signature == nil {
+			p.Tx.Vin[inID].Signature = vin.Signature
+		}
+	}
+
+	return nil
+}
+
+// IsComplete reports whether every input is signed
+func (p *PSBT) IsComplete() bool {
+	for _, vin := range p.Tx.Vin {
+		if vin.Signature == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Finalize checks the signatures of a complete transaction and returns it
+// ready for the mempool
+func (p *PSBT) Finalize() (*Transaction, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if !p.IsComplete() {
+		return nil, ErrPSBTIncomplete
+	}
+
+	tx := p.Tx
+	if !tx.Verify(p.prevTXs()) {
+		return nil, ErrPSBTInvalid
+	}
+
+	return &tx, nil
+}
+
+// Fee returns the native coins the transaction leaves to the miner
+func (p *PSBT) Fee() Amount {
+	return p.Tx.Fee(p.prevTXs())
+}
+
+// Encode returns the transaction as Base64 text, to carry between machines
+func (p *PSBT) Encode() string {
+	var encoded bytes.Buffer
+	encoded.Write(psbtMagic)
+
+	err := gob.NewEncoder(&encoded).Encode(p)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encoded.Bytes())
+}
+
+// DecodePSBT decodes a transaction encoded by Encode
+func DecodePSBT(text string) (*PSBT, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil || !bytes.HasPrefix(data, psbtMagic) {
+		return nil, ErrPSBTMalformed
+	}
+
+	var p PSBT
+	err = gob.NewDecoder(bytes.NewReader(data[len(psbtMagic):])).Decode(&p)
+	if err != nil {
+		return nil, ErrPSBTMalformed
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// check makes sure every input comes with the transaction it spends, whose
+// hash is the ID the input refers to, and spends one of its outputs owned by
+// its public key, and that the ID commits to the unsigned transaction
+func (p *PSBT) check() error {
+	if p.Tx.IsCoinbase() || len(p.Tx.Vin) == 0 {
+		return ErrPSBTMalformed
+	}
+	prevTXs := p.prevTXs()
+	for _, vin := range p.Tx.Vin {
+		prevTx, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return ErrPSBTMalformed
+		}
+		if !vin.UsesKey(prevTx.Vout[vin.Vout].PubKeyHash) {
+			return ErrPSBTMalformed
+		}
+	}
+	if !bytes.Equal(p.Tx.ID, p.Tx.unsignedHash()) {
+		return ErrPSBTMalformed
+	}
+
+	return nil
+}
+
+// prevTXs returns the carried transactions by the hash of their contents,
+// as signing and verifying look them up. Their own IDs are not trusted.
+func (p *PSBT) prevTXs() map[string]Transaction {
+	prevTXs := make(map[string]Transaction)
+	for _, prevTx := range p.PrevTXs {
+		prevTx.ID = prevTx.unsignedHash()
+		prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
+	}
+
+	return prevTXs
+}
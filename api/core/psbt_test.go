@@ -0,0 +1,148 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+)
+
+// testPSBT creates a transaction spending the coinbases of two wallets,
+// which are returned in their own wallet files
+func testPSBT(t *testing.T) (*PSBT, *UTXOSet, *Wallets, *Wallets) {
+	first, second := NewWallet(SchemeP256), NewWallet(SchemeEd25519)
+	UTXOSet := &UTXOSet{newTestBlockchain(t, first, second)}
+
+	files := make([]*Wallets, 2)
+	for i, w := range []*Wallet{first, second} {
+		files[i] = &Wallets{Wallets: map[string]*Wallet{fmt.Sprintf("%s", w.GetAddress()): w}, Addresses: make(map[string]*AddressInfo)}
+	}
+
+	// Only the public keys are needed to create it
+	from := []*Wallet{
+		{PublicKey: first.PublicKey, Scheme: first.Scheme},
+		{PublicKey: second.PublicKey, Scheme: second.Scheme},
+	}
+	to := NewWallet(SchemeEd25519)
+	amount := ActiveParams.BlockSubsidy(1) + 1
+	p := NewPSBT(from, string(to.GetAddress()), "", nil, amount, coinselect.LargestFirst, 1, UTXOSet, nil)
+	if len(p.Tx.Vin) != 2 {
+		t.Fatalf("PSBT spends %d inputs, want 2", len(p.Tx.Vin))
+	}
+
+	return p, UTXOSet, files[0], files[1]
+}
+
+func TestPSBTRoundTrip(t *testing.T) {
+	p, _, _, _ := testPSBT(t)
+
+	decoded, err := DecodePSBT(p.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Encode() != p.Encode() {
+		t.Errorf("DecodePSBT(Encode()) differs from the original")
+	}
+	if decoded.Fee() != p.Fee() || p.Fee() == 0 {
+		t.Errorf("Fee() = %d after decoding, want %d", decoded.Fee(), p.Fee())
+	}
+}
+
+func TestPSBTSignCombineFinalize(t *testing.T) {
+	p, UTXOSet, firstFile, secondFile := testPSBT(t)
+	encoded := p.Encode()
+
+	// Each wallet signs its own copy
+	copies := make([]*PSBT, 2)
+	for i, file := range []*Wallets{firstFile, secondFile} {
+		var err error
+		if copies[i], err = DecodePSBT(encoded); err != nil {
+			t.Fatal(err)
+		}
+		signed, err := copies[i].Sign(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signed != 1 {
+			t.Errorf("Sign signed %d inputs, want 1", signed)
+		}
+		if copies[i].IsComplete() {
+			t.Errorf("a copy signed by one wallet is complete")
+		}
+		if _, err := copies[i].Finalize(); err != ErrPSBTIncomplete {
+			t.Errorf("Finalize: %v, want %v", err, ErrPSBTIncomplete)
+		}
+	}
+
+	combined, err := DecodePSBT(copies[0].Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := combined.Combine(copies[1]); err != nil {
+		t.Fatal(err)
+	}
+	if !combined.IsComplete() {
+		t.Fatal("combined copies are not complete")
+	}
+	tx, err := combined.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mempool := NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+	if err := mempool.Add(tx, UTXOSet); err != nil {
+		t.Errorf("finalized transaction refused by the mempool: %s", err)
+	}
+
+	// A bad signature is caught
+	combined.Tx.Vin[0].Signature = append([]byte{}, combined.Tx.Vin[1].Signature...)
+	if _, err := combined.Finalize(); err != ErrPSBTInvalid {
+		t.Errorf("Finalize with a bad signature: %v, want %v", err, ErrPSBTInvalid)
+	}
+}
+
+func TestPSBTCombineMismatch(t *testing.T) {
+	p, _, _, _ := testPSBT(t)
+	other, _, _, _ := testPSBT(t)
+
+	if err := p.Combine(other); err != ErrPSBTMismatch {
+		t.Errorf("Combine: %v, want %v", err, ErrPSBTMismatch)
+	}
+}
+
+func TestDecodePSBTMalformed(t *testing.T) {
+	p, _, _, _ := testPSBT(t)
+
+	// The value of a spent output is changed, so the transaction no longer
+	// has the ID the input refers to
+	tampered := *p
+	tampered.PrevTXs = append([]Transaction{}, p.PrevTXs...)
+	prevTx := tampered.PrevTXs[0]
+	prevTx.Vout = append([]TXOutput{}, prevTx.Vout...)
+	prevTx.Vout[0].Value++
+	tampered.PrevTXs[0] = prevTx
+
+	// The transaction no longer matches its ID
+	changed := *p
+	changed.Tx.Vout = append([]TXOutput{}, p.Tx.Vout...)
+	changed.Tx.Vout[0].Value--
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"not base64", "not a psbt!"},
+		{"no magic", base64.StdEncoding.EncodeToString([]byte("hello"))},
+		{"truncated", base64.StdEncoding.EncodeToString([]byte("psbt\xff\x01\x02"))},
+		{"tampered previous transaction", tampered.Encode()},
+		{"missing previous transactions", (&PSBT{p.Tx, nil}).Encode()},
+		{"changed transaction", changed.Encode()},
+	}
+
+	for _, test := range tests {
+		if _, err := DecodePSBT(test.text); err != ErrPSBTMalformed {
+			t.Errorf("%s: %v, want %v", test.name, err, ErrPSBTMalformed)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by raw transactions
+var (
+	ErrPubKeyUnknown  = errors.New("Public key of the owner is unknown: it is not in the wallet file and never spent on the chain")
+	ErrOutputNotFound = errors.New("Output is spent or does not exist")
+	ErrRawTxMalformed = errors.New("Raw transaction is malformed")
+)
+
+// TxView is a readable view of a transaction. Amounts are in base units.
+type TxView struct {
+	TxID        string         `json:"txid"`
+	Size        int            `json:"size"`
+	Replaceable bool           `json:"replaceable"`
+	Signed      bool           `json:"signed"`
+	Fee         *Amount        `json:"fee,omitempty"` // unknown when an input spends an unknown output
+	Inputs      []TxInputView  `json:"inputs"`
+	Outputs     []TxOutputView `json:"outputs"`
+}
+
+// TxInputView is a readable view of a transaction input
+type TxInputView struct {
+	TxID       string  `json:"txid"`
+	Vout       int     `json:"vout"`
+	PubKey     string  `json:"pubkey"`
+	Scheme     string  `json:"scheme"`
+	Sequence   uint32  `json:"sequence"`
+	Signed     bool    `json:"signed"`
+	Value      *Amount `json:"value,omitempty"`
+	Asset      string  `json:"asset,omitempty"`
+	PubKeyHash string  `json:"pubkeyhash,omitempty"`
+}
+
+// TxOutputView is a readable view of a transaction output
+type TxOutputView struct {
+	N          int    `json:"n"`
+	Value      Amount `json:"value"`
+	Asset      string `json:"asset,omitempty"`
+	PubKeyHash string `json:"pubkeyhash,omitempty"`
+	Data       string `json:"data,omitempty"`
+}
+
+// ParseOutpoint parses an output written as txid:vout
+func ParseOutpoint(outpoint string) ([]byte, int, error) {
+	parts := strings.Split(outpoint, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("Input %q is not txid:vout", outpoint)
+	}
+
+	txID, err := hex.DecodeString(parts[0])
+	if err != nil || len(txID) != 32 {
+		return nil, 0, fmt.Errorf("Input %q has an invalid transaction ID", outpoint)
+	}
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return nil, 0, fmt.Errorf("Input %q has an invalid output index", outpoint)
+	}
+
+	return txID, vout, nil
+}
+
+// NewRawTransaction creates an unsigned transaction spending the given
+// outputs of the UTXO set or the mempool, if any, into the given outputs,
+// as they are: nothing is added for change or the fee. The public keys of
+// the owners are looked up in the wallet file and the chain. Inputs signal
+// replace-by-fee when replaceable is set.
+func NewRawTransaction(inputs []string, outputs []TXOutput, replaceable bool, wallets *Wallets, UTXOSet *UTXOSet, mempool *Mempool) (*Transaction, error) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, errors.New("Transaction needs at least one input and one output")
+	}
+
+	sequence := SequenceFinal
+	if replaceable {
+		sequence = SequenceReplaceable
+	}
+
+	var vin []TXInput
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		txID, vout, err := ParseOutpoint(input)
+		if err != nil {
+			return nil, err
+		}
+		if seen[outpoint(txID, vout)] {
+			return nil, fmt.Errorf("Input %s is spent twice", input)
+		}
+		seen[outpoint(txID, vout)] = true
+
+		prevOut, ok := findOutput(txID, vout, UTXOSet, mempool)
+		if !ok || (mempool != nil && mempool.IsSpent(txID, vout)) {
+			return nil, fmt.Errorf("%s: %s", input, ErrOutputNotFound)
+		}
+		pubKey, scheme, err := wallets.findPubKey(prevOut.PubKeyHash, UTXOSet.Blockchain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", input, err)
+		}
+
+		vin = append(vin, TXInput{txID, vout, nil, pubKey, scheme, sequence})
+	}
+
+	tx := Transaction{nil, vin, outputs, nil}
+	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
+
+// SignTransaction signs the unsigned inputs of a transaction whose keys are
+// in the wallet file, which must be unlocked when encrypted. The outputs
+// they spend are looked up in the mempool, if any, and the UTXO set. It
+// returns the number of inputs signed.
+func (ws *Wallets) SignTransaction(tx *Transaction, UTXOSet *UTXOSet, mempool *Mempool) (int, error) {
+	prevTXs := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		prevOut, ok := findOutput(vin.Txid, vin.Vout, UTXOSet, mempool)
+		if !ok {
+			return 0, fmt.Errorf("%x:%d: %s", vin.Txid, vin.Vout, ErrOutputNotFound)
+		}
+		addPrevOutput(prevTXs, vin, prevOut)
+	}
+
+	return ws.signInputs(tx, prevTXs)
+}
+
+// signInputs signs the unsigned inputs of tx whose keys are in the wallet
+// file and returns how many were signed
+func (ws *Wallets) signInputs(tx *Transaction, prevTXs map[string]Transaction) (int, error) {
+	signed := 0
+	for inID, vin := range tx.Vin {
+		if vin.Signature != nil {
+			continue
+		}
+		_, wallet := ws.FindByPubKey(vin.PubKey)
+		if wallet == nil {
+			continue
+		}
+
+		signature, err := wallet.Sign(tx.SigHash(inID, prevTXs))
+		if err != nil {
+			return signed, err
+		}
+		tx.Vin[inID].Signature = signature
+		signed++
+	}
+
+	return signed, nil
+}
+
+// View returns a readable view of the transaction. The outputs its inputs
+// spend are looked up in the mempool, if any, and the UTXO set to compute
+// the fee, which is left out when one of them cannot be found.
+func (tx *Transaction) View(UTXOSet *UTXOSet, mempool *Mempool) TxView {
+	view := TxView{
+		TxID:        hex.EncodeToString(tx.ID),
+		Size:        len(tx.Serialize()),
+		Replaceable: tx.IsReplaceable(),
+		Signed:      true,
+		Inputs:      []TxInputView{},
+		Outputs:     []TxOutputView{},
+	}
+
+	prevTXs := make(map[string]Transaction)
+	allKnown := !tx.IsCoinbase()
+	for _, vin := range tx.Vin {
+		input := TxInputView{
+			TxID:     hex.EncodeToString(vin.Txid),
+			Vout:     vin.Vout,
+			PubKey:   hex.EncodeToString(vin.PubKey),
+			Scheme:   vin.Scheme.String(),
+			Sequence: vin.Sequence,
+			Signed:   vin.Signature != nil,
+		}
+		view.Signed = view.Signed && input.Signed
+
+		if prevOut, ok := findOutput(vin.Txid, vin.Vout, UTXOSet, mempool); ok && !tx.IsCoinbase() {
+			value := prevOut.Value
+			input.Value = &value
+			input.Asset = hex.EncodeToString(prevOut.Asset)
+			input.PubKeyHash = hex.EncodeToString(prevOut.PubKeyHash)
+			addPrevOutput(prevTXs, vin, prevOut)
+		} else {
+			allKnown = false
+		}
+		view.Inputs = append(view.Inputs, input)
+	}
+
+	for n, out := range tx.Vout {
+		view.Outputs = append(view.Outputs, TxOutputView{
+			N:          n,
+			Value:      out.Value,
+			Asset:      hex.EncodeToString(out.Asset),
+			PubKeyHash: hex.EncodeToString(out.PubKeyHash),
+			Data:       hex.EncodeToString(out.Data),
+		})
+	}
+
+	if allKnown {
+		fee := tx.Fee(prevTXs)
+		view.Fee = &fee
+	}
+
+	return view
+}
+
+// DecodeRawTransaction decodes a transaction serialized as hex
+func DecodeRawTransaction(rawHex string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(rawHex))
+	if err != nil {
+		return nil, ErrRawTxMalformed
+	}
+
+	var tx Transaction
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&tx)
+	if err != nil || len(tx.Vin) == 0 {
+		return nil, ErrRawTxMalformed
+	}
+
+	return &tx, nil
+}
+
+// EncodeRawTransaction serializes a transaction as hex
+func EncodeRawTransaction(tx *Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// findOutput looks up an output of a transaction of the mempool, if any,
+// or of the UTXO set, where spent outputs are not found
+func findOutput(txID []byte, vout int, UTXOSet *UTXOSet, mempool *Mempool) (TXOutput, bool) {
+	if mempool != nil {
+		if parent, ok := mempool.Get(txID); ok {
+			if vout >= len(parent.Vout) || parent.Vout[vout].IsData() {
+				return TXOutput{}, false
+			}
+			return parent.Vout[vout], true
+		}
+	}
+
+	outs, ok := UTXOSet.FindOutputs(txID)
+	if !ok {
+		return TXOutput{}, false
+	}
+	out, ok := outs.Outputs[vout]
+
+	return out, ok
+}
+
+// addPrevOutput adds the output an input spends to the transactions
+// signing and verifying look it up in. The output must have been found at
+// vin.Vout, which bounds the outputs added before it.
+func addPrevOutput(prevTXs map[string]Transaction, vin TXInput, prevOut TXOutput) {
+	prevID := hex.EncodeToString(vin.Txid)
+	prevTx, ok := prevTXs[prevID]
+	if !ok {
+		prevTx = Transaction{vin.Txid, nil, nil, nil}
+	}
+	for len(prevTx.Vout) <= vin.Vout {
+		prevTx.Vout = append(prevTx.Vout, TXOutput{})
+	}
+	prevTx.Vout[vin.Vout] = prevOut
+	prevTXs[prevID] = prevTx
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+)
+
+// defaultRPCHost is the host the RPC server binds to when its listen
+// address only gives a port, so that only local tools reach it
+const defaultRPCHost = "127.0.0.1"
+
+// Errors returned to RPC clients
+var (
+	ErrRPCAuth              = errors.New("Authenticate with the cookie of the node first")
+	ErrRPCCookie            = errors.New("Wrong RPC cookie")
+	ErrRPCWalletUnencrypted = errors.New("Wallet is not encrypted, encrypt it or start the node with -rpcallowunencrypted")
+)
+
+// RPCConfig configures the RPC server of a node. Wallet methods are only
+// served for encrypted wallets unlocked with walletpassphrase, unless
+// AllowUnencryptedWallet is set.
+type RPCConfig struct {
+	Listen                 string // address to listen on, none when empty
+	AllowUnencryptedWallet bool
+}
+
+// RPCRequest is a call to the RPC server of a node. Requests and responses
+// are JSON objects, one per line, over a TCP connection. The first request
+// of a connection must be auth, with the cookie the node wrote to its
+// cookie file.
+type RPCRequest struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse answers an RPCRequest with the same ID
+type RPCResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// rpcMethod handles the parameters of a request and returns its result
+type rpcMethod func(s *rpcServer, params json.RawMessage) (interface{}, error)
+
+// RPCAuthParams are the parameters of auth
+type RPCAuthParams struct {
+	Cookie string `json:"cookie"`
+}
+
+// rpcMethods are the methods served by name to authenticated clients
+var rpcMethods = map[string]rpcMethod{
+	"getblocktemplate":   (*rpcServer).getBlockTemplate,
+	"submitblock":        (*rpcServer).submitBlock,
+	"sendrawtransaction": (*rpcServer).sendRawTransaction,
+	"walletpassphrase":   (*rpcServer).walletPassphrase,
+	"walletlock":         (*rpcServer).walletLock,
+	"send":               (*rpcServer).send,
+	"setmocktime":        (*rpcServer).setMockTime,
+	"generate":           (*rpcServer).generate,
+}
+
+// rpcServer serves the local tools of a node: external miners and wallet commands
+type rpcServer struct {
+	bc     *Blockchain
+	nodeID string
+	config RPCConfig
+	cookie string
+
+	mu        sync.Mutex
+	tip       []byte
+	templates map[string]*BlockTemplate
+	order     []string
+
+	walletMu  sync.Mutex
+	wallets   *Wallets
+	lockTimer *time.Timer
+}
+
+// StartRPCServer listens on the address of config for local tools, binding
+// to the loopback interface when it only gives a port. A new cookie is
+// written to the cookie file of the node, readable by its owner only. It
+// serves the requests of the tools until the listener fails.
+func StartRPCServer(config RPCConfig, nodeID string, bc *Blockchain) {
+	address := rpcListenAddress(config.Listen)
+	ln, err := net.Listen(protocol, address)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer ln.Close()
+
+	cookie, err := writeRPCCookie(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("RPC server listening on %s\n", address)
+	s := &rpcServer{bc: bc, nodeID: nodeID, config: config, cookie: cookie, templates: make(map[string]*BlockTemplate)}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Panic(err)
+		}
+		go s.serve(conn)
+	}
+}
+
+// rpcListenAddress adds the default host to a listen address giving only a
+// port, ie 8333 or :8333
+func rpcListenAddress(address string) string {
+	if !strings.Contains(address, ":") {
+		address = ":" + address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != "" {
+		return address
+	}
+
+	return net.JoinHostPort(defaultRPCHost, port)
+}
+
+// GetRPCCookieFile returns the path of the file holding the RPC cookie of a node
+func GetRPCCookieFile(nodeID string) string {
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathRPCCookie)
+
+	return fmt.Sprintf(str, nodeID)
+}
+
+// writeRPCCookie writes a new random cookie to the cookie file of a node.
+// An older file is removed first so that the new one is created with
+// owner-only permissions.
+func writeRPCCookie(nodeID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	cookie := hex.EncodeToString(buf)
+
+	path := GetRPCCookieFile(nodeID)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(cookie)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return cookie, err
+}
+
+// ReadRPCCookie reads the cookie of a running node
+func ReadRPCCookie(nodeID string) (string, error) {
+	data, err := ioutil.ReadFile(GetRPCCookieFile(nodeID))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// serve answers the requests of one client until it disconnects. Requests
+// other than auth are refused until the client is authenticated.
+func (s *rpcServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	authenticated := false
+	for {
+		var req RPCRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		resp := RPCResponse{ID: req.ID}
+		var result interface{}
+		var err error
+		switch {
+		case req.Method == "auth":
+			err = s.auth(req.Params)
+			authenticated = err == nil
+			result = authenticated
+		case !authenticated:
+			err = ErrRPCAuth
+		default:
+			result, err = s.handle(req)
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result, err = json.Marshal(result)
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+		if req.Method == "auth" && !authenticated {
+			return
+		}
+	}
+}
+
+// auth checks the cookie sent by a client
+func (s *rpcServer) auth(data json.RawMessage) error {
+	var params RPCAuthParams
+	if err := decodeParams(data, &params); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(params.Cookie), []byte(s.cookie)) != 1 {
+		return ErrRPCCookie
+	}
+
+	return nil
+}
+
+// handle calls the method of a request. Code shared with the command line
+// panics on bad input, which must not bring the node down.
+func (s *rpcServer) handle(req RPCRequest) (result interface{}, err error) {
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		return nil, fmt.Errorf("Unknown method %q", req.Method)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("%s", r)
+		}
+	}()
+
+	return method(s, req.Params)
+}
+
+// decodeParams unmarshals the parameters of a request, which may be omitted
+func decodeParams(data json.RawMessage, params interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, params)
+}
+
+// RPCClient talks to the RPC server of a node
+type RPCClient struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	next int
+}
+
+// DialRPC connects to the RPC server listening on address
+func DialRPC(address string) (*RPCClient, error) {
+	conn, err := net.Dial(protocol, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RPCClient{conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}, nil
+}
+
+// Authenticate sends the cookie of the node, which must be done before
+// calling any other method
+func (c *RPCClient) Authenticate(cookie string) error {
+	var ok bool
+
+	return c.Call("auth", RPCAuthParams{cookie}, &ok)
+}
+
+// Close closes the connection to the node
+func (c *RPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// Call calls a method with the given parameters and decodes its result
+func (c *RPCClient) Call(method string, params, result interface{}) error {
+	c.next++
+	req := RPCRequest{ID: c.next, Method: method}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req.Params = data
+
+	if err := c.enc.Encode(req); err != nil {
+		return err
+	}
+	var resp RPCResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
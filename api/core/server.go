@@ -3,28 +3,37 @@ package core
 import (
 	"bytes"
 	"encoding/gob"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 const protocol = "tcp"
-const nodeVersion = 1
-const commandLength = 12
+const mempoolExpireInterval = time.Minute
 
 var nodeAddress string
 var miningAddress string
-var KnownNodes = []string{
-	"localhost:3000",
+var mempool = NewMempool(defaultMaxMempoolSize, defaultMempoolTTL)
+var miner *Miner
+
+// netAddress is the address of a node as shared in addr messages
+type netAddress struct {
+	Addr      string
+	Services  uint64
+	Timestamp int64 // unix time the node was last seen
 }
-var blocksInTransit = [][]byte{}
-var mempool = make(map[string]Transaction)
 
 type addr struct {
-	AddrList []string
+	AddrList []netAddress
+}
+
+type getaddr struct {
+	AddrFrom string
 }
 
 type block struct {
@@ -32,8 +41,14 @@ type block struct {
 	Block    []byte
 }
 
-type getblocks struct {
+type getheaders struct {
 	AddrFrom string
+	Locator  [][]byte
+	HashStop []byte
+}
+
+type headers struct {
+	Headers []BlockHeader
 }
 
 type getdata struct {
@@ -55,385 +70,327 @@ type tx struct {
 
 type verzion struct {
 	Version    int
+	Services   uint64
 	BestHeight int
 	AddrFrom   string
+	Nonce      uint64
 }
 
-func commandToBytes(command string) []byte {
-	var bytes [commandLength]byte
-
-	for i, c := range command {
-		bytes[i] = byte(c)
-	}
-
-	return bytes[:]
+func sendAddr(p *Peer, addrs []netAddress) {
+	p.QueueMessage("addr", addr{addrs})
 }
 
-func bytesToCommand(bytes []byte) string {
-	var command []byte
-
-	for _, b := range bytes {
-		if b != 0x0 {
-			command = append(command, b)
-		}
-	}
-
-	return fmt.Sprintf("%s", command)
+func sendGetAddr(p *Peer) {
+	p.addrMu.Lock()
+	p.addrTokens += maxAddrPerMsg
+	p.addrMu.Unlock()
+	p.QueueMessage("getaddr", getaddr{nodeAddress})
 }
 
-func extractCommand(request []byte) []byte {
-	return request[:commandLength]
+func sendBlock(p *Peer, b *Block) {
+	p.QueueMessage("block", block{nodeAddress, b.Serialize()})
 }
 
-func requestBlocks() {
-	if len(KnownNodes) > 0 {
-		for _, node := range KnownNodes {
-			sendGetBlocks(node)
-		}
-	}
+func sendInv(p *Peer, kind string, items [][]byte) {
+	p.QueueMessage("inv", inv{nodeAddress, kind, items})
 }
 
-func sendAddr(address string) {
-	if len(KnownNodes) > 0 {
-		nodes := addr{KnownNodes}
-		nodes.AddrList = append(nodes.AddrList, nodeAddress)
-		payload := gobEncode(nodes)
-		request := append(commandToBytes("addr"), payload...)
+// sendGetHeaders asks a peer for the headers following the first hash of
+// locator it has on its chain, up to stop or maxHeadersPerMsg of them
+func sendGetHeaders(p *Peer, locator [][]byte, stop []byte) {
+	p.QueueMessage("getheaders", getheaders{nodeAddress, locator, stop})
+}
 
-		sendData(address, request)
-	}
+func sendNotFound(p *Peer, kind string, id []byte) {
+	p.QueueMessage("notfound", inv{nodeAddress, kind, [][]byte{id}})
 }
 
-func sendBlock(addr string, b *Block) {
-	data := block{nodeAddress, b.Serialize()}
-	payload := gobEncode(data)
-	request := append(commandToBytes("block"), payload...)
+func sendGetData(p *Peer, kind string, id []byte) {
+	p.QueueMessage("getdata", getdata{nodeAddress, kind, id})
+}
 
-	sendData(addr, request)
+func sendTx(p *Peer, tnx *Transaction) {
+	p.QueueMessage("tx", tx{nodeAddress, tnx.Serialize()})
 }
 
-func sendData(addr string, data []byte) {
-	conn, err := net.Dial(protocol, addr)
+// SendTx sends a transaction to the node listening on addr over a
+// connection of its own, for command line clients which keep no peers
+func SendTx(addr string, tnx *Transaction) {
+	err := sendOneShot(addr, "tx", tx{nodeAddress, tnx.Serialize()})
 	if err != nil {
-		fmt.Printf("%s is not available\n", addr)
-		var updatedNodes []string
-
-		if len(KnownNodes) > 0 {
-			for _, node := range KnownNodes {
-				if node != addr {
-					updatedNodes = append(updatedNodes, node)
-				}
-			}
+		fmt.Printf("Could not send the transaction to %s: %s\n", addr, err)
+	}
+}
 
-			KnownNodes = updatedNodes
-		}
+// relayInv announces blocks or transactions to every peer but except.
+// Transactions only go to peers relaying them.
+func relayInv(kind string, items [][]byte, except *Peer) {
+	if connManager == nil {
 		return
 	}
-	defer conn.Close()
 
-	_, err = io.Copy(conn, bytes.NewReader(data))
-	if err != nil {
-		log.Panic(err)
+	for _, p := range connManager.Peers() {
+		if p == except || (kind == "tx" && p.Services&SFNodeRelay == 0) {
+			continue
+		}
+		sendInv(p, kind, items)
 	}
 }
 
-func sendInv(address, kind string, items [][]byte) {
-	inventory := inv{nodeAddress, kind, items}
-	payload := gobEncode(inventory)
-	request := append(commandToBytes("inv"), payload...)
+// handleAddr adds the addresses a peer sends to the address manager. Each
+// address costs the peer a token, refilled at addrTokenRate per second or
+// granted in bulk by a getaddr, so that it cannot flood the node. Small
+// messages of new addresses are relayed to a couple of other peers.
+func handleAddr(p *Peer, request []byte) error {
+	var payload addr
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if len(payload.AddrList) > maxAddrPerMsg {
+		return fmt.Errorf("Too many addresses: %d", len(payload.AddrList))
+	}
 
-	sendData(address, request)
-}
+	now := time.Now()
+	addrs := payload.AddrList
+	p.addrMu.Lock()
+	p.addrTokens += now.Sub(p.addrRefill).Seconds() * addrTokenRate
+	if p.addrTokens > maxAddrPerMsg {
+		p.addrTokens = maxAddrPerMsg
+	}
+	p.addrRefill = now
 
-func sendGetBlocks(address string) {
-	payload := gobEncode(getblocks{nodeAddress})
-	request := append(commandToBytes("getblocks"), payload...)
+	if int(p.addrTokens) < len(addrs) {
+		fmt.Printf("Ignored %d address(es) from %s over the rate limit\n", len(addrs)-int(p.addrTokens), p)
+		addrs = addrs[:int(p.addrTokens)]
+	}
+	p.addrTokens -= float64(len(addrs))
+	p.addrMu.Unlock()
 
-	sendData(address, request)
-}
+	added := addrManager.AddAddresses(addrs, p.Addr)
+	fmt.Printf("Received %d address(es), %d new, %d known\n", len(payload.AddrList), len(added), addrManager.Count())
 
-func sendGetData(address, kind string, id []byte) {
-	payload := gobEncode(getdata{nodeAddress, kind, id})
-	request := append(commandToBytes("getdata"), payload...)
+	if len(payload.AddrList) <= addrRelayMax {
+		relayAddrs(added, now, p)
+	}
 
-	sendData(address, request)
+	return nil
 }
 
-func SendTx(addr string, tnx *Transaction) {
-	data := tx{nodeAddress, tnx.Serialize()}
-	payload := gobEncode(data)
-	request := append(commandToBytes("tx"), payload...)
+// relayAddrs relays the addresses seen recently to addrRelayPeers random
+// peers but except
+func relayAddrs(addrs []netAddress, now time.Time, except *Peer) {
+	fresh := []netAddress{}
+	for _, na := range addrs {
+		if now.Unix()-na.Timestamp < int64(addrRelayAge.Seconds()) {
+			fresh = append(fresh, na)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
 
-	sendData(addr, request)
+	peers := connManager.Peers()
+	relayed := 0
+	for _, i := range randomPerm(len(peers)) {
+		if relayed == addrRelayPeers {
+			break
+		}
+		if peers[i] == except || peers[i].Addr == "" {
+			continue
+		}
+		sendAddr(peers[i], fresh)
+		relayed++
+	}
 }
 
-func sendVersion(addr string, bc *Blockchain) {
-	bestHeight := bc.GetBestHeight()
-	payload := gobEncode(verzion{nodeVersion, bestHeight, nodeAddress})
+// handleGetAddr answers a getaddr of an inbound peer with known addresses,
+// once per connection
+func handleGetAddr(p *Peer, request []byte) error {
+	var payload getaddr
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if !p.Inbound || p.sentAddrs {
+		return nil
+	}
+	p.sentAddrs = true
 
-	request := append(commandToBytes("version"), payload...)
+	sendAddr(p, addrManager.AddressCache())
 
-	sendData(addr, request)
+	return nil
 }
 
-func handleAddr(request []byte) {
-	var buff bytes.Buffer
-	var payload addr
-
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+func handleBlock(p *Peer, request []byte) error {
+	var payload block
+	if err := gobDecode(request, &payload); err != nil {
+		return err
 	}
 
-	if len(KnownNodes) > 0 {
-		KnownNodes = append(KnownNodes, payload.AddrList...)
-		fmt.Printf("There are %d known nodes now!\n", len(KnownNodes))
+	block := &Block{}
+	if err := gobDecode(payload.Block, block); err != nil {
+		return err
 	}
-	requestBlocks()
-}
 
-func handleBlock(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
-	var payload block
+	return syncManager.handleBlock(p, block)
+}
 
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+func handleInv(p *Peer, request []byte, bc *Blockchain) error {
+	var payload inv
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if len(payload.Items) == 0 {
+		return errors.New("Inventory is empty")
 	}
 
-	blockData := payload.Block
-	block := DeserializeBlock(blockData)
-
-	fmt.Println("Recevied a new block!")
-	bc.AddBlock(block)
+	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
-	fmt.Printf("Added block %x\n", block.Hash)
+	if payload.Type == "block" {
+		syncManager.handleInv(p, payload.Items)
+	}
 
-	if len(blocksInTransit) > 0 {
-		blockHash := blocksInTransit[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
+	if payload.Type == "tx" {
+		txID := payload.Items[0]
 
-		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := UTXOSet{bc}
-		UTXOSet.Reindex()
+		if !mempool.Has(txID) {
+			sendGetData(p, "tx", txID)
+		}
 	}
+
+	return nil
 }
 
-func handleInv(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
+// handleNotFound hands the blocks a peer does not have back to the sync manager
+func handleNotFound(p *Peer, request []byte) error {
 	var payload inv
-
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+	if err := gobDecode(request, &payload); err != nil {
+		return err
 	}
 
-	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
-
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		syncManager.handleNotFound(p, payload.Items)
+	}
 
-		blockHash := payload.Items[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
+	return nil
+}
 
-		newInTransit := [][]byte{}
-		for _, b := range blocksInTransit {
-			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
-			}
-		}
-		blocksInTransit = newInTransit
+// handleGetHeaders answers a getheaders with the headers of the chain
+// following the block of the locator they have in common
+func handleGetHeaders(p *Peer, request []byte, bc *Blockchain) error {
+	var payload getheaders
+	if err := gobDecode(request, &payload); err != nil {
+		return err
+	}
+	if len(payload.Locator) == 0 {
+		return errors.New("Locator is empty")
 	}
 
-	if payload.Type == "tx" {
-		txID := payload.Items[0]
+	tip, _ := bc.tip()
+	p.QueueMessage("headers", headers{syncManager.index.headersAfter(tip, payload.Locator, payload.HashStop, maxHeadersPerMsg)})
 
-		if mempool[hex.EncodeToString(txID)].ID == nil {
-			sendGetData(payload.AddrFrom, "tx", txID)
-		}
-	}
+	return nil
 }
 
-func handleGetBlocks(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
-	var payload getblocks
-
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+func handleHeaders(p *Peer, request []byte) error {
+	var payload headers
+	if err := gobDecode(request, &payload); err != nil {
+		return err
 	}
 
-	blocks := bc.GetBlockHashes()
-	sendInv(payload.AddrFrom, "block", blocks)
+	return syncManager.handleHeaders(p, payload.Headers)
 }
 
-func handleGetData(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
+func handleGetData(p *Peer, request []byte, bc *Blockchain) error {
 	var payload getdata
-
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+	if err := gobDecode(request, &payload); err != nil {
+		return err
 	}
 
 	if payload.Type == "block" {
 		block, err := bc.GetBlock([]byte(payload.ID))
 		if err != nil {
-			return
+			sendNotFound(p, "block", payload.ID)
+			return nil
 		}
 
-		sendBlock(payload.AddrFrom, &block)
+		sendBlock(p, &block)
 	}
 
 	if payload.Type == "tx" {
-		txID := hex.EncodeToString(payload.ID)
-		tx := mempool[txID]
+		tx, ok := mempool.Get(payload.ID)
+		if !ok {
+			sendNotFound(p, "tx", payload.ID)
+			return nil
+		}
 
-		SendTx(payload.AddrFrom, &tx)
+		sendTx(p, &tx)
 		// delete(mempool, txID)
 	}
+
+	return nil
 }
 
-func handleTx(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
+func handleTx(p *Peer, request []byte, bc *Blockchain) error {
 	var payload tx
-
-	fmt.Printf("Incomming Tranaction [%s]\n", string(request))
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
-	if err != nil {
-		log.Panic(err)
+	if err := gobDecode(request, &payload); err != nil {
+		return err
 	}
 
-	txData := payload.Transaction
-	tx := DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
-
-	if len(KnownNodes) > 0 {
-		if nodeAddress == KnownNodes[0] {
-			for _, node := range KnownNodes {
-				if node != nodeAddress && node != payload.AddFrom {
-					sendInv(node, "tx", [][]byte{tx.ID})
-				}
-			}
-		} else {
-			if len(mempool) >= 2 && len(miningAddress) > 0 {
-			MineTransactions:
-				var txs []*Transaction
-
-				for id := range mempool {
-					tx := mempool[id]
-					if bc.VerifyTransaction(&tx) {
-						txs = append(txs, &tx)
-					}
-				}
-
-				if len(txs) == 0 {
-					fmt.Println("All transactions are invalid! Waiting for new ones...")
-					return
-				}
-
-				cbTx := NewCoinbaseTX(miningAddress, "")
-				txs = append(txs, cbTx)
-
-				newBlock := bc.MineBlock(txs)
-				UTXOSet := UTXOSet{bc}
-				UTXOSet.Reindex()
-
-				fmt.Println("New block is mined!")
-
-				for _, tx := range txs {
-					txID := hex.EncodeToString(tx.ID)
-					delete(mempool, txID)
-				}
-
-				for _, node := range KnownNodes {
-					if node != nodeAddress {
-						sendInv(node, "block", [][]byte{newBlock.Hash})
-					}
-				}
-
-				if len(mempool) > 0 {
-					goto MineTransactions
-				}
-			}
-		}
+	var tx Transaction
+	if err := gobDecode(payload.Transaction, &tx); err != nil {
+		return err
 	}
-}
-
-func handleVersion(request []byte, bc *Blockchain) {
-	var buff bytes.Buffer
-	var payload verzion
+	fmt.Printf("Incomming Tranaction [%x]\n", tx.ID)
 
-	buff.Write(request[commandLength:])
-	dec := gob.NewDecoder(&buff)
-	err := dec.Decode(&payload)
+	err := mempool.Add(&tx, &UTXOSet{bc})
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("Rejected transaction %x: %s\n", tx.ID, err)
+		return nil
 	}
 
-	myBestHeight := bc.GetBestHeight()
-	foreignerBestHeight := payload.BestHeight
+	relayInv("tx", [][]byte{tx.ID}, p)
 
-	if myBestHeight < foreignerBestHeight {
-		sendGetBlocks(payload.AddrFrom)
-	} else if myBestHeight > foreignerBestHeight {
-		sendVersion(payload.AddrFrom, bc)
-	}
+	notifyMiner()
 
-	// sendAddr(payload.AddrFrom)
-	if !nodeIsKnown(payload.AddrFrom) {
-		KnownNodes = append(KnownNodes, payload.AddrFrom)
-	}
+	return nil
 }
 
-func handleConnection(conn net.Conn, bc *Blockchain) {
-	request, err := ioutil.ReadAll(conn)
-	if err != nil {
-		log.Panic(err)
-	}
-	command := bytesToCommand(request[:commandLength])
-	fmt.Printf("Received %s command\n", command)
-
-	switch command {
+// handleMessage passes the payload of a message from a peer to the handler of its command
+func handleMessage(p *Peer, msg *Message, bc *Blockchain) error {
+	switch msg.Command {
 	case "addr":
-		handleAddr(request)
+		return handleAddr(p, msg.Payload)
+	case "getaddr":
+		return handleGetAddr(p, msg.Payload)
 	case "block":
-		handleBlock(request, bc)
+		return handleBlock(p, msg.Payload)
 	case "inv":
-		handleInv(request, bc)
-	case "getblocks":
-		handleGetBlocks(request, bc)
+		return handleInv(p, msg.Payload, bc)
+	case "notfound":
+		return handleNotFound(p, msg.Payload)
+	case "getheaders":
+		return handleGetHeaders(p, msg.Payload, bc)
+	case "headers":
+		return handleHeaders(p, msg.Payload)
 	case "getdata":
-		handleGetData(request, bc)
+		return handleGetData(p, msg.Payload, bc)
 	case "tx":
-		handleTx(request, bc)
-	case "version":
-		handleVersion(request, bc)
+		return handleTx(p, msg.Payload, bc)
 	default:
 		fmt.Println("Unknown command!")
 	}
 
-	conn.Close()
+	return nil
 }
 
-// StartServer starts a node
-func StartServer(nodeID, minerAddress string) {
+// StartServer starts a node. It keeps peer connections as set by
+// connConfig. With a miner address in minerConfig, the node mines in the
+// background. When rpcConfig has a listen address, external miners and
+// wallet commands can reach the node on that address.
+func StartServer(nodeID string, minerConfig MinerConfig, connConfig ConnConfig, rpcConfig RPCConfig) {
 	fmt.Printf("Starting node server...\n")
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
-	miningAddress = minerAddress
+	miningAddress = minerConfig.Address
 	ln, err := net.Listen(protocol, nodeAddress)
 	if err != nil {
 		log.Panic(err)
@@ -442,22 +399,107 @@ func StartServer(nodeID, minerAddress string) {
 
 	bc := NewBlockchain(nodeID)
 
-	if len(KnownNodes) > 0 {
-		if nodeAddress != KnownNodes[0] {
-			sendVersion(KnownNodes[0], bc)
+	loaded, dropped, err := mempool.LoadFromFile(nodeID, &UTXOSet{bc})
+	if err != nil {
+		fmt.Printf("Could not load the mempool: %s\n", err)
+	} else if loaded+dropped > 0 {
+		fmt.Printf("Loaded %d mempool transaction(s), dropped %d no longer valid\n", loaded, dropped)
+	}
+
+	syncManager = NewSyncManager(bc)
+	syncManager.Start()
+
+	addrManager = NewAddrManager(GetPeersFile(nodeID))
+	err = addrManager.Load()
+	if err != nil {
+		fmt.Printf("Could not load the known peers: %s\n", err)
+	}
+	if addrManager.Count() == 0 {
+		seeds := []netAddress{}
+		for _, seed := range ActiveParams.Seeds {
+			seeds = append(seeds, netAddress{seed, SFNodeNetwork, time.Now().Unix()})
+		}
+		addrManager.AddAddresses(seeds, "")
+	} else {
+		fmt.Printf("Loaded %d known peer(s)\n", addrManager.Count())
+	}
+	go saveOnExit(nodeID, bc)
+
+	go func() {
+		for range time.Tick(mempoolExpireInterval) {
+			mempool.Expire()
+			err := mempool.SaveToFile(nodeID)
+			if err != nil {
+				fmt.Printf("Could not save the mempool: %s\n", err)
+			}
+		}
+	}()
+	go func() {
+		for range time.Tick(addrSaveInterval) {
+			err := addrManager.Save()
+			if err != nil {
+				fmt.Printf("Could not save the known peers: %s\n", err)
+			}
 		}
+	}()
+
+	if rpcConfig.Listen != "" {
+		go StartRPCServer(rpcConfig, nodeID, bc)
 	}
+	if miningAddress != "" {
+		miner = NewMiner(bc, minerConfig)
+		miner.Start()
+	}
+
+	localServices = SFNodeNetwork | SFNodeRelay
+	connManager = NewConnManager(bc, connConfig)
+	connManager.Start()
 
+	fmt.Printf("Server listening for peers...\n")
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
 			log.Panic(err)
 		}
-		fmt.Printf("Server listening for commands...\n")
-		go handleConnection(conn, bc)
+		connManager.HandleInbound(conn)
+	}
+}
+
+// notifyMiner restarts the background miner, if any, on a new tip or new transactions
+func notifyMiner() {
+	if miner != nil {
+		miner.Notify()
 	}
 }
 
+// saveOnExit waits for the node to be interrupted or terminated, then saves
+// the mempool and the known peers so that unconfirmed transactions survive
+// the restart and the node finds its peers again
+func saveOnExit(nodeID string, bc *Blockchain) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	if miner != nil {
+		miner.Stop()
+	}
+	fmt.Printf("Saving %d mempool transaction(s)...\n", mempool.Count())
+	err := mempool.SaveToFile(nodeID)
+	if err != nil {
+		fmt.Printf("Could not save the mempool: %s\n", err)
+	}
+	fmt.Printf("Saving %d known peer(s)...\n", addrManager.Count())
+	err = addrManager.Save()
+	if err != nil {
+		fmt.Printf("Could not save the known peers: %s\n", err)
+	}
+
+	// Let a block being connected finish first
+	submitMu.Lock()
+	bc.DB.Close()
+	os.Exit(0)
+}
+
 func gobEncode(data interface{}) []byte {
 	var buff bytes.Buffer
 
@@ -470,16 +512,6 @@ func gobEncode(data interface{}) []byte {
 	return buff.Bytes()
 }
 
-func nodeIsKnown(addr string) bool {
-	fmt.Printf("Checking to see if %s is a KnowNode...", addr)
-	if len(KnownNodes) > 0 {
-		for _, node := range KnownNodes {
-			if node == addr {
-				fmt.Printf("Yes\n")
-				return true
-			}
-		}
-	}
-	fmt.Printf("No\n")
-	return false
+func gobDecode(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
 }
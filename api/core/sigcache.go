@@ -0,0 +1,92 @@
+package core
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// defaultSigCacheSize is the number of verified signatures remembered
+const defaultSigCacheSize = 50000
+
+// sigCache remembers signatures verified at mempool entry so that they are not
+// verified again when the block containing them is validated
+var sigCache = NewSigCache(defaultSigCacheSize)
+
+// SigCache is a fixed size LRU set of signatures that verified successfully.
+// It is safe for concurrent use.
+type SigCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+// NewSigCache creates a SigCache holding at most size signatures
+func NewSigCache(size int) *SigCache {
+	return &SigCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// sigCacheKey identifies a (sighash, pubkey, sig) triple. Every part is
+// length-prefixed so that different triples never share a key.
+func sigCacheKey(scheme SigScheme, sighash, pubKey, sig []byte) [sha256.Size]byte {
+	data := []byte{byte(scheme)}
+	for _, part := range [][]byte{sighash, pubKey, sig} {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(part)))
+		data = append(append(data, length[:]...), part...)
+	}
+
+	return sha256.Sum256(data)
+}
+
+// Contains reports whether the signature was verified before and marks it
+// as recently used
+func (c *SigCache) Contains(key [sha256.Size]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+
+	return ok
+}
+
+// Add records a verified signature, evicting the least recently used one
+// when the cache is full
+func (c *SigCache) Add(key [sha256.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.([sha256.Size]byte))
+	}
+
+	c.entries[key] = c.order.PushFront(key)
+}
+
+// Len returns the number of cached signatures
+func (c *SigCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
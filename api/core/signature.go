@@ -0,0 +1,261 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SigScheme identifies the signature scheme of a key, address or input
+type SigScheme byte
+
+const (
+	// SchemeP256Legacy is the original P-256 ECDSA scheme, whose signatures
+	// covered a constant prefix of the printed transaction and could be
+	// replayed on any other. Its keys and addresses are still known, but
+	// inputs of the scheme are refused: legacy wallets sign with SchemeP256.
+	SchemeP256Legacy SigScheme = 0x00
+	// SchemeP256 is P-256 ECDSA with fixed-width keys and signatures
+	SchemeP256 SigScheme = 0x01
+	// SchemeEd25519 is Ed25519
+	SchemeEd25519 SigScheme = 0x02
+)
+
+// DefaultScheme is used for new wallets when no scheme is chosen
+const DefaultScheme = SchemeP256
+
+// SignatureScheme signs and verifies messages with keys of one kind
+type SignatureScheme interface {
+	// Name returns the name used to select the scheme
+	Name() string
+	// NewWallet generates a wallet with a fresh key pair
+	NewWallet() (*Wallet, error)
+	// WalletFromKey creates the wallet of a 32 byte private key or seed
+	WalletFromKey(key []byte) (*Wallet, error)
+	// Sign signs msg with the private key of a wallet
+	Sign(w *Wallet, msg []byte) ([]byte, error)
+	// Verify checks sig over msg with an encoded public key
+	Verify(pubKey, msg, sig []byte) bool
+}
+
+var schemes = map[SigScheme]SignatureScheme{
+	SchemeP256Legacy: p256Legacy{},
+	SchemeP256:       p256{},
+	SchemeEd25519:    ed25519Scheme{},
+}
+
+// GetScheme returns the implementation of a signature scheme
+func GetScheme(id SigScheme) (SignatureScheme, error) {
+	scheme, ok := schemes[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signature scheme %d", id)
+	}
+
+	return scheme, nil
+}
+
+// ParseScheme finds a signature scheme new keys can be created with by name
+func ParseScheme(name string) (SigScheme, error) {
+	if name == "" {
+		return DefaultScheme, nil
+	}
+
+	for id, scheme := range schemes {
+		if id != SchemeP256Legacy && strings.EqualFold(scheme.Name(), name) {
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown signature scheme %q (use p256 or ed25519)", name)
+}
+
+// String returns the name of the scheme
+func (s SigScheme) String() string {
+	scheme, err := GetScheme(s)
+	if err != nil {
+		return fmt.Sprintf("unknown(%d)", byte(s))
+	}
+
+	return scheme.Name()
+}
+
+const p256FieldLen = 32
+
+type p256Legacy struct{}
+
+func (p256Legacy) Name() string {
+	return "p256-legacy"
+}
+
+func (p256Legacy) NewWallet() (*Wallet, error) {
+	return nil, errors.New("Legacy P-256 keys can no longer be created")
+}
+
+// WalletFromKey restores a legacy wallet, whose public key is X||Y without
+// leading zero bytes, so that its address stays the same
+func (p256Legacy) WalletFromKey(key []byte) (*Wallet, error) {
+	wallet, err := p256{}.WalletFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	public := wallet.PrivateKey.PublicKey
+	wallet.PublicKey = append(public.X.Bytes(), public.Y.Bytes()...)
+	wallet.Scheme = SchemeP256Legacy
+	wallet.Format = FormatBase58
+
+	return wallet, nil
+}
+
+// Sign lets wallets created before schemes existed keep spending their
+// outputs. They sign like SchemeP256, see Wallet.InputScheme.
+func (p256Legacy) Sign(w *Wallet, msg []byte) ([]byte, error) {
+	return p256{}.Sign(w, msg)
+}
+
+// Verify refuses every signature, see SchemeP256Legacy
+func (p256Legacy) Verify(pubKey, msg, sig []byte) bool {
+	return false
+}
+
+type p256 struct{}
+
+func (p256) Name() string {
+	return "p256"
+}
+
+func (p256) NewWallet() (*Wallet, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{*private, encodeP256PubKey(&private.PublicKey), SchemeP256, nil, "", DefaultAddressFormat}, nil
+}
+
+func (p256) WalletFromKey(key []byte) (*Wallet, error) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(key)
+	if len(key) != p256FieldLen || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("Invalid P-256 private key")
+	}
+
+	private := ecdsa.PrivateKey{D: d}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(key)
+
+	return &Wallet{private, encodeP256PubKey(&private.PublicKey), SchemeP256, nil, "", DefaultAddressFormat}, nil
+}
+
+// Sign produces a fixed-width 64 byte r||s signature
+func (p256) Sign(w *Wallet, msg []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, &w.PrivateKey, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 2*p256FieldLen)
+	r.FillBytes(sig[:p256FieldLen])
+	s.FillBytes(sig[p256FieldLen:])
+
+	return sig, nil
+}
+
+// Verify accepts fixed-width r||s or DER encoded signatures. Public keys of
+// legacy wallets may be shorter than 64 bytes.
+func (p256) Verify(pubKey, msg, sig []byte) bool {
+	key := decodeP256PubKey(pubKey)
+	if key == nil {
+		return false
+	}
+
+	if len(sig) == 2*p256FieldLen {
+		r := new(big.Int).SetBytes(sig[:p256FieldLen])
+		s := new(big.Int).SetBytes(sig[p256FieldLen:])
+		return ecdsa.Verify(key, msg, r, s)
+	}
+
+	return ecdsa.VerifyASN1(key, msg, sig)
+}
+
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) Name() string {
+	return "ed25519"
+}
+
+func (ed25519Scheme) NewWallet() (*Wallet, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{ecdsa.PrivateKey{}, public, SchemeEd25519, private, "", DefaultAddressFormat}, nil
+}
+
+func (ed25519Scheme) WalletFromKey(key []byte) (*Wallet, error) {
+	if len(key) != ed25519.SeedSize {
+		return nil, errors.New("Invalid Ed25519 seed")
+	}
+
+	private := ed25519.NewKeyFromSeed(key)
+	public := private.Public().(ed25519.PublicKey)
+
+	return &Wallet{ecdsa.PrivateKey{}, public, SchemeEd25519, private, "", DefaultAddressFormat}, nil
+}
+
+func (ed25519Scheme) Sign(w *Wallet, msg []byte) ([]byte, error) {
+	if len(w.SecretKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("Wallet has no Ed25519 private key")
+	}
+
+	return ed25519.Sign(ed25519.PrivateKey(w.SecretKey), msg), nil
+}
+
+func (ed25519Scheme) Verify(pubKey, msg, sig []byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(pubKey), msg, sig)
+}
+
+// encodeP256PubKey encodes a public key as fixed-width X||Y
+func encodeP256PubKey(key *ecdsa.PublicKey) []byte {
+	pubKey := make([]byte, 2*p256FieldLen)
+	key.X.FillBytes(pubKey[:p256FieldLen])
+	key.Y.FillBytes(pubKey[p256FieldLen:])
+
+	return pubKey
+}
+
+// p256MaxDroppedBytes is the number of leading zero bytes the coordinates
+// of the public key of a legacy wallet may have lost together
+const p256MaxDroppedBytes = 2
+
+// decodeP256PubKey decodes X||Y. Keys shorter than 64 bytes come from old
+// wallets that dropped leading zero bytes, so the few splits they can have
+// are tried until one gives a point on the curve.
+func decodeP256PubKey(pubKey []byte) *ecdsa.PublicKey {
+	curve := elliptic.P256()
+
+	dropped := 2*p256FieldLen - len(pubKey)
+	if dropped < 0 || dropped > p256MaxDroppedBytes {
+		return nil
+	}
+
+	for split := p256FieldLen - dropped; split <= p256FieldLen; split++ {
+		x := new(big.Int).SetBytes(pubKey[:split])
+		y := new(big.Int).SetBytes(pubKey[split:])
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	return nil
+}
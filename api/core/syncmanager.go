@@ -0,0 +1,411 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limits of the headers-first sync. Headers are fetched in batches of
+// maxHeadersPerMsg from the peers announcing a higher chain. The blocks of
+// the best header chain are then downloaded from every peer having them,
+// with at most maxBlocksInFlight requests per peer, among the
+// blockDownloadWindow lowest missing blocks so that blocks arriving out of
+// order are held briefly.
+const (
+	maxHeadersPerMsg     = 2000
+	maxBlocksInFlight    = 16
+	blockDownloadWindow  = 1024
+	blockDownloadTimeout = 30 * time.Second
+	maxBlockStalls       = 3 // requests timed out or not found before the peer is dropped
+	syncTickInterval     = 5 * time.Second
+)
+
+// ErrPeerStalled is the reason a peer that does not deliver the blocks it announced is dropped
+var ErrPeerStalled = errors.New("Peer does not deliver the blocks it announced")
+
+// syncPeer is what the sync manager knows of a peer
+type syncPeer struct {
+	bestKnown *blockNode // highest block the peer is known to have
+	inFlight  int        // blocks requested and not received yet
+	stalls    int        // requests timed out or not found
+}
+
+// blockRequest is a block requested from a peer
+type blockRequest struct {
+	peer *Peer
+	node *blockNode
+	time time.Time
+}
+
+// SyncManager downloads the blockchain from the peers of the node: headers
+// first, to find the best chain, then the blocks of that chain in parallel
+type SyncManager struct {
+	bc    *Blockchain
+	index *blockIndex
+
+	mu        sync.Mutex
+	peers     map[*Peer]*syncPeer
+	requested map[string]*blockRequest // by block hash
+	waiting   map[string]*Block        // downloaded blocks by the hash of their missing parent
+}
+
+// syncManager is the sync manager of the running node
+var syncManager *SyncManager
+
+// NewSyncManager creates a sync manager for the blockchain, indexing the
+// blocks it stores. It does nothing until started.
+func NewSyncManager(bc *Blockchain) *SyncManager {
+	return &SyncManager{
+		bc:        bc,
+		index:     newBlockIndex(bc),
+		peers:     make(map[*Peer]*syncPeer),
+		requested: make(map[string]*blockRequest),
+		waiting:   make(map[string]*Block),
+	}
+}
+
+// Start checks the block requests every syncTickInterval in the background,
+// requesting again from other peers the blocks that timed out
+func (sm *SyncManager) Start() {
+	go func() {
+		for range time.Tick(syncTickInterval) {
+			sm.mu.Lock()
+			sm.checkTimeouts()
+			sm.schedule()
+			sm.mu.Unlock()
+		}
+	}()
+}
+
+// PeerConnected starts syncing from a peer serving the chain, asking it for
+// headers when it announced a higher chain
+func (sm *SyncManager) PeerConnected(p *Peer) {
+	if p.Services&SFNodeNetwork == 0 {
+		return
+	}
+
+	sm.mu.Lock()
+	sm.peers[p] = &syncPeer{}
+	sm.mu.Unlock()
+
+	best := sm.index.best()
+	if p.BestHeight > best.header.Height {
+		sendGetHeaders(p, sm.index.locator(best), nil)
+	}
+}
+
+// PeerDisconnected forgets a peer and requests its blocks in flight from others
+func (sm *SyncManager) PeerDisconnected(p *Peer) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for key, req := range sm.requested {
+		if req.peer == p {
+			delete(sm.requested, key)
+		}
+	}
+	delete(sm.peers, p)
+	sm.schedule()
+}
+
+// handleHeaders adds the headers sent by a peer to the index, asks for the
+// next batch when the message was full and downloads the new blocks
+func (sm *SyncManager) handleHeaders(p *Peer, headers []BlockHeader) error {
+	if len(headers) > maxHeadersPerMsg {
+		return fmt.Errorf("Too many headers: %d", len(headers))
+	}
+
+	var last *blockNode
+	for i := range headers {
+		node, err := sm.index.addHeader(&headers[i])
+		if err != nil {
+			return err
+		}
+		last = node
+	}
+	if last == nil {
+		return nil
+	}
+	fmt.Printf("Received %d header(s) up to %d, best header is %d\n", len(headers), last.header.Height, sm.index.best().header.Height)
+
+	if len(headers) == maxHeadersPerMsg {
+		sendGetHeaders(p, sm.index.locator(last), nil)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.updateBestKnown(p, last)
+	sm.schedule()
+
+	return nil
+}
+
+// handleInv records the blocks a peer announces, asking it for the headers
+// of those that are unknown
+func (sm *SyncManager) handleInv(p *Peer, hashes [][]byte) {
+	unknown := false
+
+	sm.mu.Lock()
+	for _, hash := range hashes {
+		node := sm.index.lookup(hash)
+		if node == nil {
+			unknown = true
+			continue
+		}
+		sm.updateBestKnown(p, node)
+	}
+	sm.schedule()
+	sm.mu.Unlock()
+
+	if unknown {
+		sendGetHeaders(p, sm.index.locator(sm.index.best()), nil)
+	}
+}
+
+// handleNotFound cancels the requests of blocks a peer does not have
+func (sm *SyncManager) handleNotFound(p *Peer, hashes [][]byte) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, hash := range hashes {
+		if req, ok := sm.requested[hex.EncodeToString(hash)]; ok && req.peer == p {
+			sm.cancel(hex.EncodeToString(hash), req)
+		}
+	}
+	sm.schedule()
+}
+
+// handleBlock checks a block received from a peer against its header and
+// connects it, along with the downloaded blocks waiting for it. A block
+// whose parent is not stored yet waits for it. A peer sending a block that
+// does not match its header or is invalid is disconnected.
+func (sm *SyncManager) handleBlock(p *Peer, block *Block) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	key := hex.EncodeToString(block.Hash)
+	req, requested := sm.requested[key]
+	if requested {
+		delete(sm.requested, key)
+		if sp, ok := sm.peers[req.peer]; ok {
+			sp.inFlight--
+		}
+	}
+
+	// The hash commits to the transactions, so that a peer cannot send
+	// others under the hash of a known header
+	header := block.Header()
+	if !ValidateHeader(header) {
+		p.disconnect(ErrHeaderProof)
+		return ErrHeaderProof
+	}
+	node, err := sm.index.addHeader(header)
+	if err == ErrHeaderOrphan {
+		// A new block on a chain whose headers are not known yet
+		sendGetHeaders(p, sm.index.locator(sm.index.best()), nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(node.header.Hash, header.Hash) || !bytes.Equal(node.header.MerkleRoot, header.MerkleRoot) {
+		p.disconnect(ErrBlockMismatch)
+		return ErrBlockMismatch
+	}
+	if node.haveData {
+		return nil
+	}
+	if err := checkBlock(block); err != nil {
+		p.disconnect(err)
+		return err
+	}
+	sm.updateBestKnown(p, node)
+
+	received := block
+	for block != nil {
+		if parent := sm.index.lookup(block.PrevBlockHash); parent == nil || !parent.haveData {
+			// Only requested blocks, which are in the download window, wait
+			if requested {
+				sm.waiting[hex.EncodeToString(block.PrevBlockHash)] = block
+			}
+			break
+		}
+		if err := sm.connect(block, p); err != nil {
+			sm.dropWaiting(block.Hash)
+			if block == received {
+				p.disconnect(err)
+				return err
+			}
+			fmt.Printf("Refused block %d %x: %s\n", block.Height, block.Hash, err)
+			break
+		}
+
+		key := hex.EncodeToString(block.Hash)
+		block = sm.waiting[key]
+		delete(sm.waiting, key)
+	}
+	sm.schedule()
+
+	return nil
+}
+
+// dropWaiting forgets the downloaded blocks descending from a block that
+// cannot be connected
+func (sm *SyncManager) dropWaiting(hash []byte) {
+	for {
+		key := hex.EncodeToString(hash)
+		block, ok := sm.waiting[key]
+		if !ok {
+			return
+		}
+		delete(sm.waiting, key)
+		hash = block.Hash
+	}
+}
+
+// connect stores a block whose parent is stored and relays it once the node
+// is synced. A block building on the tip is first validated like a submitted
+// block. A block making another branch the longest one switches the chain to
+// it: the blocks of the branch are validated while they are connected, the
+// switch is refused when one of them is invalid, and the transactions of the
+// blocks left are offered to the mempool again. Blocks of shorter branches
+// only get the checks of their headers and structure until then.
+func (sm *SyncManager) connect(block *Block, source *Peer) error {
+	submitMu.Lock()
+	prevTip, prevHeight := sm.bc.tip()
+	UTXOSet := UTXOSet{sm.bc}
+	var detached, attached []*Block
+	switch {
+	case bytes.Equal(block.PrevBlockHash, prevTip):
+		if err := sm.bc.checkTransactions(block); err != nil {
+			submitMu.Unlock()
+			return err
+		}
+		sm.bc.AddBlock(block)
+		UTXOSet.Update(block)
+		attached = []*Block{block}
+	case block.Height > prevHeight:
+		sm.bc.putBlock(block)
+		fmt.Printf("Switching to the chain of block %x\n", block.Hash)
+		var err error
+		if detached, attached, err = sm.bc.reorganize(block); err != nil {
+			submitMu.Unlock()
+			return err
+		}
+	default:
+		sm.bc.putBlock(block)
+	}
+	sm.index.addBlock(block)
+
+	newTip := len(attached) > 0
+	for _, b := range attached {
+		mempool.RemoveConfirmed(b)
+	}
+	for _, b := range detached {
+		for _, tx := range b.Transactions[1:] {
+			mempool.Add(tx, &UTXOSet)
+		}
+	}
+	submitMu.Unlock()
+
+	fmt.Printf("Added block %d %x\n", block.Height, block.Hash)
+	if newTip {
+		notifyMiner()
+		if sm.index.best().header.Height == block.Height {
+			relayInv("block", [][]byte{block.Hash}, source)
+		}
+	}
+
+	return nil
+}
+
+// schedule requests the missing blocks of the best header chain from the
+// peers having them, up to maxBlocksInFlight per peer
+func (sm *SyncManager) schedule() {
+	window := sm.index.missing(blockDownloadWindow)
+	if len(window) == 0 {
+		return
+	}
+	inWindow := make(map[*blockNode]bool)
+	for _, node := range window {
+		inWindow[node] = true
+	}
+	bottom, top := window[0].header.Height, window[len(window)-1].header.Height
+
+	for p, sp := range sm.peers {
+		if sp.bestKnown == nil || sp.inFlight >= maxBlocksInFlight {
+			continue
+		}
+
+		// Highest block of the window the peer has
+		has := sp.bestKnown.ancestor(top)
+		for has != nil && has.header.Height >= bottom && !inWindow[has] {
+			has = has.parent
+		}
+		if has == nil || !inWindow[has] {
+			continue
+		}
+
+		for _, node := range window {
+			if node.header.Height > has.header.Height || sp.inFlight >= maxBlocksInFlight {
+				break
+			}
+			key := hex.EncodeToString(node.header.Hash)
+			if _, ok := sm.requested[key]; ok || sm.isWaiting(node) {
+				continue
+			}
+
+			sm.requested[key] = &blockRequest{p, node, time.Now()}
+			sp.inFlight++
+			sendGetData(p, "block", node.header.Hash)
+		}
+	}
+}
+
+// checkTimeouts cancels the requests not answered within
+// blockDownloadTimeout so that they are sent to other peers
+func (sm *SyncManager) checkTimeouts() {
+	now := time.Now()
+	for key, req := range sm.requested {
+		if now.Sub(req.time) > blockDownloadTimeout {
+			fmt.Printf("Block %d %s from %s timed out\n", req.node.header.Height, key, req.peer)
+			sm.cancel(key, req)
+		}
+	}
+}
+
+// cancel forgets a request the peer did not answer, dropping peers that
+// stall too often
+func (sm *SyncManager) cancel(key string, req *blockRequest) {
+	delete(sm.requested, key)
+
+	sp, ok := sm.peers[req.peer]
+	if !ok {
+		return
+	}
+	sp.inFlight--
+	sp.stalls++
+	if sp.stalls >= maxBlockStalls {
+		req.peer.disconnect(ErrPeerStalled)
+	}
+}
+
+// updateBestKnown records that a peer has node and its ancestors
+func (sm *SyncManager) updateBestKnown(p *Peer, node *blockNode) {
+	sp, ok := sm.peers[p]
+	if ok && (sp.bestKnown == nil || node.header.Height > sp.bestKnown.header.Height) {
+		sp.bestKnown = node
+	}
+}
+
+// isWaiting tells whether the block of node is downloaded and waits for its parent
+func (sm *SyncManager) isWaiting(node *blockNode) bool {
+	block, ok := sm.waiting[hex.EncodeToString(node.header.PrevBlockHash)]
+
+	return ok && bytes.Equal(block.Hash, node.header.Hash)
+}
@@ -2,30 +2,27 @@ package core
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"math/big"
 	"strings"
 
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"log"
-)
 
-// Initial amount of coins to address (10 Million)
-const totalSupply = 15000000
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+)
 
-// How much miner makes for mining the block
-const subsidy = 1
+// Initial amount of coins to address (15 Million)
+const totalSupplyCoins = 15000000
 
 // Transaction represents a Bitcoin transaction
 type Transaction struct {
-	ID   []byte
-	Vin  []TXInput
-	Vout []TXOutput
+	ID       []byte
+	Vin      []TXInput
+	Vout     []TXOutput
+	Issuance *AssetIssuance
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -33,6 +30,17 @@ func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
+// IsReplaceable checks whether the transaction opted in to replace-by-fee
+func (tx Transaction) IsReplaceable() bool {
+	for _, vin := range tx.Vin {
+		if vin.Sequence == SequenceReplaceable {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Serialize returns a serialized Transaction
 func (tx Transaction) Serialize() []byte {
 	var encoded bytes.Buffer
@@ -58,8 +66,8 @@ func (tx *Transaction) Hash() []byte {
 	return hash[:]
 }
 
-// Sign signs each input of a Transaction
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// Sign signs each input of a Transaction with the wallet's key
+func (tx *Transaction) Sign(wallet *Wallet, prevTXs map[string]Transaction) {
 	if tx.IsCoinbase() {
 		return
 	}
@@ -70,32 +78,41 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inID, vin := range txCopy.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
-
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
+	for inID := range tx.Vin {
+		signature, err := wallet.Sign(tx.SigHash(inID, prevTXs))
 		if err != nil {
 			log.Panic(err)
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
 
 		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil
 	}
 }
 
+// SigHash returns the message signed by an input: the trimmed transaction
+// with the input's PubKey replaced by the public key hash it spends
+func (tx *Transaction) SigHash(inID int, prevTXs map[string]Transaction) []byte {
+	vin := tx.Vin[inID]
+	prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+
+	hash := sha256.Sum256(txCopy.Serialize())
+
+	return hash[:]
+}
+
 // String returns a human-readable representation of a transaction
 func (tx Transaction) String() string {
 	var lines []string
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
 
+	if tx.Issuance != nil {
+		supply := FormatAmount(tx.Issuance.Supply, tx.Issuance.Decimals, tx.Issuance.Symbol)
+		lines = append(lines, fmt.Sprintf("		Issues %s (%x), %d decimals", supply, tx.Issuance.ID, tx.Issuance.Decimals))
+	}
+
 	for i, input := range tx.Vin {
 
 		lines = append(lines, fmt.Sprintf("		Input %d:", i))
@@ -107,7 +124,16 @@ func (tx Transaction) String() string {
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("		Output %d:", i))
-		lines = append(lines, fmt.Sprintf("		Value:  %d", output.Value))
+		if output.IsData() {
+			lines = append(lines, fmt.Sprintf("		Data:   %x", output.Data))
+			continue
+		}
+		if output.Asset != nil {
+			lines = append(lines, fmt.Sprintf("		Value:  %d units", uint64(output.Value)))
+			lines = append(lines, fmt.Sprintf("		Asset:  %x", output.Asset))
+		} else {
+			lines = append(lines, fmt.Sprintf("		Value:  %s", output.Value))
+		}
 		lines = append(lines, fmt.Sprintf("		Script: %x", output.PubKeyHash))
 	}
 
@@ -120,88 +146,116 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil, vin.Scheme, vin.Sequence})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TXOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, TXOutput{vout.Value, vout.Asset, vout.PubKeyHash, vout.Data})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.Issuance}
 
 	return txCopy
 }
 
+// checkDataOutputs makes sure data outputs are well formed and that no input
+// tries to spend one
+func (tx *Transaction) checkDataOutputs(prevTXs map[string]Transaction) bool {
+	for _, out := range tx.Vout {
+		if out.IsData() && (out.Value != 0 || len(out.Data) > maxDataOutputSize) {
+			return false
+		}
+	}
+
+	for _, vin := range tx.Vin {
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return false
+		}
+		if prevTx.Vout[vin.Vout].IsData() {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Verify verifies signatures of Transaction inputs
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	checks, ok := tx.sigChecks(prevTXs)
+	if !ok {
+		return false
+	}
+
+	return verifySigChecks(checks)
+}
+
+// sigChecks returns the signatures of the inputs that need verifying, or
+// false if the transaction is invalid without looking at them, such as when
+// it spends a transaction missing from prevTXs
+func (tx *Transaction) sigChecks(prevTXs map[string]Transaction) ([]sigCheck, bool) {
 	if tx.IsCoinbase() {
-		return true
+		return nil, true
 	}
 
 	for _, vin := range tx.Vin {
 		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+			return nil, false
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
+	if bytes.Compare(tx.ID, tx.unsignedHash()) != 0 {
+		return nil, false
+	}
 
+	var checks []sigCheck
 	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return nil, false
+		}
+		if vin.Scheme == SchemeP256Legacy || !vin.UsesKey(prevTx.Vout[vin.Vout].PubKeyHash) {
+			return nil, false
+		}
 
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
+		checks = append(checks, sigCheck{vin.Scheme, vin.PubKey, tx.SigHash(inID, prevTXs), vin.Signature})
+	}
 
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
+	return checks, true
+}
 
-		rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
-			return false
-		}
-		txCopy.Vin[inID].PubKey = nil
+// unsignedHash returns the hash of the transaction with its signatures
+// removed, which is what its ID commits to
+func (tx *Transaction) unsignedHash() []byte {
+	txCopy := *tx
+	txCopy.Vin = make([]TXInput, len(tx.Vin))
+	for i, vin := range tx.Vin {
+		vin.Signature = nil
+		txCopy.Vin[i] = vin
 	}
 
-	return true
+	return txCopy.Hash()
 }
 
-// NewBlockchainTX creates a new transaction to deposit total supply into the primary blockchain wallet
+// NewBlockchainTX creates a new transaction to deposit total supply into the primary blockchain wallet.
+// data is the text of its input, the genesis data of the network.
 func NewBlockchainTX(to, data string) *Transaction {
-	data = "Deposit Total Supply Coins into Primary Blockchain Wallet."
 	if data == "" {
-		randData := make([]byte, 20)
-		_, err := rand.Read(randData)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		//data = fmt.Sprintf("%x", randData)
-
+		data = "Deposit Total Supply Coins into Primary Blockchain Wallet."
 	}
 
 	//txin := TXInput{[]byte{}, -1, nil, []byte(data)}
-	txin := TXInput{[]byte{}, totalSupply, nil, []byte(data)}
-	txout := NewTXOutput(totalSupply, to)
-	//fmt.Printf("Deposited %d coins into wallet %s.\n", totalSupply, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	txin := TXInput{[]byte{}, totalSupplyCoins, nil, []byte(data), 0, SequenceFinal}
+	txout := NewTXOutput(ActiveParams.GenesisSupply, to)
+	//fmt.Printf("Deposited %d coins into wallet %s.\n", ActiveParams.GenesisSupply, to)
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}, nil}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-// NewCoinbaseTX creates a new coinbase transaction
-func NewCoinbaseTX(to, data string) *Transaction {
+// NewCoinbaseTX creates a new coinbase transaction paying the reward of the block at the given height
+func NewCoinbaseTX(to, data string, height int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -212,53 +266,103 @@ func NewCoinbaseTX(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTXOutput(subsidy, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	txin := TXInput{[]byte{}, -1, nil, []byte(data), 0, SequenceFinal}
+	txout := NewTXOutput(ActiveParams.BlockSubsidy(height), to)
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}, nil}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-// NewUTXOTransaction creates a new transaction
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+// NewUTXOTransaction creates a new transaction sending amount of an asset. A nil asset is the native coin.
+// Inputs are chosen by the coin selection strategy and the fee of feeRate base units per byte is paid
+// in native coins. Change goes to the change address, or back to the sender when it is empty. With a
+// mempool, unconfirmed outputs of the wallet may be spent. A replaceable transaction signals
+// replace-by-fee so that its fee can be bumped later.
+func NewUTXOTransaction(wallet *Wallet, to, change string, asset []byte, amount Amount, strategy coinselect.Strategy, feeRate Amount, replaceable bool, UTXOSet *UTXOSet, mempool *Mempool) *Transaction {
 	var inputs []TXInput
 	var outputs []TXOutput
 
-	pubKeyHash := HashPubKey(wallet.PublicKey)
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+	if amount == 0 {
+		log.Panic("ERROR: Amount must be positive")
+	}
 
-	if acc < amount {
-		log.Panic("ERROR: Not enough funds")
+	if change == "" {
+		change = fmt.Sprintf("%s", wallet.GetAddress())
+	}
+	outputs = append(outputs, *NewAssetTXOutput(amount, asset, to))
+
+	if asset != nil {
+		// Assets pay no fee, so their change is kept down to the last unit
+		params := coinselect.Params{Target: uint64(amount)}
+		assetInputs, selection := selectInputs(wallet, asset, strategy, params, UTXOSet, mempool)
+		inputs = append(inputs, assetInputs...)
+		if selection.Change > 0 {
+			outputs = append(outputs, *NewAssetTXOutput(Amount(selection.Change), asset, change)) // a change
+		}
 	}
 
-	// Build a list of inputs
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		if err != nil {
-			log.Panic(err)
+	if asset == nil || feeRate > 0 {
+		// Native coins pay the amount, if sending them, and the fee
+		params := selectParams(wallet, Transaction{nil, inputs, outputs, nil}, change, feeRate)
+		if asset == nil {
+			params.Target = uint64(amount)
+		}
+		coinInputs, selection := selectInputs(wallet, nil, strategy, params, UTXOSet, mempool)
+		inputs = append(inputs, coinInputs...)
+		if selection.Change > 0 {
+			outputs = append(outputs, *NewTXOutput(Amount(selection.Change), change)) // a change
 		}
+	}
 
-		for _, out := range outs {
-			input := TXInput{txID, out, nil, wallet.PublicKey}
-			inputs = append(inputs, input)
+	if replaceable {
+		for i := range inputs {
+			inputs[i].Sequence = SequenceReplaceable
 		}
 	}
 
-	// Build a list of outputs
+	tx := Transaction{nil, inputs, outputs, nil}
+	tx.ID = tx.Hash()
+	signTransaction(&tx, wallet, UTXOSet, mempool)
+
+	return &tx
+}
+
+// NewDataTransaction creates a transaction anchoring data in an unspendable
+// output. At least one of the wallet's outputs is spent to pay the fee and
+// sign the data, the rest comes back as change.
+func NewDataTransaction(wallet *Wallet, data []byte, UTXOSet *UTXOSet, mempool *Mempool) *Transaction {
+	if len(data) == 0 || len(data) > maxDataOutputSize {
+		log.Panicf("ERROR: Data must be between 1 and %d bytes", maxDataOutputSize)
+	}
+
 	from := fmt.Sprintf("%s", wallet.GetAddress())
-	outputs = append(outputs, *NewTXOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from)) // a change
+	outputs := []TXOutput{*NewDataOutput(data)}
+
+	params := selectParams(wallet, Transaction{nil, nil, outputs, nil}, from, DefaultFeeRate)
+	inputs, selection := selectInputs(wallet, nil, coinselect.LargestFirst, params, UTXOSet, mempool)
+	if selection.Change > 0 {
+		outputs = append(outputs, *NewTXOutput(Amount(selection.Change), from))
 	}
 
-	tx := Transaction{nil, inputs, outputs}
+	tx := Transaction{nil, inputs, outputs, nil}
 	tx.ID = tx.Hash()
-	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+	signTransaction(&tx, wallet, UTXOSet, mempool)
 
 	return &tx
 }
 
+// newInput creates an unsigned input of the wallet spending output out of
+// txid. Its transaction may not be replaced unless it is made replaceable.
+func newInput(wallet *Wallet, txid string, out int) TXInput {
+	txID, err := hex.DecodeString(txid)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return TXInput{txID, out, nil, wallet.PublicKey, wallet.InputScheme(), SequenceFinal}
+}
+
 // DeserializeTransaction deserializes a transaction
 func DeserializeTransaction(data []byte) Transaction {
 	var transaction Transaction
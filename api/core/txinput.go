@@ -2,11 +2,23 @@ package core
 
 import "bytes"
 
+// Input sequence numbers. Inputs created before sequence numbers existed
+// decode as SequenceFinal.
+const (
+	// SequenceFinal marks an input whose transaction may not be replaced
+	SequenceFinal uint32 = 0
+	// SequenceReplaceable opts the transaction in to replace-by-fee
+	SequenceReplaceable uint32 = 1
+)
+
+// TXInput represents a transaction input
 type TXInput struct {
 	Txid      []byte // Transaction ID
 	Vout      int    // TODO: float32
 	Signature []byte
 	PubKey    []byte
+	Scheme    SigScheme
+	Sequence  uint32
 }
 
 func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
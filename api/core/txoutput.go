@@ -4,39 +4,94 @@ import (
 	"bytes"
 	"encoding/gob"
 	"log"
-
-	"github.com/NlaakStudios/Blockchain/api/utils"
 )
 
 // TXOutput represents a transaction output
 type TXOutput struct {
-	Value      int
+	Value      Amount
+	Asset      []byte
 	PubKeyHash []byte
+	Data       []byte
 }
 
+// maxDataOutputSize is the largest payload a data output may carry
+const maxDataOutputSize = 80
+
 // Lock signs the output
 func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := utils.Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	_, pubKeyHash, err := DecodeAddress(string(address))
+	if err != nil {
+		log.Panic(err)
+	}
 	out.PubKeyHash = pubKeyHash
 }
 
+// IsData checks if the output is a provably-unspendable data output
+func (out *TXOutput) IsData() bool {
+	return len(out.Data) > 0
+}
+
+// IsAsset checks if the output carries the given asset. A nil asset is the native coin.
+func (out *TXOutput) IsAsset(asset []byte) bool {
+	return bytes.Equal(out.Asset, asset)
+}
+
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	if out.IsData() {
+		return false
+	}
 	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
 }
 
 // NewTXOutput create a new TXOutput
-func NewTXOutput(value int, address string) *TXOutput {
-	txo := &TXOutput{value, nil}
+func NewTXOutput(value Amount, address string) *TXOutput {
+	txo := &TXOutput{value, nil, nil, nil}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
-// TXOutputs collects TXOutput
+// NewAssetTXOutput create a new TXOutput carrying an issued asset
+func NewAssetTXOutput(value Amount, asset []byte, address string) *TXOutput {
+	txo := NewTXOutput(value, address)
+	txo.Asset = asset
+
+	return txo
+}
+
+// NewDataOutput creates an unspendable output carrying data. It has no value
+// and no owner, so it never enters the UTXO set.
+func NewDataOutput(data []byte) *TXOutput {
+	return &TXOutput{0, nil, nil, data}
+}
+
+// TXOutputs collects the unspent outputs of a transaction keyed by their index
 type TXOutputs struct {
-	Outputs []TXOutput
+	Outputs map[int]TXOutput
+}
+
+// NewTXOutputs creates an empty TXOutputs
+func NewTXOutputs() TXOutputs {
+	return TXOutputs{make(map[int]TXOutput)}
+}
+
+// transaction rebuilds the outputs part of the transaction they came from.
+// Spent outputs are left empty.
+func (outs TXOutputs) transaction(txID []byte) Transaction {
+	size := 0
+	for outIdx := range outs.Outputs {
+		if outIdx+1 > size {
+			size = outIdx + 1
+		}
+	}
+
+	tx := Transaction{txID, nil, make([]TXOutput, size), nil}
+	for outIdx, out := range outs.Outputs {
+		tx.Vout[outIdx] = out
+	}
+
+	return tx
 }
 
 // Serialize serializes TXOutputs
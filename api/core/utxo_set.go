@@ -1,23 +1,37 @@
 package core
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
+	"sort"
 
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
 	"github.com/boltdb/bolt"
 )
 
 const utxoBucket = "chainstate"
 
+// chainMetaBucket holds the format version of the UTXO set, under
+// chainstateVersionKey
+const chainMetaBucket = "meta"
+
+var chainstateVersionKey = []byte("chainstate")
+
+// chainstateVersion is the format of the UTXO set written by Reindex. A UTXO
+// set of another format is rebuilt when the blockchain is opened. Version 1
+// keys the unspent outputs of a transaction by their index.
+const chainstateVersion = 1
+
 // UTXOSet represents UTXO set
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs
-func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
+// FindSpendableOutputs finds and returns unspent outputs of an asset to reference in inputs
+func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, asset []byte, amount Amount) (Amount, map[string][]int, error) {
 	unspentOutputs := make(map[string][]int)
-	accumulated := 0
+	accumulated := Amount(0)
 	db := u.Blockchain.DB
 
 	err := db.View(func(tx *bolt.Tx) error {
@@ -29,8 +43,11 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 			outs := DeserializeOutputs(v)
 
 			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
-					accumulated += out.Value
+				if out.IsLockedWithKey(pubkeyHash) && out.IsAsset(asset) && accumulated < amount {
+					var err error
+					if accumulated, err = accumulated.Add(out.Value); err != nil {
+						return err
+					}
 					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
 				}
 			}
@@ -38,15 +55,108 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 
 		return nil
 	})
+	if err == ErrAmountOverflow {
+		return 0, nil, err
+	}
 	if err != nil {
 		log.Panic(err)
 	}
 
-	return accumulated, unspentOutputs
+	return accumulated, unspentOutputs, nil
+}
+
+// FindCoins lists the outputs of an asset a public key hash can spend as
+// coins for coin selection. With a mempool, outputs it spends are left out
+// and its unconfirmed outputs are added, unless their chain of unconfirmed
+// transactions cannot grow any longer.
+func (u UTXOSet) FindCoins(pubKeyHash []byte, asset []byte, mempool *Mempool) []coinselect.Coin {
+	coins := u.findConfirmedCoins(pubKeyHash, asset, mempool)
+	if mempool == nil {
+		return coins
+	}
+
+	for _, coin := range mempool.FindCoins(pubKeyHash, asset) {
+		txID, err := hex.DecodeString(coin.TxID)
+		if err != nil {
+			log.Panic(err)
+		}
+		if mempool.ChainLength(txID) < maxUnconfirmedChain {
+			coins = append(coins, coin)
+		}
+	}
+
+	return coins
 }
 
-// FindUTXO finds UTXO for a public key hash
-func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
+// Balance returns the confirmed balance of an asset for a public key hash,
+// and the pending one it will have once the transactions of the mempool are
+// confirmed. ErrAmountOverflow is returned when a balance does not fit in
+// an Amount.
+func (u UTXOSet) Balance(pubKeyHash []byte, asset []byte, mempool *Mempool) (Amount, Amount, error) {
+	var confirmed, pending Amount
+	var err error
+
+	for _, out := range u.FindUTXO(pubKeyHash, asset) {
+		if confirmed, err = confirmed.Add(out.Value); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	coins := u.findConfirmedCoins(pubKeyHash, asset, mempool)
+	if mempool != nil {
+		coins = append(coins, mempool.FindCoins(pubKeyHash, asset)...)
+	}
+	for _, coin := range coins {
+		if pending, err = pending.Add(Amount(coin.Value)); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return confirmed, pending, nil
+}
+
+// findConfirmedCoins lists the unspent outputs of an asset for a public key
+// hash not spent in the mempool, ordered by transaction ID and output index
+func (u UTXOSet) findConfirmedCoins(pubKeyHash []byte, asset []byte, mempool *Mempool) []coinselect.Coin {
+	var coins []coinselect.Coin
+	db := u.Blockchain.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txID := hex.EncodeToString(k)
+			outs := DeserializeOutputs(v)
+
+			var indexes []int
+			for outIdx := range outs.Outputs {
+				indexes = append(indexes, outIdx)
+			}
+			sort.Ints(indexes)
+
+			for _, outIdx := range indexes {
+				out := outs.Outputs[outIdx]
+				if mempool != nil && mempool.IsSpent(k, outIdx) {
+					continue
+				}
+				if out.IsLockedWithKey(pubKeyHash) && out.IsAsset(asset) {
+					coins = append(coins, coinselect.Coin{TxID: txID, Vout: outIdx, Value: uint64(out.Value)})
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return coins
+}
+
+// FindUTXO finds UTXO of an asset for a public key hash
+func (u UTXOSet) FindUTXO(pubKeyHash []byte, asset []byte) []TXOutput {
 	var UTXOs []TXOutput
 	db := u.Blockchain.DB
 
@@ -58,7 +168,7 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 			outs := DeserializeOutputs(v)
 
 			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
+				if out.IsLockedWithKey(pubKeyHash) && out.IsAsset(asset) {
 					UTXOs = append(UTXOs, out)
 				}
 			}
@@ -73,6 +183,29 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	return UTXOs
 }
 
+// FindOutputs returns the unspent outputs of a transaction
+func (u UTXOSet) FindOutputs(txID []byte) (TXOutputs, bool) {
+	var outs TXOutputs
+	found := false
+	db := u.Blockchain.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		outsBytes := b.Get(txID)
+		if outsBytes != nil {
+			outs = DeserializeOutputs(outsBytes)
+			found = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return outs, found
+}
+
 // CountTransactions returns the number of transactions in the UTXO set
 func (u UTXOSet) CountTransactions() int {
 	db := u.Blockchain.DB
@@ -136,6 +269,89 @@ func (u UTXOSet) Reindex() {
 
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	u.reindexAssets()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(chainMetaBucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(chainstateVersionKey, []byte{chainstateVersion})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// isCurrent reports whether the UTXO set has the format of chainstateVersion
+func (u UTXOSet) isCurrent() bool {
+	current := false
+
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(chainMetaBucket)); b != nil {
+			current = bytes.Equal(b.Get(chainstateVersionKey), []byte{chainstateVersion})
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return current
+}
+
+// disconnect reverts Update for the block at the tip: the outputs of its
+// transactions are removed, the outputs they spent are unspent again and
+// the assets they issued are forgotten
+func (u UTXOSet) disconnect(block *Block) {
+	prevTXs, err := u.Blockchain.findPrevTransactions(block.Transactions)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = u.Blockchain.DB.Update(func(btx *bolt.Tx) error {
+		b := btx.Bucket([]byte(utxoBucket))
+		assets := btx.Bucket([]byte(assetsBucket))
+
+		// Later transactions may spend the outputs of earlier ones
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
+			if err := b.Delete(tx.ID); err != nil {
+				log.Panic(err)
+			}
+			if tx.Issuance != nil && assets != nil {
+				if err := assets.Delete(tx.Issuance.ID); err != nil {
+					log.Panic(err)
+				}
+			}
+			if tx.IsCoinbase() {
+				continue
+			}
+
+			for _, vin := range tx.Vin {
+				outs := NewTXOutputs()
+				if outsBytes := b.Get(vin.Txid); outsBytes != nil {
+					outs = DeserializeOutputs(outsBytes)
+				}
+				outs.Outputs[vin.Vout] = prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+
+				if err := b.Put(vin.Txid, outs.Serialize()); err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 }
 
 // Update updates the UTXO set with transactions from the Block
@@ -146,18 +362,18 @@ func (u UTXOSet) Update(block *Block) {
 	err := db.Update(func(btx *bolt.Tx) error {
 		b := btx.Bucket([]byte(utxoBucket))
 
+		assets, err := btx.CreateBucketIfNotExists([]byte(assetsBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+		putAssets(assets, block.Transactions)
+
 		for _, tx := range block.Transactions {
 			if tx.IsCoinbase() == false {
 				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
 					outsBytes := b.Get(vin.Txid)
-					outs := DeserializeOutputs(outsBytes)
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
+					updatedOuts := DeserializeOutputs(outsBytes)
+					delete(updatedOuts.Outputs, vin.Vout)
 
 					if len(updatedOuts.Outputs) == 0 {
 						err := b.Delete(vin.Txid)
@@ -174,9 +390,16 @@ func (u UTXOSet) Update(block *Block) {
 				}
 			}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs := NewTXOutputs()
+			for outIdx, out := range tx.Vout {
+				if out.IsData() {
+					continue
+				}
+				newOutputs.Outputs[outIdx] = out
+			}
+
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
 
 			err := b.Put(tx.ID, newOutputs.Serialize())
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// sigCheck is the signature of a single input waiting to be verified
+type sigCheck struct {
+	scheme  SigScheme
+	pubKey  []byte
+	sighash []byte
+	sig     []byte
+}
+
+// verify checks the signature, skipping the work when the cache has seen it
+func (c sigCheck) verify() bool {
+	key := sigCacheKey(c.scheme, c.sighash, c.pubKey, c.sig)
+	if sigCache.Contains(key) {
+		return true
+	}
+
+	scheme, err := GetScheme(c.scheme)
+	if err != nil {
+		return false
+	}
+	if !scheme.Verify(c.pubKey, c.sighash, c.sig) {
+		return false
+	}
+
+	sigCache.Add(key)
+
+	return true
+}
+
+// verifySigChecks verifies signatures in a pool of workers, one per CPU
+// core, and stops handing out work after the first failure
+func verifySigChecks(checks []sigCheck) bool {
+	workers := runtime.NumCPU()
+	if workers > len(checks) {
+		workers = len(checks)
+	}
+
+	var failed int32
+	var next int64 = -1
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				n := int(atomic.AddInt64(&next, 1))
+				if n >= len(checks) {
+					return
+				}
+				if !checks[n].verify() {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return failed == 0
+}
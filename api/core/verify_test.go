@@ -0,0 +1,89 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// benchSigChecks returns n valid signatures of distinct messages by one P-256 key
+func benchSigChecks(b *testing.B, n int) []sigCheck {
+	wallet := NewWallet(SchemeP256)
+	checks := make([]sigCheck, n)
+
+	for i := range checks {
+		msg := sha256.Sum256([]byte(fmt.Sprintf("message %d", i)))
+		sig, err := wallet.Sign(msg[:])
+		if err != nil {
+			b.Fatal(err)
+		}
+		checks[i] = sigCheck{SchemeP256, wallet.PublicKey, msg[:], sig}
+	}
+
+	return checks
+}
+
+// withoutSigCache runs f with a signature cache that remembers nothing
+func withoutSigCache(f func()) {
+	saved := sigCache
+	sigCache = NewSigCache(0)
+	defer func() { sigCache = saved }()
+
+	f()
+}
+
+func BenchmarkVerifySigChecksPool(b *testing.B) {
+	checks := benchSigChecks(b, 64)
+
+	withoutSigCache(func() {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !verifySigChecks(checks) {
+				b.Fatal("valid signatures were refused")
+			}
+		}
+	})
+}
+
+func BenchmarkVerifySigChecksSerial(b *testing.B) {
+	checks := benchSigChecks(b, 64)
+
+	withoutSigCache(func() {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, check := range checks {
+				if !check.verify() {
+					b.Fatal("valid signature was refused")
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkSigCacheHit(b *testing.B) {
+	check := benchSigChecks(b, 1)[0]
+	saved := sigCache
+	sigCache = NewSigCache(defaultSigCacheSize)
+	defer func() { sigCache = saved }()
+	check.verify()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !check.verify() {
+			b.Fatal("valid signature was refused")
+		}
+	}
+}
+
+func BenchmarkSigCacheMiss(b *testing.B) {
+	check := benchSigChecks(b, 1)[0]
+
+	withoutSigCache(func() {
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !check.verify() {
+				b.Fatal("valid signature was refused")
+			}
+		}
+	})
+}
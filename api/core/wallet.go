@@ -1,46 +1,68 @@
 package core
 
 import (
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
-	"fmt"
 	"log"
 
-	"github.com/NlaakStudios/Blockchain/api/utils"
 	"golang.org/x/crypto/ripemd160"
 )
 
-const version = byte(0x00)
-const addressChecksumLen = 4
-
-// Wallet stores private and public keys
+// Wallet stores private and public keys. P-256 keys live in PrivateKey,
+// keys of other schemes in SecretKey. Keys derived from the seed of an HD
+// wallet file have their derivation path in Path. Format is the text
+// encoding of the address, Base58 for wallets made before bech32.
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	Scheme     SigScheme
+	SecretKey  []byte
+	Path       string
+	Format     AddressFormat
 }
 
-// NewWallet creates and returns a Wallet
-func NewWallet() *Wallet {
-	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+// NewWallet creates and returns a Wallet using the given signature scheme
+func NewWallet(scheme SigScheme) *Wallet {
+	impl, err := GetScheme(scheme)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallet, err := impl.NewWallet()
+	if err != nil {
+		log.Panic(err)
+	}
 
-	return &wallet
+	return wallet
 }
 
-// GetAddress returns wallet address
-func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+// Sign signs msg with the wallet's private key
+func (w *Wallet) Sign(msg []byte) ([]byte, error) {
+	if w.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	impl, err := GetScheme(w.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return impl.Sign(w, msg)
+}
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
-	checksum := checksum(versionedPayload)
+// InputScheme returns the scheme of inputs signed by the wallet. Legacy
+// P-256 wallets now sign with the fixed-width P-256 scheme.
+func (w *Wallet) InputScheme() SigScheme {
+	if w.Scheme == SchemeP256Legacy {
+		return SchemeP256
+	}
 
-	fullPayload := append(versionedPayload, checksum...)
-	address := utils.Base58Encode(fullPayload)
+	return w.Scheme
+}
 
-	return address
+// GetAddress returns wallet address on the network the node runs on
+func (w Wallet) GetAddress() []byte {
+	return []byte(EncodeAddress(w.Scheme, HashPubKey(w.PublicKey), w.Format))
 }
 
 // HashPubKey hashes public key
@@ -56,34 +78,3 @@ func HashPubKey(pubKey []byte) []byte {
 
 	return publicRIPEMD160
 }
-
-// ValidateAddress check if address if valid
-func ValidateAddress(address string) bool {
-	fmt.Printf("Validating address %s...", address)
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
-
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
-}
-
-// Checksum generates a checksum for a public key
-func checksum(payload []byte) []byte {
-	firstSHA := sha256.Sum256(payload)
-	secondSHA := sha256.Sum256(firstSHA[:])
-
-	return secondSHA[:addressChecksumLen]
-}
-
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err != nil {
-		log.Panic(err)
-	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-
-	return *private, pubKey
-}
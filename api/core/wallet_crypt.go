@@ -0,0 +1,316 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters of newly encrypted wallet files
+const (
+	walletKDFTime    = 3
+	walletKDFMemory  = 64 * 1024 // KiB
+	walletKDFThreads = 4
+	walletKeyLen     = 32
+)
+
+// Errors returned by wallet encryption
+var (
+	ErrWalletEncrypted    = errors.New("Wallet is already encrypted")
+	ErrWalletNotEncrypted = errors.New("Wallet is not encrypted")
+	ErrWalletPassphrase   = errors.New("The wallet passphrase is incorrect")
+	ErrWalletLocked       = errors.New("Wallet is locked, unlock it with its passphrase first")
+)
+
+// WalletCrypt holds the encrypted private keys and HD seed of a wallet
+// file. They are encrypted with a random master key using AES-256-GCM, and
+// the master key with a key derived from the passphrase by Argon2id, so
+// changing the passphrase only encrypts the master key again.
+type WalletCrypt struct {
+	Salt      []byte
+	Time      uint32
+	Memory    uint32
+	Threads   uint8
+	MasterKey []byte            // nonce and sealed master key
+	Keys      map[string][]byte // address -> nonce and sealed private key
+	Mnemonic  []byte            // nonce and sealed mnemonic of the HD seed
+}
+
+// mnemonicAD is the additional data authenticated with the sealed mnemonic
+var mnemonicAD = []byte("mnemonic")
+
+// IsEncrypted reports whether the private keys are encrypted with a passphrase
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.Crypt != nil
+}
+
+// IsLocked reports whether the private keys of an encrypted wallet file
+// are unavailable until the passphrase is given
+func (ws *Wallets) IsLocked() bool {
+	return ws.Crypt != nil && ws.masterKey == nil
+}
+
+// Encrypt encrypts the private keys with a passphrase and locks the wallets
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	masterKey := make([]byte, walletKeyLen)
+	if _, err := rand.Read(masterKey); err != nil {
+		return err
+	}
+
+	crypt, err := newWalletCrypt(passphrase, masterKey)
+	if err != nil {
+		return err
+	}
+	for address, w := range ws.Wallets {
+		crypt.Keys[address], err = seal(masterKey, w.secret(), []byte(address))
+		if err != nil {
+			return err
+		}
+	}
+
+	ws.Crypt = crypt
+	ws.masterKey = masterKey
+	if err := ws.sealMnemonic(); err != nil {
+		ws.Crypt, ws.masterKey = nil, nil
+		return err
+	}
+	ws.Lock()
+
+	return nil
+}
+
+// Unlock decrypts the private keys with the passphrase
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	masterKey, err := ws.Crypt.openMasterKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return ws.unlockWithKey(masterKey)
+}
+
+// unlockWithKey decrypts the private keys with the master key, which lets
+// the wallet file be reloaded while unlocked
+func (ws *Wallets) unlockWithKey(masterKey []byte) error {
+	for address, w := range ws.Wallets {
+		secret, err := open(masterKey, ws.Crypt.Keys[address], []byte(address))
+		if err != nil {
+			return err
+		}
+		w.setSecret(secret)
+	}
+	if ws.HD != nil && ws.Crypt.Mnemonic != nil {
+		mnemonic, err := open(masterKey, ws.Crypt.Mnemonic, mnemonicAD)
+		if err != nil {
+			return err
+		}
+		ws.HD.Mnemonic = string(mnemonic)
+	}
+	ws.masterKey = masterKey
+
+	return nil
+}
+
+// Lock forgets the private keys of an encrypted wallet file
+func (ws *Wallets) Lock() {
+	if !ws.IsEncrypted() {
+		return
+	}
+
+	for _, w := range ws.Wallets {
+		w.wipe()
+	}
+	if ws.HD != nil {
+		ws.HD.Mnemonic = ""
+	}
+	for i := range ws.seed {
+		ws.seed[i] = 0
+	}
+	ws.seed = nil
+	ws.masterKey = nil
+}
+
+// ChangePassphrase encrypts the wallets with a new passphrase
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if !ws.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	masterKey, err := ws.Crypt.openMasterKey(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	crypt, err := newWalletCrypt(newPassphrase, masterKey)
+	if err != nil {
+		return err
+	}
+	crypt.Keys = ws.Crypt.Keys
+	crypt.Mnemonic = ws.Crypt.Mnemonic
+	ws.Crypt = crypt
+
+	return nil
+}
+
+// addKey encrypts the private key of a new wallet, which requires the
+// wallets to be unlocked
+func (ws *Wallets) addKey(address string, w *Wallet) error {
+	if !ws.IsEncrypted() {
+		return nil
+	}
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	sealed, err := seal(ws.masterKey, w.secret(), []byte(address))
+	if err != nil {
+		return err
+	}
+	ws.Crypt.Keys[address] = sealed
+
+	return nil
+}
+
+// sealMnemonic encrypts the mnemonic of the HD seed, which requires the
+// wallets to be unlocked
+func (ws *Wallets) sealMnemonic() error {
+	if !ws.IsEncrypted() || ws.HD == nil {
+		return nil
+	}
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	sealed, err := seal(ws.masterKey, []byte(ws.HD.Mnemonic), mnemonicAD)
+	if err != nil {
+		return err
+	}
+	ws.Crypt.Mnemonic = sealed
+
+	return nil
+}
+
+// newWalletCrypt derives a key from the passphrase and encrypts the master key with it
+func newWalletCrypt(passphrase string, masterKey []byte) (*WalletCrypt, error) {
+	crypt := &WalletCrypt{
+		Salt:    make([]byte, 16),
+		Time:    walletKDFTime,
+		Memory:  walletKDFMemory,
+		Threads: walletKDFThreads,
+		Keys:    make(map[string][]byte),
+	}
+	if _, err := rand.Read(crypt.Salt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	crypt.MasterKey, err = seal(crypt.passphraseKey(passphrase), masterKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypt, nil
+}
+
+func (c *WalletCrypt) passphraseKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), c.Salt, c.Time, c.Memory, c.Threads, walletKeyLen)
+}
+
+func (c *WalletCrypt) openMasterKey(passphrase string) ([]byte, error) {
+	masterKey, err := open(c.passphraseKey(passphrase), c.MasterKey, nil)
+	if err != nil {
+		return nil, ErrWalletPassphrase
+	}
+
+	return masterKey, nil
+}
+
+// seal encrypts and authenticates plaintext and the additional data, and
+// returns the nonce followed by the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open reverses seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("Encrypted key is too short")
+	}
+
+	nonce := sealed[:aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[aead.NonceSize():], additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// secret returns the private key material of the wallet
+func (w *Wallet) secret() []byte {
+	if w.Scheme == SchemeEd25519 {
+		return w.SecretKey
+	}
+	if w.PrivateKey.D == nil {
+		return nil
+	}
+
+	return w.PrivateKey.D.Bytes()
+}
+
+// setSecret restores private key material returned by secret
+func (w *Wallet) setSecret(secret []byte) {
+	if w.Scheme == SchemeEd25519 {
+		w.SecretKey = secret
+		return
+	}
+
+	w.PrivateKey.D = new(big.Int).SetBytes(secret)
+}
+
+// wipe forgets the private key, keeping what is needed for the address
+func (w *Wallet) wipe() {
+	for i := range w.SecretKey {
+		w.SecretKey[i] = 0
+	}
+	w.SecretKey = nil
+	if w.PrivateKey.D != nil {
+		w.PrivateKey.D.SetInt64(0)
+		w.PrivateKey.D = nil
+	}
+}
+
+// IsLocked reports whether the wallet's private key is unavailable
+func (w *Wallet) IsLocked() bool {
+	return len(w.secret()) == 0
+}
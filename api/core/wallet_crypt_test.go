@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWallets creates an HD wallet file, not saved, with a derived key and
+// a key of each scheme. It returns the private keys by address.
+func newTestWallets(t *testing.T) (*Wallets, map[string][]byte) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet), Addresses: make(map[string]*AddressInfo)}
+	ws.CreateWallet(SchemeP256)
+	ws.CreateWallet(SchemeEd25519)
+	if err := ws.SetMnemonic(testMnemonic); err != nil {
+		t.Fatal(err)
+	}
+	ws.CreateWallet(SchemeEd25519)
+
+	secrets := make(map[string][]byte)
+	for address, w := range ws.Wallets {
+		secrets[address] = append([]byte{}, w.secret()...)
+	}
+
+	return ws, secrets
+}
+
+// checkUnlocked fails unless every private key and the mnemonic are back
+func checkUnlocked(t *testing.T, ws *Wallets, secrets map[string][]byte) {
+	t.Helper()
+
+	if ws.IsLocked() {
+		t.Fatal("wallets are still locked")
+	}
+	for address, secret := range secrets {
+		if !bytes.Equal(ws.Wallets[address].secret(), secret) {
+			t.Errorf("private key of %s was not restored", address)
+		}
+	}
+	if ws.HD.Mnemonic != testMnemonic {
+		t.Errorf("mnemonic was not restored")
+	}
+}
+
+// checkLocked fails unless every private key and the mnemonic are forgotten
+func checkLocked(t *testing.T, ws *Wallets) {
+	t.Helper()
+
+	if !ws.IsLocked() {
+		t.Fatal("wallets are not locked")
+	}
+	for address, w := range ws.Wallets {
+		if !w.IsLocked() {
+			t.Errorf("private key of %s is still known", address)
+		}
+	}
+	if ws.HD.Mnemonic != "" {
+		t.Errorf("mnemonic is still known")
+	}
+}
+
+func TestWalletEncryptUnlock(t *testing.T) {
+	ws, secrets := newTestWallets(t)
+
+	if err := ws.Unlock("secret"); err != ErrWalletNotEncrypted {
+		t.Errorf("Unlock before Encrypt: %v, want %v", err, ErrWalletNotEncrypted)
+	}
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(t, ws)
+	if err := ws.Encrypt("secret"); err != ErrWalletEncrypted {
+		t.Errorf("Encrypt twice: %v, want %v", err, ErrWalletEncrypted)
+	}
+
+	if err := ws.Unlock("wrong"); err != ErrWalletPassphrase {
+		t.Fatalf("Unlock with a wrong passphrase: %v, want %v", err, ErrWalletPassphrase)
+	}
+	checkLocked(t, ws)
+
+	if err := ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	checkUnlocked(t, ws, secrets)
+
+	ws.Lock()
+	checkLocked(t, ws)
+	if err := ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	checkUnlocked(t, ws, secrets)
+}
+
+func TestWalletLockedRefusesNewKeys(t *testing.T) {
+	ws, _ := newTestWallets(t)
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ws.NewAddress(SchemeEd25519, ChainReceive); err == nil {
+		t.Errorf("NewAddress derived a key while locked")
+	}
+	if err := ws.addKey("address", NewWallet(SchemeEd25519)); err != ErrWalletLocked {
+		t.Errorf("addKey while locked: %v, want %v", err, ErrWalletLocked)
+	}
+
+	// Keys added while unlocked are encrypted too
+	if err := ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	address := ws.CreateWallet(SchemeP256)
+	secret := append([]byte{}, ws.Wallets[address].secret()...)
+	ws.Lock()
+	if err := ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ws.Wallets[address].secret(), secret) {
+		t.Errorf("private key added while unlocked was not restored")
+	}
+}
+
+func TestWalletChangePassphrase(t *testing.T) {
+	ws, secrets := newTestWallets(t)
+	if err := ws.Encrypt("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.ChangePassphrase("wrong", "new"); err != ErrWalletPassphrase {
+		t.Fatalf("ChangePassphrase with a wrong passphrase: %v, want %v", err, ErrWalletPassphrase)
+	}
+	if err := ws.ChangePassphrase("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.Unlock("old"); err != ErrWalletPassphrase {
+		t.Errorf("Unlock with the old passphrase: %v, want %v", err, ErrWalletPassphrase)
+	}
+	if err := ws.Unlock("new"); err != nil {
+		t.Fatal(err)
+	}
+	checkUnlocked(t, ws, secrets)
+}
+
+func TestWalletTamperedKey(t *testing.T) {
+	ws, _ := newTestWallets(t)
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for address, sealed := range ws.Crypt.Keys {
+		sealed[len(sealed)-1] ^= 1
+		if err := ws.Unlock("secret"); err == nil {
+			t.Errorf("Unlock accepted the tampered key of %s", address)
+		}
+		sealed[len(sealed)-1] ^= 1
+	}
+
+	// A key sealed for another address is refused
+	var addresses []string
+	for address := range ws.Crypt.Keys {
+		addresses = append(addresses, address)
+	}
+	keys := ws.Crypt.Keys
+	keys[addresses[0]], keys[addresses[1]] = keys[addresses[1]], keys[addresses[0]]
+	if err := ws.Unlock("secret"); err == nil {
+		t.Errorf("Unlock accepted keys swapped between addresses")
+	}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/config"
+	"github.com/boltdb/bolt"
+)
+
+// Buckets of the wallet database
+const (
+	walletKeysBucket      = "keys"         // address -> wallet, without its private key once encrypted
+	walletSecretsBucket   = "secrets"      // address -> sealed private key of encrypted wallet files
+	walletAddressesBucket = "addresses"    // address -> AddressInfo
+	walletTxsBucket       = "transactions" // transaction ID -> WalletTx
+	walletMetaBucket      = "meta"         // encryption, HD seed and sync state
+)
+
+// Keys of the meta bucket
+var (
+	walletCryptKey  = []byte("crypt")
+	walletHDKey     = []byte("hd")
+	walletSyncedKey = []byte("synced")
+)
+
+// walletDBTimeout is how long to wait for another process using the wallet database
+const walletDBTimeout = 10 * time.Second
+
+// GetWalletsFile given a node port returns the full path to the wallet database
+func GetWalletsFile(nodeID string) string {
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathWalletDB)
+	return fmt.Sprintf(str, nodeID)
+}
+
+// getLegacyWalletsFile returns the path of the gob wallets file of old versions
+func getLegacyWalletsFile(nodeID string) string {
+	str := fmt.Sprintf("%s/%s", GetDataDir(), config.FilePathWallets)
+	return fmt.Sprintf(str, nodeID)
+}
+
+// openWalletDB opens the wallet database of a node, creating it and its
+// buckets when needed. It is readable by its owner only.
+func openWalletDB(nodeID string) (*bolt.DB, error) {
+	return openWalletDBFile(GetWalletsFile(nodeID))
+}
+
+// openWalletDBFile is openWalletDB for the database at the given path
+func openWalletDBFile(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: walletDBTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{walletKeysBucket, walletSecretsBucket, walletAddressesBucket, walletTxsBucket, walletMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// LoadFromFile loads wallets from the wallet database. A wallets file of
+// an old version is moved into a new database first.
+func (ws *Wallets) LoadFromFile(nodeID string) error {
+	dbFile := GetWalletsFile(nodeID)
+	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+		return ws.migrateLegacyFile(nodeID)
+	}
+
+	db, err := openWalletDB(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer db.Close()
+
+	gob.Register(elliptic.P256())
+	wallets := Wallets{Wallets: make(map[string]*Wallet), Addresses: make(map[string]*AddressInfo)}
+	err = db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(walletMetaBucket))
+		if data := meta.Get(walletCryptKey); data != nil {
+			wallets.Crypt = &WalletCrypt{}
+			if err := gobDecode(data, wallets.Crypt); err != nil {
+				return err
+			}
+			wallets.Crypt.Keys = make(map[string][]byte)
+			err := tx.Bucket([]byte(walletSecretsBucket)).ForEach(func(k, v []byte) error {
+				wallets.Crypt.Keys[string(k)] = append([]byte{}, v...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if data := meta.Get(walletHDKey); data != nil {
+			wallets.HD = &HDChain{}
+			if err := gobDecode(data, wallets.HD); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Bucket([]byte(walletKeysBucket)).ForEach(func(k, v []byte) error {
+			var wallet Wallet
+			if err := gobDecode(v, &wallet); err != nil {
+				return err
+			}
+			wallets.Wallets[string(k)] = &wallet
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket([]byte(walletAddressesBucket)).ForEach(func(k, v []byte) error {
+			var info AddressInfo
+			if err := gobDecode(v, &info); err != nil {
+				return err
+			}
+			wallets.Addresses[string(k)] = &info
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ws.Wallets = wallets.Wallets
+	ws.Addresses = wallets.Addresses
+	ws.Crypt = wallets.Crypt
+	ws.HD = wallets.HD
+	if ws.HD != nil && ws.HD.Next == nil {
+		ws.HD.Next = make(map[SigScheme][]uint32)
+	}
+	ws.masterKey = nil
+	ws.seed = nil
+
+	return nil
+}
+
+// SaveToFile saves wallets to the wallet database, writing only what
+// changed. Entries are never removed, so keys another process added since
+// the wallets were loaded are kept. Private keys and the seed of encrypted
+// wallets are only written encrypted.
+func (ws Wallets) SaveToFile(nodeID string) {
+	db, err := openWalletDB(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer db.Close()
+
+	err = db.Update(ws.write)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// ReplaceFile saves wallets to a new wallet database, along with the
+// transactions of the old one, and puts it in place of the old one. Bolt
+// keeps the pages it frees, so this is how plaintext keys are dropped from
+// the disk once the wallets are encrypted.
+func (ws Wallets) ReplaceFile(nodeID string) {
+	dbFile := GetWalletsFile(nodeID)
+	old, err := openWalletDB(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer old.Close()
+
+	newFile := dbFile + ".new"
+	if err := os.Remove(newFile); err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	db, err := openWalletDBFile(newFile)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = old.View(func(oldTx *bolt.Tx) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			txs := tx.Bucket([]byte(walletTxsBucket))
+			err := oldTx.Bucket([]byte(walletTxsBucket)).ForEach(func(k, v []byte) error {
+				return txs.Put(k, v)
+			})
+			if err != nil {
+				return err
+			}
+			if synced := oldTx.Bucket([]byte(walletMetaBucket)).Get(walletSyncedKey); synced != nil {
+				if err := tx.Bucket([]byte(walletMetaBucket)).Put(walletSyncedKey, synced); err != nil {
+					return err
+				}
+			}
+
+			return ws.write(tx)
+		})
+	})
+	if err == nil {
+		err = db.Close()
+	} else {
+		db.Close()
+	}
+	if err == nil {
+		err = os.Rename(newFile, dbFile)
+	}
+	if err != nil {
+		os.Remove(newFile)
+		log.Panic(err)
+	}
+}
+
+// write puts the entries of the wallets that differ from those of the
+// database. Unencrypted wallets are not written over an encrypted database.
+func (ws Wallets) write(tx *bolt.Tx) error {
+	meta := tx.Bucket([]byte(walletMetaBucket))
+	if !ws.IsEncrypted() && meta.Get(walletCryptKey) != nil {
+		return ErrWalletEncrypted
+	}
+
+	gob.Register(elliptic.P256())
+	keys := tx.Bucket([]byte(walletKeysBucket))
+	for address, w := range ws.Wallets {
+		wallet := *w
+		if ws.IsEncrypted() {
+			wallet.PrivateKey.D = nil
+			wallet.SecretKey = nil
+		}
+		if err := putIfChanged(keys, []byte(address), gobEncode(wallet)); err != nil {
+			return err
+		}
+	}
+
+	if ws.IsEncrypted() {
+		secrets := tx.Bucket([]byte(walletSecretsBucket))
+		for address, secret := range ws.Crypt.Keys {
+			if err := putIfChanged(secrets, []byte(address), secret); err != nil {
+				return err
+			}
+		}
+	}
+
+	addresses := tx.Bucket([]byte(walletAddressesBucket))
+	for address, info := range ws.Addresses {
+		if err := putIfChanged(addresses, []byte(address), gobEncode(info)); err != nil {
+			return err
+		}
+	}
+
+	if ws.IsEncrypted() {
+		crypt := *ws.Crypt
+		crypt.Keys = nil
+		if err := putIfChanged(meta, walletCryptKey, gobEncode(crypt)); err != nil {
+			return err
+		}
+	}
+	if ws.HD != nil {
+		chain := *ws.HD
+		if ws.IsEncrypted() {
+			chain.Mnemonic = ""
+		}
+		if err := putIfChanged(meta, walletHDKey, gobEncode(chain)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateLegacyFile moves the gob wallets file of old versions into the
+// wallet database and keeps it as a backup
+func (ws *Wallets) migrateLegacyFile(nodeID string) error {
+	legacyFile := getLegacyWalletsFile(nodeID)
+	if _, err := os.Stat(legacyFile); err != nil {
+		return err
+	}
+
+	fileContent, err := ioutil.ReadFile(legacyFile)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var legacy struct {
+		Wallets map[string]*Wallet
+		Crypt   *WalletCrypt
+		HD      *HDChain
+		Watch   map[string]bool
+	}
+	gob.Register(elliptic.P256())
+	err = gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&legacy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ws.Wallets = legacy.Wallets
+	if ws.Wallets == nil {
+		ws.Wallets = make(map[string]*Wallet)
+	}
+	ws.Addresses = make(map[string]*AddressInfo)
+	ws.Crypt = legacy.Crypt
+	ws.HD = legacy.HD
+	ws.masterKey = nil
+	ws.seed = nil
+
+	// When the keys were created is unknown
+	for address, w := range ws.Wallets {
+		purpose := PurposeReceive
+		if w.Path != "" {
+			purpose = chainPurpose(hdChain(w.Path))
+		}
+		ws.Addresses[address] = &AddressInfo{"", 0, purpose}
+	}
+	for address := range legacy.Watch {
+		ws.Addresses[address] = &AddressInfo{"", 0, PurposeWatchOnly}
+	}
+
+	ws.SaveToFile(nodeID)
+	err = os.Rename(legacyFile, legacyFile+".bak")
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Moved %s into the wallet database %s\n", legacyFile, GetWalletsFile(nodeID))
+
+	return nil
+}
+
+// putIfChanged writes a value unless the bucket holds it already
+func putIfChanged(b *bolt.Bucket, key, value []byte) error {
+	if bytes.Equal(b.Get(key), value) {
+		return nil
+	}
+
+	return b.Put(key, value)
+}
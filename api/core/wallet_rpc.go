@@ -0,0 +1,186 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NlaakStudios/Blockchain/api/coinselect"
+)
+
+// WalletPassphraseParams are the parameters of walletpassphrase: the node
+// keeps its wallets unlocked for Timeout seconds
+type WalletPassphraseParams struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int    `json:"timeout"`
+}
+
+// SendParams are the parameters of send. Amount is in whole units of the
+// asset, ie 1.25, and the native coin is sent when Asset is empty. A
+// Replaceable transaction signals replace-by-fee.
+type SendParams struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Asset       string `json:"asset,omitempty"`
+	Amount      string `json:"amount"`
+	Strategy    string `json:"strategy,omitempty"`
+	FeeRate     Amount `json:"fee_rate,omitempty"`
+	Replaceable bool   `json:"replaceable,omitempty"`
+}
+
+// walletPassphrase loads the wallets of the node and unlocks them until the
+// timeout, replacing an earlier timeout
+func (s *rpcServer) walletPassphrase(data json.RawMessage) (interface{}, error) {
+	var params WalletPassphraseParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if params.Timeout <= 0 {
+		return nil, errors.New("Timeout must be a positive number of seconds")
+	}
+
+	wallets, err := NewWallets(s.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := wallets.Unlock(params.Passphrase); err != nil {
+		return nil, err
+	}
+
+	s.walletMu.Lock()
+	defer s.walletMu.Unlock()
+
+	s.lockWallets()
+	s.wallets = wallets
+	s.lockTimer = time.AfterFunc(time.Duration(params.Timeout)*time.Second, func() {
+		s.walletMu.Lock()
+		defer s.walletMu.Unlock()
+
+		if s.wallets == wallets {
+			s.lockWallets()
+		}
+	})
+
+	return true, nil
+}
+
+// walletLock locks the wallets of the node at once
+func (s *rpcServer) walletLock(data json.RawMessage) (interface{}, error) {
+	s.walletMu.Lock()
+	defer s.walletMu.Unlock()
+
+	s.lockWallets()
+
+	return true, nil
+}
+
+// lockWallets forgets the unlocked wallets. walletMu must be held.
+func (s *rpcServer) lockWallets() {
+	if s.lockTimer != nil {
+		s.lockTimer.Stop()
+		s.lockTimer = nil
+	}
+	if s.wallets != nil {
+		s.wallets.Lock()
+		s.wallets = nil
+	}
+}
+
+// send pays from a wallet of the node and relays the transaction. It
+// returns the transaction ID. The wallet must be encrypted and unlocked,
+// unless the node allows unencrypted wallets.
+func (s *rpcServer) send(data json.RawMessage) (interface{}, error) {
+	var params SendParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if err := ValidateAddress(params.From); err != nil {
+		return nil, fmt.Errorf("Sender address is not valid: %s", err)
+	}
+	if err := ValidateAddress(params.To); err != nil {
+		return nil, fmt.Errorf("Recipient address is not valid: %s", err)
+	}
+	strategy, err := coinselect.Get(params.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	feeRate := params.FeeRate
+	if feeRate == 0 {
+		feeRate = DefaultFeeRate
+	}
+
+	UTXOSet := UTXOSet{s.bc}
+	assetID, symbol, decimals, err := UTXOSet.ResolveAsset(params.Asset)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := ParseAmount(params.Amount, decimals, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	s.walletMu.Lock()
+	defer s.walletMu.Unlock()
+
+	// The file is read again since addresses may have been created after
+	// walletpassphrase, and the next change address is written back
+	wallets, err := NewWallets(s.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if !wallets.IsEncrypted() && !s.config.AllowUnencryptedWallet {
+		return nil, ErrRPCWalletUnencrypted
+	}
+	if wallets.IsEncrypted() {
+		if s.wallets == nil {
+			return nil, ErrWalletLocked
+		}
+		if err := wallets.unlockWithKey(s.wallets.masterKey); err != nil {
+			return nil, err
+		}
+		defer wallets.Lock()
+	}
+	wallet, ok := wallets.Wallets[params.From]
+	if !ok {
+		if wallets.IsWatchOnly(params.From) {
+			return nil, ErrWalletWatchOnly
+		}
+		return nil, fmt.Errorf("Address %s is not in the wallet", params.From)
+	}
+
+	change := wallets.ChangeAddress(wallet.Scheme)
+	tx := NewUTXOTransaction(wallet, params.To, change, assetID, amount, strategy, feeRate, params.Replaceable, &UTXOSet, mempool)
+	err = acceptTransaction(s.bc, tx)
+	if err != nil {
+		return nil, err
+	}
+	if change != "" {
+		wallets.SaveToFile(s.nodeID)
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// WalletPassphrase unlocks the wallets of the node for timeout
+func (c *RPCClient) WalletPassphrase(passphrase string, timeout time.Duration) error {
+	var ok bool
+
+	return c.Call("walletpassphrase", WalletPassphraseParams{passphrase, int(timeout / time.Second)}, &ok)
+}
+
+// WalletLock locks the wallets of the node
+func (c *RPCClient) WalletLock() error {
+	var ok bool
+
+	return c.Call("walletlock", nil, &ok)
+}
+
+// Send pays from a wallet of the node and returns the transaction ID
+func (c *RPCClient) Send(params SendParams) (string, error) {
+	var txID string
+	err := c.Call("send", params, &txID)
+
+	return txID, err
+}
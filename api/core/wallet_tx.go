@@ -0,0 +1,294 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/boltdb/bolt"
+)
+
+// WalletTx is a transaction paying to or spending from an address of the
+// wallet file, watch-only ones included
+type WalletTx struct {
+	Tx        Transaction
+	Time      int64  // unix time of the block, or when first seen unconfirmed
+	BlockHash []byte // nil while unconfirmed
+	Height    int    // -1 while unconfirmed
+}
+
+// IsConfirmed reports whether the transaction is in a block of the chain
+func (wtx *WalletTx) IsConfirmed() bool {
+	return wtx.Height >= 0
+}
+
+// Confirmations returns the number of blocks on top of the transaction's
+// block, that block included, for a chain of the given best height
+func (wtx *WalletTx) Confirmations(bestHeight int) int {
+	if !wtx.IsConfirmed() {
+		return 0
+	}
+
+	return bestHeight - wtx.Height + 1
+}
+
+// WalletTxDetails is what a wallet transaction means for the wallet in native coins
+type WalletTxDetails struct {
+	Received  Amount   // paid to the wallet
+	Sent      Amount   // spent from the wallet
+	Fee       Amount   // paid by the wallet, when it paid all the inputs
+	Addresses []string // wallet addresses paid or spent from
+	WatchOnly bool     // a watch-only address is involved
+}
+
+// SyncTransactions records the transactions of the wallet addresses found
+// in the blocks added since the last sync, or in the whole chain when
+// rescanning, and in the mempool. It returns the number of transactions
+// added or whose confirmation changed. Transactions of blocks that left
+// the chain become unconfirmed.
+func (ws *Wallets) SyncTransactions(nodeID string, bc *Blockchain, mempool *Mempool, rescan bool) (int, error) {
+	mine := ws.pubKeyHashes()
+
+	db, err := openWalletDB(nodeID)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	changed := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		txs := tx.Bucket([]byte(walletTxsBucket))
+		meta := tx.Bucket([]byte(walletMetaBucket))
+
+		synced := meta.Get(walletSyncedKey)
+		if rescan {
+			synced = nil
+		}
+
+		record := func(wtx *WalletTx) error {
+			key := wtx.Tx.ID
+			if old := txs.Get(key); old != nil {
+				var stored WalletTx
+				if err := gobDecode(old, &stored); err != nil {
+					return err
+				}
+				if bytes.Equal(stored.BlockHash, wtx.BlockHash) {
+					return nil
+				}
+				if !wtx.IsConfirmed() {
+					// Only a walk back to the synced block may unconfirm it
+					return nil
+				}
+			}
+			changed++
+			return txs.Put(key, gobEncode(wtx))
+		}
+
+		seen := make(map[string]bool)
+		reached := false
+		bci := bc.Iterator()
+		for {
+			block := bci.Next()
+			if synced != nil && bytes.Equal(block.Hash, synced) {
+				reached = true
+				break
+			}
+
+			for _, t := range block.Transactions {
+				if !ws.touches(t, mine) {
+					continue
+				}
+				seen[hex.EncodeToString(t.ID)] = true
+				err := record(&WalletTx{*t, block.Timestamp, block.Hash, block.Height})
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(block.PrevBlockHash) == 0 {
+				break
+			}
+		}
+
+		// The synced block left the chain, or everything was scanned again:
+		// what was not found is no longer confirmed
+		if !reached {
+			var unconfirmed []*WalletTx
+			err := txs.ForEach(func(k, v []byte) error {
+				var stored WalletTx
+				if err := gobDecode(v, &stored); err != nil {
+					return err
+				}
+				if stored.IsConfirmed() && !seen[hex.EncodeToString(k)] {
+					stored.BlockHash, stored.Height = nil, -1
+					unconfirmed = append(unconfirmed, &stored)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, wtx := range unconfirmed {
+				changed++
+				if err := txs.Put(wtx.Tx.ID, gobEncode(wtx)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if mempool != nil {
+			for _, entry := range mempool.Entries() {
+				if !ws.touches(&entry.Tx, mine) || txs.Get(entry.Tx.ID) != nil {
+					continue
+				}
+				err := record(&WalletTx{entry.Tx, entry.Time.Unix(), nil, -1})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return meta.Put(walletSyncedKey, bc.Tip)
+	})
+
+	return changed, err
+}
+
+// Transactions returns the recorded transactions of the wallet file,
+// newest first
+func (ws *Wallets) Transactions(nodeID string) ([]*WalletTx, error) {
+	db, err := openWalletDB(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var wtxs []*WalletTx
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(walletTxsBucket)).ForEach(func(k, v []byte) error {
+			var wtx WalletTx
+			if err := gobDecode(v, &wtx); err != nil {
+				return err
+			}
+			wtxs = append(wtxs, &wtx)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(wtxs, func(i, j int) bool {
+		if wtxs[i].IsConfirmed() != wtxs[j].IsConfirmed() {
+			return !wtxs[i].IsConfirmed()
+		}
+		if wtxs[i].Height != wtxs[j].Height {
+			return wtxs[i].Height > wtxs[j].Height
+		}
+		return wtxs[i].Time > wtxs[j].Time
+	})
+
+	return wtxs, nil
+}
+
+// Details works out what a transaction means for the wallet. The outputs
+// it spends are looked up in history, the wallet transactions by ID.
+func (ws *Wallets) Details(wtx *WalletTx, history map[string]*WalletTx) WalletTxDetails {
+	var details WalletTxDetails
+	addresses := ws.addressesByPubKeyHash()
+	involved := make(map[string]bool)
+	note := func(pubKeyHash []byte) bool {
+		address, ok := addresses[hex.EncodeToString(pubKeyHash)]
+		if ok && !involved[address] {
+			involved[address] = true
+			details.Addresses = append(details.Addresses, address)
+			details.WatchOnly = details.WatchOnly || ws.IsWatchOnly(address)
+		}
+		return ok
+	}
+
+	var in, out Amount
+	allKnown := !wtx.Tx.IsCoinbase()
+	if !wtx.Tx.IsCoinbase() {
+		for _, vin := range wtx.Tx.Vin {
+			prev, ok := history[hex.EncodeToString(vin.Txid)]
+			if !ok || vin.Vout >= len(prev.Tx.Vout) {
+				allKnown = false
+				continue
+			}
+			spent := prev.Tx.Vout[vin.Vout]
+			if len(spent.Asset) > 0 {
+				continue
+			}
+			in += spent.Value
+			if note(spent.PubKeyHash) {
+				details.Sent += spent.Value
+			} else {
+				allKnown = false
+			}
+		}
+	}
+
+	for _, vout := range wtx.Tx.Vout {
+		if vout.IsData() || len(vout.Asset) > 0 {
+			continue
+		}
+		out += vout.Value
+		if note(vout.PubKeyHash) {
+			details.Received += vout.Value
+		}
+	}
+
+	if allKnown && in > out {
+		details.Fee = in - out
+	}
+
+	return details
+}
+
+// touches reports whether a transaction pays to or spends from one of the
+// public key hashes
+func (ws *Wallets) touches(tx *Transaction, pubKeyHashes map[string]bool) bool {
+	for _, out := range tx.Vout {
+		if !out.IsData() && pubKeyHashes[hex.EncodeToString(out.PubKeyHash)] {
+			return true
+		}
+	}
+	if tx.IsCoinbase() {
+		return false
+	}
+	for _, in := range tx.Vin {
+		if pubKeyHashes[hex.EncodeToString(HashPubKey(in.PubKey))] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pubKeyHashes returns the hex encoded public key hashes of the keys and
+// watch-only addresses of the wallet file
+func (ws *Wallets) pubKeyHashes() map[string]bool {
+	pubKeyHashes := make(map[string]bool)
+	for pubKeyHash := range ws.addressesByPubKeyHash() {
+		pubKeyHashes[pubKeyHash] = true
+	}
+
+	return pubKeyHashes
+}
+
+// addressesByPubKeyHash maps the hex encoded public key hashes of the keys
+// and watch-only addresses of the wallet file to their addresses
+func (ws *Wallets) addressesByPubKeyHash() map[string]string {
+	addresses := make(map[string]string)
+	for address, w := range ws.Wallets {
+		addresses[hex.EncodeToString(HashPubKey(w.PublicKey))] = address
+	}
+	for _, address := range ws.GetWatchOnly() {
+		if _, pubKeyHash, err := DecodeAddress(address); err == nil {
+			addresses[hex.EncodeToString(pubKeyHash)] = address
+		}
+	}
+
+	return addresses
+}
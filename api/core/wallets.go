@@ -2,48 +2,75 @@ package core
 
 import (
 	"bytes"
-	"crypto/elliptic"
-	"encoding/gob"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
+)
 
-	"github.com/NlaakStudios/Blockchain/api/config"
+// Purposes of the addresses of a wallet file
+const (
+	PurposeReceive   = "receive"    // handed out for payments
+	PurposeChange    = "change"     // receives change
+	PurposeImported  = "imported"   // key imported with importprivkey
+	PurposeWatchOnly = "watch-only" // watched without its key
+	PurposeSend      = "send"       // someone else's, only labelled
 )
 
-// Wallets stores a collection of wallets
+// Wallets stores a collection of wallets, the metadata of their addresses
+// and the seed of HD wallet files. Once encrypted, the file keeps only
+// public keys in Wallets and the private keys and the seed in Crypt.
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets   map[string]*Wallet
+	Addresses map[string]*AddressInfo
+	Crypt     *WalletCrypt
+	HD        *HDChain
+	masterKey []byte
+	seed      []byte
 }
 
-// GetWalletsFile given a node port returns the full path to the wallets file
-func GetWalletsFile(nodeID string) string {
-	str := fmt.Sprintf("%s/%s", config.FilePathData, config.FilePathWallets)
-	return fmt.Sprintf(str, nodeID)
+// AddressInfo is what the wallet file knows about an address besides its key
+type AddressInfo struct {
+	Label   string
+	Created int64 // unix time, 0 when unknown
+	Purpose string
 }
 
 // NewWallets creates Wallets and fills it from a file if it exists
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Addresses = make(map[string]*AddressInfo)
 
 	err := wallets.LoadFromFile(nodeID)
 
 	return &wallets, err
 }
 
-// CreateWallet adds a Wallet to Wallets
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+// CreateWallet adds a Wallet using the given signature scheme to Wallets.
+// HD wallet files derive it as the next receive address. Encrypted wallets
+// must be unlocked.
+func (ws *Wallets) CreateWallet(scheme SigScheme) string {
+	if _, err := hdCurve(scheme); err == nil && ws.IsHD() {
+		address, err := ws.NewAddress(scheme, ChainReceive)
+		if err != nil {
+			log.Panic(err)
+		}
+		return address
+	}
+
+	wallet := NewWallet(scheme)
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
+	err := ws.addKey(address, wallet)
+	if err != nil {
+		log.Panic(err)
+	}
 	ws.Wallets[address] = wallet
+	ws.noteAddress(address, PurposeReceive)
 
 	return address
 }
 
-// GetAddresses returns an array of addresses stored in the wallet file
+// GetAddresses returns the addresses of the keys stored in the wallet file
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
 
@@ -54,51 +81,67 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
-// GetWallet returns a Wallet by its address
-func (ws Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// FindByPubKey returns the address and wallet of a public key, or a nil
+// wallet when it is not in the wallet file
+func (ws *Wallets) FindByPubKey(pubKey []byte) (string, *Wallet) {
+	for address, w := range ws.Wallets {
+		if bytes.Equal(w.PublicKey, pubKey) {
+			return address, w
+		}
+	}
+
+	return "", nil
 }
 
-// LoadFromFile loads wallets from the file
-func (ws *Wallets) LoadFromFile(nodeID string) error {
-	dbFile := GetWalletsFile(nodeID)
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
-		return err
+// GetWallet returns a Wallet by its address. Watch-only addresses and
+// addresses of other wallets have none.
+func (ws Wallets) GetWallet(address string) Wallet {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		if ws.IsWatchOnly(address) {
+			log.Panic("ERROR: ", ErrWalletWatchOnly)
+		}
+		log.Panicf("ERROR: Address %s is not in the wallet", address)
 	}
 
-	fileContent, err := ioutil.ReadFile(dbFile)
-	if err != nil {
-		log.Panic(err)
-	}
+	return *wallet
+}
 
-	var wallets Wallets
-	gob.Register(elliptic.P256())
-	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
-	if err != nil {
-		log.Panic(err)
+// SetLabel labels an address. Addresses not in the wallet file are kept
+// as someone else's, to label payments to them.
+func (ws *Wallets) SetLabel(address, label string) error {
+	if err := ValidateAddress(address); err != nil {
+		return fmt.Errorf("Address %s is not valid: %s", address, err)
 	}
 
-	ws.Wallets = wallets.Wallets
+	ws.noteAddress(address, PurposeSend)
+	ws.Addresses[address].Label = label
 
 	return nil
 }
 
-// SaveToFile saves wallets to a file
-func (ws Wallets) SaveToFile(nodeID string) {
-	var content bytes.Buffer
-	walletFile := fmt.Sprintf(config.FilePathWallets, nodeID)
+// Label returns the label of an address, empty when it has none
+func (ws *Wallets) Label(address string) string {
+	if info, ok := ws.Addresses[address]; ok {
+		return info.Label
+	}
 
-	gob.Register(elliptic.P256())
+	return ""
+}
 
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
-	if err != nil {
-		log.Panic(err)
+// noteAddress records the creation of an address with its purpose, or
+// changes the purpose of an address known already
+func (ws *Wallets) noteAddress(address, purpose string) {
+	if ws.Addresses == nil {
+		ws.Addresses = make(map[string]*AddressInfo)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
-	if err != nil {
-		log.Panic(err)
+	info, ok := ws.Addresses[address]
+	if !ok {
+		ws.Addresses[address] = &AddressInfo{"", Now().Unix(), purpose}
+		return
+	}
+	if purpose != PurposeSend {
+		info.Purpose = purpose
 	}
 }
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Messages between nodes are framed by a header: the magic bytes of the
+// network, the command NUL-padded to commandLength bytes, the length of the
+// payload as a little-endian uint32 and the first 4 bytes of the double
+// SHA-256 of the payload. The gob encoded payload follows.
+const (
+	commandLength      = 12
+	messageChecksumLen = 4
+	messageHeaderLen   = 4 + commandLength + 4 + messageChecksumLen
+)
+
+// MaxMessagePayload is the largest payload a message may carry. It leaves
+// room for a full block with its gob overhead.
+const MaxMessagePayload = 4 * MaxBlockSize
+
+// Errors returned when reading or writing a message
+var (
+	ErrMessageMagic    = errors.New("Message is for another network")
+	ErrMessageCommand  = errors.New("Message command is malformed")
+	ErrMessageTooLarge = errors.New("Message payload is too large")
+	ErrMessageChecksum = errors.New("Message checksum does not match")
+)
+
+// Message is a command sent between nodes with its payload
+type Message struct {
+	Command string
+	Payload []byte
+}
+
+// WriteMessage frames a command and its payload with a header for the
+// active network and writes them to w
+func WriteMessage(w io.Writer, command string, payload []byte) error {
+	if len(command) == 0 || len(command) > commandLength {
+		return ErrMessageCommand
+	}
+	if len(payload) > MaxMessagePayload {
+		return ErrMessageTooLarge
+	}
+
+	header := make([]byte, messageHeaderLen)
+	copy(header, ActiveParams.Magic[:])
+	copy(header[4:], command)
+	binary.LittleEndian.PutUint32(header[4+commandLength:], uint32(len(payload)))
+	copy(header[8+commandLength:], messageChecksum(payload))
+
+	_, err := w.Write(append(header, payload...))
+
+	return err
+}
+
+// ReadMessage reads the next message of a stream. It returns io.EOF when
+// the stream ends between two messages. Frames of another network, with a
+// malformed command, too large or whose checksum does not match are
+// rejected before their payload is used.
+func ReadMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, messageHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[:4], ActiveParams.Magic[:]) {
+		return nil, ErrMessageMagic
+	}
+	command, err := parseCommand(header[4 : 4+commandLength])
+	if err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[4+commandLength:])
+	if length > MaxMessagePayload {
+		return nil, ErrMessageTooLarge
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[8+commandLength:], messageChecksum(payload)) {
+		return nil, ErrMessageChecksum
+	}
+
+	return &Message{command, payload}, nil
+}
+
+// parseCommand returns the command of a header: printable ASCII followed by
+// NUL padding only
+func parseCommand(field []byte) (string, error) {
+	end := bytes.IndexByte(field, 0)
+	if end < 0 {
+		end = len(field)
+	}
+	if end == 0 {
+		return "", ErrMessageCommand
+	}
+	for i, b := range field {
+		if (i < end && (b < 0x21 || b > 0x7e)) || (i >= end && b != 0) {
+			return "", ErrMessageCommand
+		}
+	}
+
+	return string(field[:end]), nil
+}
+
+// messageChecksum returns the checksum of a payload
+func messageChecksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+
+	return second[:messageChecksumLen]
+}
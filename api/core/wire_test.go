@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// frame returns a message as WriteMessage writes it
+func frame(t *testing.T, command string, payload []byte) []byte {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, command, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadMessage(t *testing.T) {
+	payload := []byte("payload")
+	valid := frame(t, "version", payload)
+
+	msg, err := ReadMessage(bytes.NewReader(append(valid, frame(t, "verack", nil)...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Command != "version" || !bytes.Equal(msg.Payload, payload) {
+		t.Errorf("ReadMessage = %q %q, want %q %q", msg.Command, msg.Payload, "version", payload)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(data []byte) []byte
+		err    error
+	}{
+		{
+			name:   "end of stream",
+			mutate: func(data []byte) []byte { return nil },
+			err:    io.EOF,
+		},
+		{
+			name: "wrong magic",
+			mutate: func(data []byte) []byte {
+				data[0] ^= 0xff
+				return data
+			},
+			err: ErrMessageMagic,
+		},
+		{
+			name: "command not padded with NUL",
+			mutate: func(data []byte) []byte {
+				data[4+len("version")+1] = 'x'
+				return data
+			},
+			err: ErrMessageCommand,
+		},
+		{
+			name: "bad checksum",
+			mutate: func(data []byte) []byte {
+				data[len(data)-1] ^= 0xff
+				return data
+			},
+			err: ErrMessageChecksum,
+		},
+		{
+			name: "length above MaxMessagePayload",
+			mutate: func(data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[4+commandLength:], MaxMessagePayload+1)
+				return data
+			},
+			err: ErrMessageTooLarge,
+		},
+		{
+			name:   "truncated header",
+			mutate: func(data []byte) []byte { return data[:messageHeaderLen-1] },
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "truncated payload",
+			mutate: func(data []byte) []byte { return data[:len(data)-1] },
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "header without payload",
+			mutate: func(data []byte) []byte { return data[:messageHeaderLen] },
+			err:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.mutate(append([]byte{}, valid...))
+			if _, err := ReadMessage(bytes.NewReader(data)); err != test.err {
+				t.Errorf("ReadMessage: %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	if err := WriteMessage(ioutil.Discard, "", nil); err != ErrMessageCommand {
+		t.Errorf("empty command: %v, want %v", err, ErrMessageCommand)
+	}
+	if err := WriteMessage(ioutil.Discard, "commandtoolong", nil); err != ErrMessageCommand {
+		t.Errorf("long command: %v, want %v", err, ErrMessageCommand)
+	}
+	if err := WriteMessage(ioutil.Discard, "block", make([]byte, MaxMessagePayload+1)); err != ErrMessageTooLarge {
+		t.Errorf("large payload: %v, want %v", err, ErrMessageTooLarge)
+	}
+}
@@ -0,0 +1,188 @@
+// Package hd derives hierarchical deterministic keys from a seed the way
+// BIP32 does, adapted to the curves of the blockchain as in SLIP-0010, and
+// encodes seeds as BIP39 mnemonics.
+package hd
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Hardened is added to the index of hardened children, whose keys cannot
+// be derived from the parent public key
+const Hardened uint32 = 0x80000000
+
+// Curve selects the curve keys are derived for
+type Curve int
+
+const (
+	// P256 derives NIST P-256 private keys
+	P256 Curve = iota
+	// Ed25519 derives Ed25519 seeds, for hardened children only
+	Ed25519
+)
+
+// ErrNotHardened is returned when deriving a normal child of an Ed25519 key
+var ErrNotHardened = errors.New("Ed25519 keys only have hardened children")
+
+// Key is an extended private key: a private key and the chain code that
+// derives its children
+type Key struct {
+	Curve     Curve
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMasterKey derives the root key of a seed
+func NewMasterKey(seed []byte, curve Curve) (*Key, error) {
+	var hmacKey []byte
+	switch curve {
+	case P256:
+		hmacKey = []byte("Nist256p1 seed")
+	case Ed25519:
+		hmacKey = []byte("ed25519 seed")
+	default:
+		return nil, fmt.Errorf("Unknown curve %d", curve)
+	}
+
+	i := hmacSHA512(hmacKey, seed)
+	for curve == P256 && !validP256Key(i[:32]) {
+		i = hmacSHA512(hmacKey, i)
+	}
+
+	return &Key{curve, i[:32], i[32:]}, nil
+}
+
+// Child derives the child key with the given index
+func (k *Key) Child(index uint32) (*Key, error) {
+	hardened := index >= Hardened
+	if k.Curve == Ed25519 && !hardened {
+		return nil, ErrNotHardened
+	}
+
+	data := make([]byte, 0, 37)
+	if hardened {
+		data = append(append(data, 0), k.Key...)
+	} else {
+		data = append(data, compressedP256PubKey(k.Key)...)
+	}
+	data = append(data, ser32(index)...)
+
+	i := hmacSHA512(k.ChainCode, data)
+	if k.Curve == Ed25519 {
+		return &Key{k.Curve, i[:32], i[32:]}, nil
+	}
+
+	// IL + parent key must be a valid key, otherwise derivation goes on
+	// from the right half of the HMAC
+	n := elliptic.P256().Params().N
+	for {
+		if validP256Key(i[:32]) {
+			child := new(big.Int).SetBytes(i[:32])
+			child.Add(child, new(big.Int).SetBytes(k.Key))
+			child.Mod(child, n)
+			if child.Sign() != 0 {
+				return &Key{k.Curve, child.FillBytes(make([]byte, 32)), i[32:]}, nil
+			}
+		}
+
+		data = append([]byte{1}, i[32:]...)
+		data = append(data, ser32(index)...)
+		i = hmacSHA512(k.ChainCode, data)
+	}
+}
+
+// Derive follows a path of child indexes from the key
+func (k *Key) Derive(path []uint32) (*Key, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// ParsePath parses a path like m/44'/1'/0'/0/5, where ' or h marks hardened indexes
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("Path %q does not start with m", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			offset = Hardened
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("Path %q has an invalid index %q", path, part)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+
+	return indexes, nil
+}
+
+// FormatPath prints a path of child indexes, marking hardened ones with '
+func FormatPath(path []uint32) string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range path {
+		if index >= Hardened {
+			fmt.Fprintf(&b, "/%d'", index-Hardened)
+		} else {
+			fmt.Fprintf(&b, "/%d", index)
+		}
+	}
+
+	return b.String()
+}
+
+// ser32 serializes a child index as 4 big endian bytes
+func ser32(index uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, index)
+
+	return b
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// validP256Key reports whether k is a private key, between 1 and n-1
+func validP256Key(k []byte) bool {
+	d := new(big.Int).SetBytes(k)
+
+	return d.Sign() > 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// compressedP256PubKey returns the public key of k as 0x02 or 0x03 for
+// the parity of Y followed by X
+func compressedP256PubKey(k []byte) []byte {
+	x, y := elliptic.P256().ScalarBaseMult(k)
+
+	pub := make([]byte, 33)
+	pub[0] = 2 + byte(y.Bit(0))
+	x.FillBytes(pub[1:])
+
+	return pub
+}
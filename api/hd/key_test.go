@@ -0,0 +1,99 @@
+package hd
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// keyVector is a derived key of a SLIP-0010 test vector
+type keyVector struct {
+	path      string
+	chainCode string
+	key       string
+}
+
+// Test vector 1 of SLIP-0010 for both curves, from the seed
+// 000102030405060708090a0b0c0d0e0f
+var keyVectors = map[Curve][]keyVector{
+	P256: {
+		{"m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+		{"m/0'", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+		{"m/0'/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c", "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+		{"m/0'/1/2'", "98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318", "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+		{"m/0'/1/2'/2", "ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0", "5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+		{"m/0'/1/2'/2/1000000000", "b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059", "21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119"},
+	},
+	Ed25519: {
+		{"m", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7"},
+		{"m/0'", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"},
+		{"m/0'/1'", "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2"},
+		{"m/0'/1'/2'", "2e69929e00b5ab250f49c3fb1c12f252de4fed2c1db88387094a0f8c4c9ccd6c", "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9"},
+		{"m/0'/1'/2'/2'", "8f6d87f93d750e0efccda017d662a1b31a266e4a6f5993b15f5c1f07f74dd5cc", "30d1dc7e5fc04c31219ab25a27ae00b50f6fd66622f6e9c913253d6511d1e662"},
+		{"m/0'/1'/2'/2'/1000000000'", "68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230", "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793"},
+	},
+}
+
+func TestKeyVectors(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	for curve, vectors := range keyVectors {
+		master, err := NewMasterKey(seed, curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, v := range vectors {
+			path, err := ParsePath(v.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := master.Derive(path)
+			if err != nil {
+				t.Fatalf("%s: %s", v.path, err)
+			}
+
+			if chainCode := hex.EncodeToString(key.ChainCode); chainCode != v.chainCode {
+				t.Errorf("curve %d %s: chain code %s, want %s", curve, v.path, chainCode, v.chainCode)
+			}
+			if k := hex.EncodeToString(key.Key); k != v.key {
+				t.Errorf("curve %d %s: key %s, want %s", curve, v.path, k, v.key)
+			}
+		}
+	}
+}
+
+func TestEd25519NotHardened(t *testing.T) {
+	master, err := NewMasterKey([]byte("seed of at least 16 bytes"), Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := master.Child(0); err != ErrNotHardened {
+		t.Errorf("Child(0): %v, want %v", err, ErrNotHardened)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := ParsePath("m/44'/1h/0'/0/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{44 + Hardened, 1 + Hardened, Hardened, 0, 5}
+	if len(path) != len(want) {
+		t.Fatalf("ParsePath = %v, want %v", path, want)
+	}
+	for i := range want {
+		if path[i] != want[i] {
+			t.Fatalf("ParsePath = %v, want %v", path, want)
+		}
+	}
+	if formatted := FormatPath(path); formatted != "m/44'/1'/0'/0/5" {
+		t.Errorf("FormatPath = %q", formatted)
+	}
+
+	for _, invalid := range []string{"", "44'/0", "m/", "m/x", "m/-1", "m/2147483648", "m/1''"} {
+		if _, err := ParsePath(invalid); err == nil {
+			t.Errorf("ParsePath(%q) did not fail", invalid)
+		}
+	}
+}
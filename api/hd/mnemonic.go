@@ -0,0 +1,104 @@
+package hd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// MnemonicEntropy is the entropy of new mnemonics in bits, 12 words
+const MnemonicEntropy = 128
+
+// ErrMnemonicChecksum is returned for a mnemonic whose last word does not
+// match the others, usually because of a typo
+var ErrMnemonicChecksum = errors.New("Mnemonic checksum is invalid")
+
+// NewMnemonic returns a BIP39 mnemonic encoding bits of fresh entropy,
+// which must be a multiple of 32 between 128 and 256
+func NewMnemonic(bits int) (string, error) {
+	if bits%32 != 0 || bits < 128 || bits > 256 {
+		return "", fmt.Errorf("Mnemonic entropy of %d bits is not supported", bits)
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy), nil
+}
+
+// EntropyToMnemonic encodes entropy followed by the first bits of its
+// SHA-256 as words of 11 bits each
+func EntropyToMnemonic(entropy []byte) string {
+	checksumBits := len(entropy) / 4
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-uint(checksumBits)))))
+
+	words := make([]string, (len(entropy)*8+checksumBits)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = wordlist[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, 11)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// MnemonicToEntropy decodes a mnemonic and checks its checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, fmt.Errorf("Mnemonic must have 12, 15, 18, 21 or 24 words, not %d", len(words))
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a mnemonic word", word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(data, big.NewInt(1<<uint(checksumBits)-1)).Int64()
+	data.Rsh(data, uint(checksumBits))
+
+	entropy := make([]byte, checksumBits*4)
+	data.FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-uint(checksumBits))) != checksum {
+		return nil, ErrMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// NormalizeMnemonic checks a mnemonic and returns its words in lower case,
+// separated by single spaces
+func NormalizeMnemonic(mnemonic string) (string, error) {
+	entropy, err := MnemonicToEntropy(mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy), nil
+}
+
+// NewSeed derives the 64 byte seed of a mnemonic protected by an optional
+// passphrase with PBKDF2-HMAC-SHA512, as BIP39 does
+func NewSeed(mnemonic, passphrase string) []byte {
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
@@ -0,0 +1,129 @@
+package hd
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// BIP39 test vectors, whose seeds use the passphrase TREZOR
+var mnemonicVectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"80808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+	{
+		"ffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+		"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title",
+		"bc09fca1804f7e69da93c2f2028eb238c227f2e9dda30cd63699232578480a4021b146ad717fbb7e451ce9eb835f43620bf5c514db0f8add49f5d121449d3e87",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+	},
+	{
+		"9e885d952ad362caeb4efe34a8e91bd2",
+		"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+		"274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+	},
+}
+
+func TestMnemonicVectors(t *testing.T) {
+	for _, v := range mnemonicVectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+
+		if mnemonic := EntropyToMnemonic(entropy); mnemonic != v.mnemonic {
+			t.Errorf("EntropyToMnemonic(%s) = %q, want %q", v.entropy, mnemonic, v.mnemonic)
+		}
+
+		decoded, err := MnemonicToEntropy(v.mnemonic)
+		if err != nil {
+			t.Errorf("MnemonicToEntropy(%q): %s", v.mnemonic, err)
+		} else if hex.EncodeToString(decoded) != v.entropy {
+			t.Errorf("MnemonicToEntropy(%q) = %x, want %s", v.mnemonic, decoded, v.entropy)
+		}
+
+		if seed := hex.EncodeToString(NewSeed(v.mnemonic, "TREZOR")); seed != v.seed {
+			t.Errorf("NewSeed(%q) = %s, want %s", v.mnemonic, seed, v.seed)
+		}
+	}
+}
+
+func TestMnemonicInvalid(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		checksum bool // whether ErrMnemonicChecksum is expected
+	}{
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", true},
+		{"legal winner thank year wave sausage worth useful legal winner thank year", true},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", false},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", false},
+		{"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon aboot", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		_, err := MnemonicToEntropy(test.mnemonic)
+		if err == nil || (test.checksum && err != ErrMnemonicChecksum) {
+			t.Errorf("MnemonicToEntropy(%q): %v", test.mnemonic, err)
+		}
+	}
+}
+
+func TestNormalizeMnemonic(t *testing.T) {
+	want := mnemonicVectors[1].mnemonic
+	mnemonic, err := NormalizeMnemonic("  " + strings.ToUpper(strings.Replace(want, " ", "\t ", 3)) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mnemonic != want {
+		t.Errorf("NormalizeMnemonic = %q, want %q", mnemonic, want)
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if words := len(strings.Fields(mnemonic)); words != bits/32*3 {
+			t.Errorf("NewMnemonic(%d) has %d words", bits, words)
+		}
+		if _, err := MnemonicToEntropy(mnemonic); err != nil {
+			t.Errorf("NewMnemonic(%d) = %q: %s", bits, mnemonic, err)
+		}
+	}
+
+	for _, bits := range []int{0, 96, 130, 288} {
+		if _, err := NewMnemonic(bits); err == nil {
+			t.Errorf("NewMnemonic(%d) did not fail", bits)
+		}
+	}
+}
@@ -2,12 +2,17 @@ package utils
 
 import (
 	"bytes"
+	"errors"
 	"math/big"
 )
 
 var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
 
-// Base58Encode encodes a byte array to Base58
+// ErrBase58Invalid is returned when decoding text with a character outside of the Base58 alphabet
+var ErrBase58Invalid = errors.New("Invalid Base58 character")
+
+// Base58Encode encodes a byte array to Base58. Each leading zero byte is
+// written as a leading 1.
 func Base58Encode(input []byte) []byte {
 	var result []byte
 
@@ -23,31 +28,34 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	ReverseBytes(result)
-	for b := range input {
-		if b == 0x00 {
-			result = append([]byte{b58Alphabet[0]}, result...)
-		} else {
+	for _, b := range input {
+		if b != 0x00 {
 			break
 		}
+		result = append([]byte{b58Alphabet[0]}, result...)
 	}
 
 	return result
 }
 
 // Base58Decode decodes Base58-encoded data
-func Base58Decode(input []byte) []byte {
+func Base58Decode(input []byte) ([]byte, error) {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
-			zeroBytes++
+	for _, b := range input {
+		if b != b58Alphabet[0] {
+			break
 		}
+		zeroBytes++
 	}
 
 	payload := input[zeroBytes:]
 	for _, b := range payload {
 		charIndex := bytes.IndexByte(b58Alphabet, b)
+		if charIndex < 0 {
+			return nil, ErrBase58Invalid
+		}
 		result.Mul(result, big.NewInt(58))
 		result.Add(result, big.NewInt(int64(charIndex)))
 	}
@@ -55,5 +63,5 @@ func Base58Decode(input []byte) []byte {
 	decoded := result.Bytes()
 	decoded = append(bytes.Repeat([]byte{byte(0x00)}, zeroBytes), decoded...)
 
-	return decoded
+	return decoded, nil
 }
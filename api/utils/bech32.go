@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// bech32Charset maps 5 bit values to the characters of bech32 text
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Const is the checksum constant of bech32m, which unlike the first
+// bech32 also detects characters inserted or deleted before a final p
+const bech32Const = 0x2bc830a3
+
+// bech32MaxLen is the longest bech32 text
+const bech32MaxLen = 90
+
+// bech32ChecksumLen is the number of characters of the checksum
+const bech32ChecksumLen = 6
+
+// Errors returned when decoding bech32 text
+var (
+	ErrBech32Case     = errors.New("Bech32 text mixes upper and lower case")
+	ErrBech32Length   = errors.New("Bech32 text has the wrong length")
+	ErrBech32Invalid  = errors.New("Invalid bech32 character")
+	ErrBech32Checksum = errors.New("Bech32 checksum does not match")
+	ErrBech32Padding  = errors.New("Bech32 data has invalid padding")
+)
+
+// Bech32Encode encodes data as lowercase bech32 text: the human-readable
+// part hrp, a separating 1, the data in groups of 5 bits and a checksum
+// that detects up to 4 wrong characters
+func Bech32Encode(hrp string, data []byte) string {
+	hrp = strings.ToLower(hrp)
+	values := convertBits(data, 8, 5, true)
+	values = append(values, bech32Checksum(hrp, values)...)
+
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte('1')
+	for _, v := range values {
+		result.WriteByte(bech32Charset[v])
+	}
+
+	return result.String()
+}
+
+// Bech32Decode decodes bech32 text, in all lower or all upper case, and
+// returns its human-readable part in lower case and its data
+func Bech32Decode(text string) (string, []byte, error) {
+	if len(text) > bech32MaxLen {
+		return "", nil, ErrBech32Length
+	}
+	lower := strings.ToLower(text)
+	if lower != text && strings.ToUpper(text) != text {
+		return "", nil, ErrBech32Case
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+1+bech32ChecksumLen > len(lower) {
+		return "", nil, ErrBech32Length
+	}
+	hrp := lower[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, ErrBech32Invalid
+		}
+	}
+
+	var values []byte
+	for i := sep + 1; i < len(lower); i++ {
+		v := strings.IndexByte(bech32Charset, lower[i])
+		if v < 0 {
+			return "", nil, ErrBech32Invalid
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != bech32Const {
+		return "", nil, ErrBech32Checksum
+	}
+
+	data := convertBits(values[:len(values)-bech32ChecksumLen], 5, 8, false)
+	if data == nil {
+		return "", nil, ErrBech32Padding
+	}
+
+	return hrp, data, nil
+}
+
+// bech32Checksum returns the 6 checksum values of hrp and the data values
+func bech32Checksum(hrp string, values []byte) []byte {
+	enc := append(bech32ExpandHRP(hrp), values...)
+	enc = append(enc, make([]byte, bech32ChecksumLen)...)
+	mod := bech32Polymod(enc) ^ bech32Const
+
+	checksum := make([]byte, bech32ChecksumLen)
+	for i := range checksum {
+		checksum[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+
+	return checksum
+}
+
+// bech32Polymod computes the BCH code the checksum is made of
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// bech32ExpandHRP spreads the human-readable part over values of 5 bits
+// for the checksum
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+
+	return expanded
+}
+
+// convertBits regroups data from groups of fromBits bits to groups of
+// toBits bits. The last group is padded with zeros when pad is set,
+// otherwise it returns nil when bits are left over that are not zero
+// padding.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) []byte {
+	var result []byte
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+
+	for _, b := range data {
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil
+	}
+	if result == nil {
+		result = []byte{}
+	}
+
+	return result
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// The test vectors of BIP-350, as the checksum is that of bech32m. The
+// vectors of BIP-173 are for the first bech32 and do not apply, except for
+// the invalid ones.
+
+// bech32mValid are valid bech32m strings. Some carry values that do not
+// regroup into bytes, so only their checksum is checked for them.
+var bech32mValid = []string{
+	"A1LQFN3A",
+	"a1lqfn3a",
+	"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+	"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+	"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+	"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+	"?1v759aa",
+}
+
+// hasBech32mChecksum reports whether the checksum of text is valid
+func hasBech32mChecksum(text string) bool {
+	text = strings.ToLower(text)
+	sep := strings.LastIndexByte(text, '1')
+
+	var values []byte
+	for i := sep + 1; i < len(text); i++ {
+		values = append(values, byte(strings.IndexByte(bech32Charset, text[i])))
+	}
+
+	return bech32Polymod(append(bech32ExpandHRP(text[:sep]), values...)) == bech32Const
+}
+
+func TestBech32ValidChecksums(t *testing.T) {
+	for _, text := range bech32mValid {
+		if !hasBech32mChecksum(text) {
+			t.Errorf("checksum of %q does not match", text)
+		}
+	}
+}
+
+func TestBech32Decode(t *testing.T) {
+	for _, text := range bech32mValid {
+		hrp, data, err := Bech32Decode(text)
+		if err == ErrBech32Padding {
+			// 5 bit values which are not bytes
+			continue
+		}
+		if err != nil {
+			t.Errorf("Bech32Decode(%q): %s", text, err)
+			continue
+		}
+		if encoded := Bech32Encode(hrp, data); encoded != strings.ToLower(text) {
+			t.Errorf("Bech32Encode(Bech32Decode(%q)) = %q", text, encoded)
+		}
+	}
+}
+
+func TestBech32DecodeInvalid(t *testing.T) {
+	tests := []struct {
+		text   string
+		reason string
+		err    error // any error when nil
+	}{
+		{"\x201xj0phk", "HRP character out of range", ErrBech32Invalid},
+		{"\x7f1g6xzxy", "HRP character out of range", ErrBech32Invalid},
+		{"\x801vctc34", "HRP character out of range", nil},
+		{"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4", "overall max length exceeded", ErrBech32Length},
+		{"qyrz8wqd2c9m", "no separator character", ErrBech32Length},
+		{"1qyrz8wqd2c9m", "empty HRP", ErrBech32Length},
+		{"y1b0jsk6g", "invalid data character", ErrBech32Invalid},
+		{"lt1igcx5c0", "invalid data character", ErrBech32Invalid},
+		{"in1muywd", "too short checksum", ErrBech32Length},
+		{"mm1crxm3i", "invalid character in checksum", ErrBech32Invalid},
+		{"au1s5cgom", "invalid character in checksum", ErrBech32Invalid},
+		{"M1VUXWEZ", "checksum calculated with uppercase form of HRP", ErrBech32Checksum},
+		{"16plkw9", "empty HRP", ErrBech32Length},
+		{"1p2gdwpf", "empty HRP", ErrBech32Length},
+		{"A1LqFN3A", "mixed case", ErrBech32Case},
+		{"A12UEL5L", "bech32 checksum, not bech32m", ErrBech32Checksum},
+		{"a1lqfn3q", "wrong checksum character", ErrBech32Checksum},
+	}
+
+	for _, test := range tests {
+		_, _, err := Bech32Decode(test.text)
+		if err == nil || (test.err != nil && err != test.err) {
+			t.Errorf("Bech32Decode(%q), %s: %v, want %v", test.text, test.reason, err, test.err)
+		}
+	}
+}
+
+func TestBech32RoundTrip(t *testing.T) {
+	for n := 0; n <= 40; n++ {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i*37 + n)
+		}
+
+		text := Bech32Encode("gwf", data)
+		hrp, decoded, err := Bech32Decode(strings.ToUpper(text))
+		if err != nil || hrp != "gwf" || string(decoded) != string(data) {
+			t.Errorf("Bech32Decode(%q) = %q, %x, %v, want gwf, %x", text, hrp, decoded, err, data)
+		}
+	}
+}
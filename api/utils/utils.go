@@ -3,6 +3,7 @@ package utils
 import (
 	"bytes"
 	"encoding/binary"
+	"io/ioutil"
 	"log"
 	"os"
 )
@@ -26,6 +27,18 @@ func IntToHex(num int64) []byte {
 	return buff.Bytes()
 }
 
+// WriteFileAtomic writes data to a temporary file next to file and renames
+// it over file, so that a crash never leaves half of it
+func WriteFileAtomic(file string, data []byte, perm os.FileMode) error {
+	tmpFile := file + ".tmp"
+	err := ioutil.WriteFile(tmpFile, data, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, file)
+}
+
 // ReverseBytes reverses a byte array
 func ReverseBytes(data []byte) {
 	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {